@@ -10,14 +10,28 @@ import (
 	"net/http"
 )
 
-func getHttpApiRoutes(taskRepo task.TaskRepo, reg *registry.Registry) []*apiserver.HttpRoute {
-	httpApi := apihandler.NewHttpApi(service.NewTaskService(taskRepo, reg), service.NewRegistryService(reg))
+func getHttpApiRoutes(taskRepo task.TaskRepo, workflowRepo task.WorkflowRepo, reg *registry.Registry, circuitBreaker *task.RouteCircuitBreaker, callbackExec task.TaskCallbackSrvExec) []*apiserver.HttpRoute {
+	httpApi := apihandler.NewHttpApi(
+		service.NewTaskService(taskRepo, reg, callbackExec),
+		service.NewRegistryService(reg, circuitBreaker),
+		service.NewWorkflowService(workflowRepo, taskRepo),
+		)
 	return []*apiserver.HttpRoute{
 		{Path: httpproto.AddTaskCmdPath, Method: http.MethodPost, Handler: httpApi.AddTask},
 		{Path: httpproto.StopTaskCmdPath, Method: http.MethodPost, Handler: httpApi.StopTask},
+		{Path: httpproto.PauseTaskCmdPath, Method: http.MethodPost, Handler: httpApi.PauseTask},
+		{Path: httpproto.ResumeTaskCmdPath, Method: http.MethodPost, Handler: httpApi.ResumeTask},
+		{Path: httpproto.TriggerTaskCmdPath, Method: http.MethodPost, Handler: httpApi.TriggerTask},
 		{Path: httpproto.ConfirmTaskCmdPath, Method: http.MethodPost, Handler: httpApi.ConfirmTask},
+		{Path: httpproto.CancelRunCmdPath, Method: http.MethodPost, Handler: httpApi.CancelRun},
+		{Path: httpproto.ReportTaskProgressCmdPath, Method: http.MethodPost, Handler: httpApi.ReportTaskProgress},
+		{Path: httpproto.GetTaskRunCmdPath, Method: http.MethodPost, Handler: httpApi.GetTaskRun},
 		{Path: httpproto.RegisterTaskCallbackSrvCmdPath, Method: http.MethodPost, Handler: httpApi.RegisterTaskCallbackSrv},
 		{Path: httpproto.UnregisterTaskCallbackSrvCmdPath, Method: http.MethodPost, Handler: httpApi.UnregisterTaskCallbackSrv},
+		{Path: httpproto.GetRouteCircuitBreakersCmdPath, Method: http.MethodPost, Handler: httpApi.GetRouteCircuitBreakers},
+		{Path: httpproto.AddWorkflowCmdPath, Method: http.MethodPost, Handler: httpApi.AddWorkflow},
+		{Path: httpproto.StartWorkflowCmdPath, Method: http.MethodPost, Handler: httpApi.StartWorkflow},
+		{Path: httpproto.GetWorkflowRunCmdPath, Method: http.MethodPost, Handler: httpApi.GetWorkflowRun},
 	}
 }
 
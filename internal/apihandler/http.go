@@ -15,12 +15,14 @@ import (
 type HttpApi struct {
 	taskSrv     *service.TaskService
 	registrySrv *service.RegistryService
+	workflowSrv *service.WorkflowService
 }
 
-func NewHttpApi(taskSrv *service.TaskService, registrySrv *service.RegistryService) *HttpApi {
+func NewHttpApi(taskSrv *service.TaskService, registrySrv *service.RegistryService, workflowSrv *service.WorkflowService) *HttpApi {
 	return &HttpApi{
 		taskSrv:     taskSrv,
 		registrySrv: registrySrv,
+		workflowSrv: workflowSrv,
 	}
 }
 
@@ -42,18 +44,121 @@ func (a *HttpApi) AddTask(ctx context.Context, req *httpproto.AddTaskReq) (*http
 		if req.TimeIntervalSec == 0 {
 			return nil, errs.NewBizErrWithMsg(errs.ErrCodeArgsInvalid, "specific time interval is empty")
 		}
+	case proto.SchedModeFixedDelay:
+		schedMode = task.SchedModeFixedDelay
+		if req.TimeIntervalSec == 0 {
+			return nil, errs.NewBizErrWithMsg(errs.ErrCodeArgsInvalid, "specific time interval is empty")
+		}
+	}
+
+	if err := task.CheckTimeZone(req.TimeZone); err != nil {
+		return nil, errs.NewBizErrWithMsg(errs.ErrCodeArgsInvalid, "invalid time zone")
+	}
+
+	var misfirePolicy task.MisfirePolicy
+	switch req.MisfirePolicy {
+	case proto.MisfirePolicyFireOnce:
+		misfirePolicy = task.MisfirePolicyFireOnce
+	case proto.MisfirePolicyFireAll:
+		misfirePolicy = task.MisfirePolicyFireAll
+	case proto.MisfirePolicySkip:
+		misfirePolicy = task.MisfirePolicySkip
+	case proto.MisfirePolicySkipIfExpired:
+		misfirePolicy = task.MisfirePolicySkipIfExpired
+		if req.MisfireThresholdSec == 0 {
+			return nil, errs.NewBizErrWithMsg(errs.ErrCodeArgsInvalid, "misfire threshold is empty")
+		}
+	default:
+		return nil, errs.NewBizErrWithMsg(errs.ErrCodeArgsInvalid, "invalid misfire policy")
+	}
+
+	var concurrencyPolicy task.ConcurrencyPolicy
+	switch req.ConcurrencyPolicy {
+	case proto.ConcurrencyPolicyAllow:
+		concurrencyPolicy = task.ConcurrencyPolicyAllow
+	case proto.ConcurrencyPolicyForbid:
+		concurrencyPolicy = task.ConcurrencyPolicyForbid
+	case proto.ConcurrencyPolicyQueue:
+		concurrencyPolicy = task.ConcurrencyPolicyQueue
+	default:
+		return nil, errs.NewBizErrWithMsg(errs.ErrCodeArgsInvalid, "invalid concurrency policy")
+	}
+
+	var dispatchMode task.DispatchMode
+	switch req.DispatchMode {
+	case proto.DispatchModeSingle:
+		dispatchMode = task.DispatchModeSingle
+	case proto.DispatchModeBroadcast:
+		dispatchMode = task.DispatchModeBroadcast
+	default:
+		return nil, errs.NewBizErrWithMsg(errs.ErrCodeArgsInvalid, "invalid dispatch mode")
+	}
+
+	var broadcastSuccessRule task.BroadcastSuccessRule
+	switch req.BroadcastSuccessRule {
+	case proto.BroadcastSuccessRuleAll:
+		broadcastSuccessRule = task.BroadcastSuccessRuleAll
+	case proto.BroadcastSuccessRuleAny:
+		broadcastSuccessRule = task.BroadcastSuccessRuleAny
+	default:
+		return nil, errs.NewBizErrWithMsg(errs.ErrCodeArgsInvalid, "invalid broadcast success rule")
+	}
+
+	routeStrategy, err := toTaskRouteStrategy(req.RouteStrategy)
+	if err != nil {
+		return nil, err
+	}
+
+	callbackMethod := task.CallbackMethod(strings.ToUpper(req.CallbackMethod))
+	switch callbackMethod {
+	case task.CallbackMethodNil, task.CallbackMethodGet, task.CallbackMethodPost, task.CallbackMethodPut:
+	default:
+		return nil, errs.NewBizErrWithMsg(errs.ErrCodeArgsInvalid, "invalid callback method")
+	}
+
+	callbackContentType := task.CallbackContentType(strings.ToLower(req.CallbackContentType))
+	switch callbackContentType {
+	case task.CallbackContentTypeNil, task.CallbackContentTypeJson, task.CallbackContentTypeForm:
+	default:
+		return nil, errs.NewBizErrWithMsg(errs.ErrCodeArgsInvalid, "invalid callback content type")
+	}
+
+	var retryPolicy *task.RetryPolicy
+	if req.RetryPolicy != nil && req.RetryPolicy.MaxAttempts > 0 {
+		retryPolicy = task.NewRetryPolicy(
+			req.RetryPolicy.MaxAttempts,
+			req.RetryPolicy.InitialDelaySec,
+			req.RetryPolicy.Multiplier,
+			req.RetryPolicy.MaxDelaySec,
+			req.RetryPolicy.Jitter,
+			)
 	}
 
 	addTaskResp, err := a.taskSrv.AddTask(ctx, &service.AddTaskReq{
 		Name:            req.Name,
 		SrvName:         req.SrvName,
 		CallbackPath:    req.CallbackPath,
+		CallbackMethod:  callbackMethod,
+		CallbackContentType: callbackContentType,
 		SchedMode:       schedMode,
 		TimeSpecAt:      req.TimeSpecAt,
 		TimeIntervalSec: req.TimeIntervalSec,
 		TimeCron:        req.TimeCron,
+		TimeZone:        req.TimeZone,
 		Arg:             req.Arg,
 		BizId:           req.BizId,
+		RetryPolicy:     retryPolicy,
+		MisfirePolicy:   misfirePolicy,
+		MisfireMaxCatchUpTimes: req.MisfireMaxCatchUpTimes,
+		MisfireThresholdSec: req.MisfireThresholdSec,
+		ConcurrencyPolicy: concurrencyPolicy,
+		MaxRunTimeSec: req.MaxRunTimeSec,
+		DispatchMode: dispatchMode,
+		BroadcastSuccessRule: broadcastSuccessRule,
+		ShardTotal: req.ShardTotal,
+		RouteStrategy: routeStrategy,
+		LabelSelector: req.LabelSelector,
+		FailoverMaxAttempts: req.FailoverMaxAttempts,
 	})
 	if err != nil {
 		logger.MustGetSessLogger().Error(ctx, err)
@@ -82,6 +187,53 @@ func (a *HttpApi) StopTask(ctx context.Context, req *httpproto.StopTaskReq) (*ht
 	return &httpproto.StopTaskResp{}, nil
 }
 
+func (a *HttpApi) PauseTask(ctx context.Context, req *httpproto.PauseTaskReq) (*httpproto.PauseTaskResp, error) {
+	pauseTaskReq := &service.PauseTaskReq{}
+	err := reflectutil.CopySameFields(req, pauseTaskReq)
+	if err != nil {
+		logger.MustGetSessLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	_, err = a.taskSrv.PauseTask(ctx, pauseTaskReq)
+	if err != nil {
+		logger.MustGetSessLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	return &httpproto.PauseTaskResp{}, nil
+}
+
+func (a *HttpApi) ResumeTask(ctx context.Context, req *httpproto.ResumeTaskReq) (*httpproto.ResumeTaskResp, error) {
+	resumeTaskReq := &service.ResumeTaskReq{}
+	err := reflectutil.CopySameFields(req, resumeTaskReq)
+	if err != nil {
+		logger.MustGetSessLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	_, err = a.taskSrv.ResumeTask(ctx, resumeTaskReq)
+	if err != nil {
+		logger.MustGetSessLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	return &httpproto.ResumeTaskResp{}, nil
+}
+
+func (a *HttpApi) TriggerTask(ctx context.Context, req *httpproto.TriggerTaskReq) (*httpproto.TriggerTaskResp, error) {
+	_, err := a.taskSrv.TriggerTask(ctx, &service.TriggerTaskReq{
+		TaskId: req.TaskId,
+		Arg: req.Arg,
+	})
+	if err != nil {
+		logger.MustGetSessLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	return &httpproto.TriggerTaskResp{}, nil
+}
+
 func (a *HttpApi) ConfirmTask(ctx context.Context, req *httpproto.ConfirmTaskReq) (*httpproto.ConfirmTaskResp, error) {
 	confirmTaskReq := &service.ConfirmTaskReq{}
 	err := reflectutil.CopySameFields(req, confirmTaskReq)
@@ -99,15 +251,88 @@ func (a *HttpApi) ConfirmTask(ctx context.Context, req *httpproto.ConfirmTaskReq
 	return &httpproto.ConfirmTaskResp{}, nil
 }
 
-func (a *HttpApi) RegisterTaskCallbackSrv(ctx context.Context, req *httpproto.RegisterTaskCallbackSrvReq) (*httpproto.RegisterTaskCallbackSrvResp, error) {
-	registerSrvReq := &service.RegisterTaskCallbackSrvReq{}
-	err := reflectutil.CopySameFields(req, registerSrvReq)
+func (a *HttpApi) CancelRun(ctx context.Context, req *httpproto.CancelRunReq) (*httpproto.CancelRunResp, error) {
+	cancelRunReq := &service.CancelRunReq{}
+	err := reflectutil.CopySameFields(req, cancelRunReq)
+	if err != nil {
+		logger.MustGetSessLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	_, err = a.taskSrv.CancelRun(ctx, cancelRunReq)
+	if err != nil {
+		logger.MustGetSessLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	return &httpproto.CancelRunResp{}, nil
+}
+
+func (a *HttpApi) ReportTaskProgress(ctx context.Context, req *httpproto.ReportTaskProgressReq) (*httpproto.ReportTaskProgressResp, error) {
+	_, err := a.taskSrv.ReportTaskProgress(ctx, &service.ReportTaskProgressReq{
+		TaskId: req.TaskId,
+		TaskRunTimes: req.TaskRunTimes,
+		Progress: req.Progress,
+		Message: req.Message,
+	})
 	if err != nil {
 		logger.MustGetSessLogger().Error(ctx, err)
 		return nil, err
 	}
 
-	_, err = a.registrySrv.RegisterTaskCallbackSrv(ctx, registerSrvReq)
+	return &httpproto.ReportTaskProgressResp{}, nil
+}
+
+func (a *HttpApi) GetTaskRun(ctx context.Context, req *httpproto.GetTaskRunReq) (*httpproto.GetTaskRunResp, error) {
+	getTaskRunResp, err := a.taskSrv.GetTaskRun(ctx, &service.GetTaskRunReq{
+		TaskId: req.TaskId,
+		TaskRunTimes: req.TaskRunTimes,
+	})
+	if err != nil {
+		logger.MustGetSessLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	taskRun := getTaskRunResp.TaskRun
+	return &httpproto.GetTaskRunResp{
+		TaskId: taskRun.GetTaskId(),
+		TaskRunTimes: taskRun.GetRunTimes(),
+		Status: toProtoRunStatus(taskRun.GetStatus()),
+		StartedAt: taskRun.GetStartedAt(),
+		EndedAt: taskRun.GetEndedAt(),
+		IsTimeout: taskRun.IsTimeout(),
+		IsCancelled: taskRun.IsCancelled(),
+		Progress: taskRun.GetProgress(),
+		ProgressMessage: taskRun.GetProgressMsg(),
+		HeartbeatAt: taskRun.GetHeartbeatAt(),
+	}, nil
+}
+
+func (a *HttpApi) RegisterTaskCallbackSrv(ctx context.Context, req *httpproto.RegisterTaskCallbackSrvReq) (*httpproto.RegisterTaskCallbackSrvResp, error) {
+	routeStrategy, err := toTaskRouteStrategy(req.RouteStrategy)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = a.registrySrv.RegisterTaskCallbackSrv(ctx, &service.RegisterTaskCallbackSrvReq{
+		Name: req.Name,
+		Schema: req.Schema,
+		Host: req.Host,
+		Port: req.Port,
+		CallbackTimeoutSec: req.CallbackTimeoutSec,
+		IsEnableHealthCheck: req.IsEnableHealthCheck,
+		Weight: req.Weight,
+		Labels: req.Labels,
+		MaxInFlight: req.MaxInFlight,
+		MaxPerSec: req.MaxPerSec,
+		StreamKey: req.StreamKey,
+		StreamMaxLen: req.StreamMaxLen,
+		Secret: req.Secret,
+		ClearSecret: req.ClearSecret,
+		Headers: req.Headers,
+		SecretHeaders: req.SecretHeaders,
+		RouteStrategy: routeStrategy,
+	})
 	if err != nil {
 		logger.MustGetSessLogger().Error(ctx, err)
 		return nil, err
@@ -132,3 +357,166 @@ func (a *HttpApi) UnregisterTaskCallbackSrv(ctx context.Context, req *httpproto.
 
 	return &httpproto.UnregisterTaskCallbackSrvResp{}, nil
 }
+
+func (a *HttpApi) GetRouteCircuitBreakers(ctx context.Context, _ *httpproto.GetRouteCircuitBreakersReq) (*httpproto.GetRouteCircuitBreakersResp, error) {
+	getBreakersResp, err := a.registrySrv.GetRouteCircuitBreakers(ctx, &service.GetRouteCircuitBreakersReq{})
+	if err != nil {
+		logger.MustGetSessLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	resp := &httpproto.GetRouteCircuitBreakersResp{}
+	for _, state := range getBreakersResp.States {
+		protoBreaker := &httpproto.RouteCircuitBreaker{
+			RouteId: state.GetRoute().GetId(),
+			Schema: state.GetRoute().GetSchema(),
+			Host: state.GetRoute().GetHost(),
+			Port: state.GetRoute().GetPort(),
+			Calls: state.GetCalls(),
+			Failures: state.GetFailures(),
+			OpenedAt: state.GetOpenedAt(),
+		}
+		switch state.GetState() {
+		case task.CircuitBreakerStateOpen:
+			protoBreaker.State = proto.CircuitBreakerStateOpen
+		case task.CircuitBreakerStateHalfOpen:
+			protoBreaker.State = proto.CircuitBreakerStateHalfOpen
+		default:
+			protoBreaker.State = proto.CircuitBreakerStateClosed
+		}
+		resp.Routes = append(resp.Routes, protoBreaker)
+	}
+
+	return resp, nil
+}
+
+func (a *HttpApi) AddWorkflow(ctx context.Context, req *httpproto.AddWorkflowReq) (*httpproto.AddWorkflowResp, error) {
+	var (
+		nodes []*task.WorkflowNode
+		edges []*task.WorkflowEdge
+	)
+	for _, node := range req.Nodes {
+		nodes = append(nodes, task.NewWorkflowNode(node.Name, node.TaskId))
+	}
+	for _, edge := range req.Edges {
+		var condition task.WorkflowEdgeCondition
+		switch edge.Condition {
+		case proto.WorkflowEdgeConditionOnSuccess:
+			condition = task.WorkflowEdgeConditionOnSuccess
+		case proto.WorkflowEdgeConditionOnFailure:
+			condition = task.WorkflowEdgeConditionOnFailure
+		case proto.WorkflowEdgeConditionAlways:
+			condition = task.WorkflowEdgeConditionAlways
+		default:
+			return nil, errs.NewBizErrWithMsg(errs.ErrCodeArgsInvalid, "invalid workflow edge condition")
+		}
+		edges = append(edges, task.NewWorkflowEdge(edge.From, edge.To, condition))
+	}
+
+	addWorkflowResp, err := a.workflowSrv.AddWorkflow(ctx, &service.AddWorkflowReq{
+		Name: req.Name,
+		Nodes: nodes,
+		Edges: edges,
+	})
+	if err != nil {
+		logger.MustGetSessLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	return &httpproto.AddWorkflowResp{
+		WorkflowId: addWorkflowResp.WorkflowId,
+	}, nil
+}
+
+func (a *HttpApi) StartWorkflow(ctx context.Context, req *httpproto.StartWorkflowReq) (*httpproto.StartWorkflowResp, error) {
+	startWorkflowResp, err := a.workflowSrv.StartWorkflow(ctx, &service.StartWorkflowReq{
+		WorkflowId: req.WorkflowId,
+	})
+	if err != nil {
+		logger.MustGetSessLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	return &httpproto.StartWorkflowResp{
+		WorkflowRunId: startWorkflowResp.WorkflowRunId,
+	}, nil
+}
+
+func (a *HttpApi) GetWorkflowRun(ctx context.Context, req *httpproto.GetWorkflowRunReq) (*httpproto.GetWorkflowRunResp, error) {
+	getWorkflowRunResp, err := a.workflowSrv.GetWorkflowRun(ctx, &service.GetWorkflowRunReq{
+		WorkflowRunId: req.WorkflowRunId,
+	})
+	if err != nil {
+		logger.MustGetSessLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	workflowRun := getWorkflowRunResp.WorkflowRun
+	resp := &httpproto.GetWorkflowRunResp{
+		WorkflowRunId: workflowRun.GetId(),
+		WorkflowId: workflowRun.GetWorkflow().GetId(),
+		WorkflowName: workflowRun.GetWorkflow().GetName(),
+		Status: toProtoRunStatus(workflowRun.GetStatus()),
+		StartedAt: workflowRun.GetStartedAt(),
+		EndedAt: workflowRun.GetEndedAt(),
+	}
+	for _, nodeRun := range workflowRun.GetNodeRuns() {
+		resp.Nodes = append(resp.Nodes, &httpproto.WorkflowNodeRun{
+			Name: nodeRun.GetNodeName(),
+			TaskId: nodeRun.GetTaskId(),
+			Status: toProtoRunStatus(nodeRun.GetStatus()),
+			TaskRunTimes: nodeRun.GetTaskRunTimes(),
+		})
+	}
+	for _, edge := range workflowRun.GetWorkflow().GetEdges() {
+		protoEdge := &httpproto.WorkflowEdge{
+			From: edge.GetFrom(),
+			To: edge.GetTo(),
+		}
+		switch edge.GetCondition() {
+		case task.WorkflowEdgeConditionOnFailure:
+			protoEdge.Condition = proto.WorkflowEdgeConditionOnFailure
+		case task.WorkflowEdgeConditionAlways:
+			protoEdge.Condition = proto.WorkflowEdgeConditionAlways
+		default:
+			protoEdge.Condition = proto.WorkflowEdgeConditionOnSuccess
+		}
+		resp.Edges = append(resp.Edges, protoEdge)
+	}
+
+	return resp, nil
+}
+
+func toProtoRunStatus(status task.Status) proto.RunStatus {
+	switch status {
+	case task.StatusRunning:
+		return proto.RunStatusRunning
+	case task.StatusSuccess:
+		return proto.RunStatusSuccess
+	case task.StatusFailed:
+		return proto.RunStatusFailed
+	case task.StatusSkipped:
+		return proto.RunStatusSkipped
+	}
+	return proto.RunStatusPending
+}
+
+func toTaskRouteStrategy(routeStrategy proto.RouteStrategy) (task.RouteStrategy, error) {
+	switch routeStrategy {
+	case proto.RouteStrategyNil:
+		return task.RouteStrategyNil, nil
+	case proto.RouteStrategyRandom:
+		return task.RouteStrategyRandom, nil
+	case proto.RouteStrategyRoundRobin:
+		return task.RouteStrategyRoundRobin, nil
+	case proto.RouteStrategyWeightedRandom:
+		return task.RouteStrategyWeightedRandom, nil
+	case proto.RouteStrategyConsistentHash:
+		return task.RouteStrategyConsistentHash, nil
+	case proto.RouteStrategyLeastRecentlyUsed:
+		return task.RouteStrategyLeastRecentlyUsed, nil
+	case proto.RouteStrategyLeastInFlight:
+		return task.RouteStrategyLeastInFlight, nil
+	}
+	return task.RouteStrategyNil, errs.NewBizErrWithMsg(errs.ErrCodeArgsInvalid, "invalid route strategy")
+}
@@ -8,12 +8,27 @@ type AddTaskReq struct {
 	Name string
 	SrvName string
 	CallbackPath string
+	CallbackMethod task.CallbackMethod
+	CallbackContentType task.CallbackContentType
 	SchedMode task.SchedMode
 	TimeCron string
+	TimeZone string
 	TimeIntervalSec int
 	TimeSpecAt int64
 	Arg string
 	BizId string
+	RetryPolicy *task.RetryPolicy
+	MisfirePolicy task.MisfirePolicy
+	MisfireMaxCatchUpTimes int
+	MisfireThresholdSec int
+	ConcurrencyPolicy task.ConcurrencyPolicy
+	MaxRunTimeSec int
+	DispatchMode task.DispatchMode
+	BroadcastSuccessRule task.BroadcastSuccessRule
+	ShardTotal int
+	RouteStrategy task.RouteStrategy
+	LabelSelector map[string]string
+	FailoverMaxAttempts int
 }
 
 type AddTaskResp struct {
@@ -27,16 +42,66 @@ type StopTaskReq struct {
 type StopTaskResp struct {
 }
 
+type PauseTaskReq struct {
+	TaskId string
+}
+
+type PauseTaskResp struct {
+}
+
+type ResumeTaskReq struct {
+	TaskId string
+}
+
+type ResumeTaskResp struct {
+}
+
+type TriggerTaskReq struct {
+	TaskId string
+	Arg *string
+}
+
+type TriggerTaskResp struct {
+}
+
 type ConfirmTaskReq struct {
 	TaskId string
 	IsSuccess bool
 	Extra string
 	TaskRunTimes int
+	SubRunIdx int
 }
 
 type ConfirmTaskResp struct {
 }
 
+type CancelRunReq struct {
+	TaskId string
+	TaskRunTimes int
+}
+
+type CancelRunResp struct {
+}
+
+type ReportTaskProgressReq struct {
+	TaskId string
+	TaskRunTimes int
+	Progress *int
+	Message string
+}
+
+type ReportTaskProgressResp struct {
+}
+
+type GetTaskRunReq struct {
+	TaskId string
+	TaskRunTimes int
+}
+
+type GetTaskRunResp struct {
+	TaskRun *task.TaskRun
+}
+
 type RegisterTaskCallbackSrvReq struct {
 	Name string
 	Schema string
@@ -44,6 +109,17 @@ type RegisterTaskCallbackSrvReq struct {
 	Port int
 	CallbackTimeoutSec int
 	IsEnableHealthCheck bool
+	Weight int
+	Labels map[string]string
+	MaxInFlight int
+	MaxPerSec int
+	StreamKey string
+	StreamMaxLen int64
+	Secret string
+	ClearSecret bool
+	Headers map[string]string
+	SecretHeaders map[string]string
+	RouteStrategy task.RouteStrategy
 }
 
 type RegisterTaskCallbackSrvResp struct {
@@ -59,4 +135,35 @@ type UnregisterTaskCallbackSrvReq struct {
 type UnregisterTaskCallbackSrvResp struct {
 }
 
+type GetRouteCircuitBreakersReq struct {
+}
+
+type GetRouteCircuitBreakersResp struct {
+	States []*task.RouteCircuitBreakerState
+}
+
+type AddWorkflowReq struct {
+	Name string
+	Nodes []*task.WorkflowNode
+	Edges []*task.WorkflowEdge
+}
+
+type AddWorkflowResp struct {
+	WorkflowId string
+}
+
+type StartWorkflowReq struct {
+	WorkflowId string
+}
+
+type StartWorkflowResp struct {
+	WorkflowRunId string
+}
 
+type GetWorkflowRunReq struct {
+	WorkflowRunId string
+}
+
+type GetWorkflowRunResp struct {
+	WorkflowRun *task.WorkflowRun
+}
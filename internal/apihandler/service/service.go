@@ -5,18 +5,22 @@ import (
 	"github.com/995933447/easytask/internal/registry"
 	"github.com/995933447/easytask/internal/task"
 	"github.com/995933447/easytask/internal/util/logger"
+	"github.com/995933447/easytask/pkg/errs"
+	"time"
 )
 
-func NewTaskService(taskRepo task.TaskRepo, reg *registry.Registry) *TaskService {
+func NewTaskService(taskRepo task.TaskRepo, reg *registry.Registry, callbackExec task.TaskCallbackSrvExec) *TaskService {
 	return &TaskService{
 		taskRepo: taskRepo,
 		reg: reg,
+		callbackExec: callbackExec,
 	}
 }
 
 type TaskService struct {
 	taskRepo task.TaskRepo
 	reg      *registry.Registry
+	callbackExec task.TaskCallbackSrvExec
 }
 
 func (s *TaskService) AddTask(ctx context.Context, req *AddTaskReq) (*AddTaskResp, error) {
@@ -29,13 +33,28 @@ func (s *TaskService) AddTask(ctx context.Context, req *AddTaskReq) (*AddTaskRes
 	oneTask, err := task.NewTask(&task.NewTaskReq{
 		CallbackSrv: srv,
 		CallbackPath: req.CallbackPath,
+		CallbackMethod: req.CallbackMethod,
+		CallbackContentType: req.CallbackContentType,
 		Name: req.Name,
 		Arg: req.Arg,
 		SchedMode: req.SchedMode,
 		TimeSpecAt: req.TimeSpecAt,
 		TimeCronExpr: req.TimeCron,
+		TimeZone: req.TimeZone,
 		TimeIntervalSec: req.TimeIntervalSec,
 		BizId: req.BizId,
+		RetryPolicy: req.RetryPolicy,
+		MisfirePolicy: req.MisfirePolicy,
+		MisfireMaxCatchUpTimes: req.MisfireMaxCatchUpTimes,
+		MisfireThresholdSec: req.MisfireThresholdSec,
+		ConcurrencyPolicy: req.ConcurrencyPolicy,
+		MaxRunTimeSec: req.MaxRunTimeSec,
+		DispatchMode: req.DispatchMode,
+		BroadcastSuccessRule: req.BroadcastSuccessRule,
+		ShardTotal: req.ShardTotal,
+		RouteStrategy: req.RouteStrategy,
+		LabelSelector: req.LabelSelector,
+		FailoverMaxAttempts: req.FailoverMaxAttempts,
 	})
 	if err != nil {
 		logger.MustGetSessLogger().Error(ctx, err)
@@ -59,6 +78,45 @@ func (s *TaskService) StopTask(ctx context.Context, req *StopTaskReq) (*StopTask
 	return &StopTaskResp{}, nil
 }
 
+func (s *TaskService) PauseTask(ctx context.Context, req *PauseTaskReq) (*PauseTaskResp, error) {
+	if err := s.taskRepo.PauseTask(ctx, req.TaskId); err != nil {
+		logger.MustGetSessLogger().Error(ctx, err)
+		return nil, err
+	}
+	return &PauseTaskResp{}, nil
+}
+
+func (s *TaskService) ResumeTask(ctx context.Context, req *ResumeTaskReq) (*ResumeTaskResp, error) {
+	if err := s.taskRepo.ResumeTask(ctx, req.TaskId); err != nil {
+		logger.MustGetSessLogger().Error(ctx, err)
+		return nil, err
+	}
+	return &ResumeTaskResp{}, nil
+}
+
+func (s *TaskService) TriggerTask(ctx context.Context, req *TriggerTaskReq) (*TriggerTaskResp, error) {
+	if _, err := s.taskRepo.GetTaskById(ctx, req.TaskId); err != nil {
+		logger.MustGetSessLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	newTriggerReq := &task.NewTaskTriggerReq{
+		TriggerType: task.TriggerTypeManual,
+		PlanAt: time.Now().Unix(),
+	}
+	if req.Arg != nil {
+		newTriggerReq.Arg = *req.Arg
+		newTriggerReq.HasArg = true
+	}
+
+	if _, err := s.taskRepo.AddTaskTrigger(ctx, req.TaskId, task.NewTaskTrigger(newTriggerReq)); err != nil {
+		logger.MustGetSessLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	return &TriggerTaskResp{}, nil
+}
+
 func (s *TaskService) ConfirmTask(ctx context.Context, req *ConfirmTaskReq) (*ConfirmTaskResp, error) {
 	var taskStatus task.Status
 	if req.IsSuccess {
@@ -66,7 +124,11 @@ func (s *TaskService) ConfirmTask(ctx context.Context, req *ConfirmTaskReq) (*Co
 	} else {
 		taskStatus = task.StatusFailed
 	}
-	err := s.taskRepo.ConfirmTask(ctx, task.NewTaskResp(req.TaskId, true, taskStatus, req.TaskRunTimes, req.Extra))
+	resp := task.NewTaskResp(req.TaskId, true, taskStatus, req.TaskRunTimes, req.Extra)
+	if req.SubRunIdx > 0 {
+		resp = task.NewSubRunTaskResp(req.TaskId, req.SubRunIdx, taskStatus, req.TaskRunTimes, req.Extra)
+	}
+	err := s.taskRepo.ConfirmTask(ctx, resp)
 	if err != nil {
 		logger.MustGetSessLogger().Error(ctx, err)
 		return nil, err
@@ -74,21 +136,98 @@ func (s *TaskService) ConfirmTask(ctx context.Context, req *ConfirmTaskReq) (*Co
 	return &ConfirmTaskResp{}, nil
 }
 
-func NewRegistryService(reg *registry.Registry) *RegistryService {
+// 先把执行确认为取消,之后这次执行的确认都会被拒绝,再通知执行任务的节点.通知失败不影响取消结果
+func (s *TaskService) CancelRun(ctx context.Context, req *CancelRunReq) (*CancelRunResp, error) {
+	oneTask, err := s.taskRepo.GetTaskById(ctx, req.TaskId)
+	if err != nil {
+		logger.MustGetSessLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	if err = s.taskRepo.ConfirmTask(ctx, task.NewCancelledTaskResp(req.TaskId, req.TaskRunTimes)); err != nil {
+		logger.MustGetSessLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	routes, err := s.taskRepo.GetRunCallbackRoutes(ctx, req.TaskId, req.TaskRunTimes)
+	if err != nil {
+		logger.MustGetSessLogger().Error(ctx, err)
+		return &CancelRunResp{}, nil
+	}
+
+	if err = s.callbackExec.CancelRun(ctx, oneTask, req.TaskRunTimes, routes); err != nil {
+		logger.MustGetSessLogger().Error(ctx, err)
+	}
+
+	return &CancelRunResp{}, nil
+}
+
+func (s *TaskService) ReportTaskProgress(ctx context.Context, req *ReportTaskProgressReq) (*ReportTaskProgressResp, error) {
+	newProgressReq := &task.NewTaskRunProgressReq{
+		TaskId: req.TaskId,
+		RunTimes: req.TaskRunTimes,
+		ReportedAt: time.Now().Unix(),
+	}
+	if req.Progress != nil {
+		newProgressReq.Progress = *req.Progress
+		newProgressReq.Msg = req.Message
+		newProgressReq.HasProgress = true
+	}
+
+	progress, err := task.NewTaskRunProgress(newProgressReq)
+	if err != nil {
+		logger.MustGetSessLogger().Error(ctx, err)
+		return nil, errs.NewBizErrWithMsg(errs.ErrCodeArgsInvalid, err.Error())
+	}
+
+	if err = s.taskRepo.ReportTaskProgress(ctx, progress); err != nil {
+		logger.MustGetSessLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	return &ReportTaskProgressResp{}, nil
+}
+
+func (s *TaskService) GetTaskRun(ctx context.Context, req *GetTaskRunReq) (*GetTaskRunResp, error) {
+	taskRun, err := s.taskRepo.GetTaskRun(ctx, req.TaskId, req.TaskRunTimes)
+	if err != nil {
+		logger.MustGetSessLogger().Error(ctx, err)
+		return nil, err
+	}
+	return &GetTaskRunResp{TaskRun: taskRun}, nil
+}
+
+func NewRegistryService(reg *registry.Registry, circuitBreaker *task.RouteCircuitBreaker) *RegistryService {
 	return &RegistryService{
 		reg: reg,
+		circuitBreaker: circuitBreaker,
 	}
 }
 
 type RegistryService struct {
 	reg *registry.Registry
+	circuitBreaker *task.RouteCircuitBreaker
+}
+
+func (s *RegistryService) GetRouteCircuitBreakers(_ context.Context, _ *GetRouteCircuitBreakersReq) (*GetRouteCircuitBreakersResp, error) {
+	return &GetRouteCircuitBreakersResp{States: s.circuitBreaker.GetStates()}, nil
 }
 
 func (s *RegistryService) RegisterTaskCallbackSrv(ctx context.Context, req *RegisterTaskCallbackSrvReq) (*RegisterTaskCallbackSrvResp, error) {
 	routes := []*task.TaskCallbackSrvRoute{
-		task.NewTaskCallbackSrvRoute("", req.Schema, req.Host, req.Port, req.CallbackTimeoutSec, req.IsEnableHealthCheck),
+		task.NewTaskCallbackSrvRoute("", req.Schema, req.Host, req.Port, req.CallbackTimeoutSec, req.IsEnableHealthCheck, req.Weight, req.Labels),
+	}
+	headers := task.NewCallbackSrvHeaders(req.Headers, req.SecretHeaders)
+	if err := headers.Check(); err != nil {
+		logger.MustGetSessLogger().Error(ctx, err)
+		return nil, errs.NewBizErrWithMsg(errs.ErrCodeArgsInvalid, err.Error())
+	}
+	if req.ClearSecret && req.Secret != "" {
+		return nil, errs.NewBizErrWithMsg(errs.ErrCodeArgsInvalid, "secret and clear_secret can not be both set")
 	}
-	err := s.reg.Register(ctx, task.NewTaskCallbackSrv("", req.Name, routes, req.IsEnableHealthCheck))
+	srv := task.NewTaskCallbackSrv("", req.Name, routes, req.IsEnableHealthCheck, task.NewCallbackSrvLimit(req.MaxInFlight, req.MaxPerSec), req.StreamKey, req.Secret, headers, req.RouteStrategy, req.StreamMaxLen)
+	srv.SetClearSecret(req.ClearSecret)
+	err := s.reg.Register(ctx, srv)
 	if err != nil {
 		logger.MustGetSessLogger().Error(ctx, err)
 		return nil, err
@@ -110,10 +249,67 @@ func (s *RegistryService) UnregisterTaskCallbackSrv(ctx context.Context, req *Un
 		}
 	}
 
-	err = s.reg.Unregister(ctx, task.NewTaskCallbackSrv(srv.GetId(), srv.GetName(), readyDelRoutes, srv.HasEnableHealthCheckRoute()))
+	err = s.reg.Unregister(ctx, task.NewTaskCallbackSrv(srv.GetId(), srv.GetName(), readyDelRoutes, srv.HasEnableHealthCheckRoute(), srv.GetLimit(), srv.GetStreamKey(), srv.GetSecret(), srv.GetHeaders(), srv.GetRouteStrategy(), srv.GetStreamMaxLen()))
 	if err != nil {
 		logger.MustGetSessLogger().Error(ctx, err)
 		return nil, err
 	}
 	return &UnregisterTaskCallbackSrvResp{}, nil
-}
\ No newline at end of file
+}
+
+func NewWorkflowService(workflowRepo task.WorkflowRepo, taskRepo task.TaskRepo) *WorkflowService {
+	return &WorkflowService{
+		workflowRepo: workflowRepo,
+		taskRepo: taskRepo,
+	}
+}
+
+type WorkflowService struct {
+	workflowRepo task.WorkflowRepo
+	taskRepo task.TaskRepo
+}
+
+func (s *WorkflowService) AddWorkflow(ctx context.Context, req *AddWorkflowReq) (*AddWorkflowResp, error) {
+	workflow, err := task.NewWorkflow(&task.NewWorkflowReq{
+		Name: req.Name,
+		Nodes: req.Nodes,
+		Edges: req.Edges,
+	})
+	if err != nil {
+		logger.MustGetSessLogger().Error(ctx, err)
+		return nil, errs.NewBizErrWithMsg(errs.ErrCodeArgsInvalid, err.Error())
+	}
+
+	for _, node := range workflow.GetNodes() {
+		if _, err = s.taskRepo.GetTaskById(ctx, node.GetTaskId()); err != nil {
+			logger.MustGetSessLogger().Error(ctx, err)
+			return nil, err
+		}
+	}
+
+	workflowId, err := s.workflowRepo.SaveWorkflow(ctx, workflow)
+	if err != nil {
+		logger.MustGetSessLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	return &AddWorkflowResp{WorkflowId: workflowId}, nil
+}
+
+func (s *WorkflowService) StartWorkflow(ctx context.Context, req *StartWorkflowReq) (*StartWorkflowResp, error) {
+	workflowRunId, err := s.workflowRepo.StartWorkflow(ctx, req.WorkflowId)
+	if err != nil {
+		logger.MustGetSessLogger().Error(ctx, err)
+		return nil, err
+	}
+	return &StartWorkflowResp{WorkflowRunId: workflowRunId}, nil
+}
+
+func (s *WorkflowService) GetWorkflowRun(ctx context.Context, req *GetWorkflowRunReq) (*GetWorkflowRunResp, error) {
+	workflowRun, err := s.workflowRepo.GetWorkflowRunById(ctx, req.WorkflowRunId)
+	if err != nil {
+		logger.MustGetSessLogger().Error(ctx, err)
+		return nil, err
+	}
+	return &GetWorkflowRunResp{WorkflowRun: workflowRun}, nil
+}
@@ -27,6 +27,7 @@ type Registry struct {
 	callbackSrvExec           task.TaskCallbackSrvExec
 	readyCheckSrvChan         chan *task.TaskCallbackSrv
 	elect                     autoelect.AutoElection
+	circuitBreaker            *task.RouteCircuitBreaker
 	isPaused				  atomic.Bool
 	exitWorkerWait			  sync.WaitGroup
 	exitSchedSignCh			  chan struct{}
@@ -37,6 +38,7 @@ func NewRegistry(
 	srvRepo task.TaskCallbackSrvRepo,
 	callbackSrvExec task.TaskCallbackSrvExec,
 	elect autoelect.AutoElection,
+	circuitBreaker *task.RouteCircuitBreaker,
 	) *Registry {
 	if checkHealthWorkerPoolSize == 0 {
 		checkHealthWorkerPoolSize = DefaultCheckWorkerPoolSize
@@ -48,6 +50,7 @@ func NewRegistry(
 		callbackSrvExec: callbackSrvExec,
 		readyCheckSrvChan: make(chan *task.TaskCallbackSrv),
 		elect: elect,
+		circuitBreaker: circuitBreaker,
 		exitSchedSignCh: make(chan struct{}),
 	}
 }
@@ -131,6 +134,43 @@ func (r *Registry) HealthCheck(ctx context.Context) error {
 	return nil
 }
 
+// 去掉已经注销的节点的熔断状态,熔断状态保存在每个节点的内存中,所以每个节点都要清理
+func (r *Registry) pruneCircuitBreaker(ctx context.Context) error {
+	if r.circuitBreaker == nil {
+		return nil
+	}
+
+	var (
+		size, offset int64 = 1000, 0
+		routeIds = map[string]struct{}{}
+	)
+	queryStream := optionstream.NewQueryStream(nil, size, offset)
+	for {
+		srvs, err := r.srvRepo.GetSrvs(ctx, queryStream)
+		if err != nil {
+			logger.MustGetRegistryLogger().Error(ctx, err)
+			return err
+		}
+
+		for _, srv := range srvs {
+			for _, route := range srv.GetRoutes() {
+				routeIds[route.GetId()] = struct{}{}
+			}
+		}
+
+		if int64(len(srvs)) < size {
+			break
+		}
+
+		offset += size
+		queryStream.SetOffset(offset)
+	}
+
+	r.circuitBreaker.RetainRoutes(routeIds)
+
+	return nil
+}
+
 func (r *Registry) Run(ctx context.Context) {
 	go r.createHealthCheckWorkerPool(contxt.ChildOf(ctx))
 	r.sched(contxt.ChildOf(ctx))
@@ -168,6 +208,10 @@ func (r *Registry) sched(ctx context.Context) {
 
 		logger.MustGetRegistryLogger().Debug(ctx, "checked health")
 
+		if err := r.pruneCircuitBreaker(ctx); err != nil {
+			logger.MustGetRegistryLogger().Error(ctx, err)
+		}
+
 		time.Sleep(time.Duration(r.checkHealthIntervalSec) * time.Second)
 	}
 }
@@ -228,7 +272,7 @@ func (r *Registry) runWorker(ctx context.Context) {
 
 		replyRoutes := heatBeatResp.GetReplyRoutes()
 		if len(replyRoutes) > 0 {
-			withReplyRouteSrv := task.NewTaskCallbackSrv(srv.GetId(), srv.GetName(), replyRoutes, true)
+			withReplyRouteSrv := task.NewTaskCallbackSrv(srv.GetId(), srv.GetName(), replyRoutes, true, srv.GetLimit(), srv.GetStreamKey(), srv.GetSecret(), srv.GetHeaders(), srv.GetRouteStrategy(), srv.GetStreamMaxLen())
 			if err := r.srvRepo.SetSrvRoutesPassHealthCheck(ctx, withReplyRouteSrv); err != nil {
 				logger.MustGetRegistryLogger().Error(ctx, err)
 			}
@@ -236,7 +280,7 @@ func (r *Registry) runWorker(ctx context.Context) {
 
 		noReplyRoutes := heatBeatResp.GetNoReplyRoutes()
 		if len(noReplyRoutes) > 0 {
-			withNoReplyRouteSrv := task.NewTaskCallbackSrv(srv.GetId(), srv.GetName(), noReplyRoutes, true)
+			withNoReplyRouteSrv := task.NewTaskCallbackSrv(srv.GetId(), srv.GetName(), noReplyRoutes, true, srv.GetLimit(), srv.GetStreamKey(), srv.GetSecret(), srv.GetHeaders(), srv.GetRouteStrategy(), srv.GetStreamMaxLen())
 			if err := r.srvRepo.DelSrvRoutes(ctx, withNoReplyRouteSrv); err != nil {
 				logger.MustGetRegistryLogger().Error(ctx, err)
 			}
@@ -0,0 +1,99 @@
+package task
+
+import "errors"
+
+type DispatchMode int
+
+const (
+	// 随机选择一个回调节点执行(默认)
+	DispatchModeSingle DispatchMode = iota
+	// 并发回调所有健康的节点,每个节点的执行记为一个子执行
+	DispatchModeBroadcast
+)
+
+func (m DispatchMode) check() error {
+	switch m {
+	case DispatchModeSingle, DispatchModeBroadcast:
+		return nil
+	}
+	return errors.New("invalid dispatch mode")
+}
+
+type BroadcastSuccessRule int
+
+const (
+	// 所有子执行成功才算成功(默认)
+	BroadcastSuccessRuleAll BroadcastSuccessRule = iota
+	// 任意一个子执行成功就算成功
+	BroadcastSuccessRuleAny
+)
+
+func (r BroadcastSuccessRule) check() error {
+	switch r {
+	case BroadcastSuccessRuleAll, BroadcastSuccessRuleAny:
+		return nil
+	}
+	return errors.New("invalid broadcast success rule")
+}
+
+// 按规则汇总子执行的状态,结果还不能确定时返回StatusRunning
+func AggregateSubRunStatus(rule BroadcastSuccessRule, subRunStatuses []Status) Status {
+	if len(subRunStatuses) == 0 {
+		return StatusFailed
+	}
+
+	var successNum, failedNum int
+	for _, status := range subRunStatuses {
+		if IsTaskSuccess(status) {
+			successNum++
+		} else if IsTaskFailed(status) {
+			failedNum++
+		}
+	}
+
+	switch rule {
+	case BroadcastSuccessRuleAny:
+		if successNum > 0 {
+			return StatusSuccess
+		}
+		if failedNum == len(subRunStatuses) {
+			return StatusFailed
+		}
+	default:
+		if failedNum > 0 {
+			return StatusFailed
+		}
+		if successNum == len(subRunStatuses) {
+			return StatusSuccess
+		}
+	}
+
+	return StatusRunning
+}
+
+type TaskSubRunsStartedLogDetail struct {
+	taskId string
+	runTimes int
+	routes []*TaskCallbackSrvRoute
+}
+
+func (d *TaskSubRunsStartedLogDetail) GetTaskId() string {
+	return d.taskId
+}
+
+func (d *TaskSubRunsStartedLogDetail) GetRunTimes() int {
+	return d.runTimes
+}
+
+// 第i个路由对应第i+1个子执行
+func (d *TaskSubRunsStartedLogDetail) GetRoutes() []*TaskCallbackSrvRoute {
+	return d.routes
+}
+
+func NewTaskSubRunsStartedLogDetail(taskId string, runTimes int, routes []*TaskCallbackSrvRoute) *TaskSubRunsStartedLogDetail {
+	return &TaskSubRunsStartedLogDetail{
+		taskId: taskId,
+		runTimes: runTimes,
+		routes: routes,
+	}
+}
@@ -0,0 +1,24 @@
+package task
+
+import "testing"
+
+func TestAggregateSubRunStatus(t *testing.T) {
+	cases := []struct {
+		rule BroadcastSuccessRule
+		subRunStatuses []Status
+		expect Status
+	}{
+		{BroadcastSuccessRuleAll, nil, StatusFailed},
+		{BroadcastSuccessRuleAll, []Status{StatusSuccess, StatusSuccess}, StatusSuccess},
+		{BroadcastSuccessRuleAll, []Status{StatusSuccess, StatusRunning}, StatusRunning},
+		{BroadcastSuccessRuleAll, []Status{StatusRunning, StatusFailed}, StatusFailed},
+		{BroadcastSuccessRuleAny, []Status{StatusFailed, StatusSuccess}, StatusSuccess},
+		{BroadcastSuccessRuleAny, []Status{StatusFailed, StatusRunning}, StatusRunning},
+		{BroadcastSuccessRuleAny, []Status{StatusFailed, StatusFailed}, StatusFailed},
+	}
+	for i, c := range cases {
+		if status := AggregateSubRunStatus(c.rule, c.subRunStatuses); status != c.expect {
+			t.Errorf("case %d: expect status %d, got %d", i, c.expect, status)
+		}
+	}
+}
@@ -7,6 +7,10 @@ import (
 type TaskCallbackSrvExec interface {
 	CallbackSrv(ctx context.Context, task *Task, extra interface{}) (*TaskCallbackSrvResp, error)
 	HeartBeat(context.Context, *TaskCallbackSrv) (*HeartBeatResp, error)
+	// 通知执行任务的节点取消一次执行
+	CancelRun(ctx context.Context, task *Task, runTimes int, routes []*TaskCallbackSrvRoute) error
+	// 异步执行确认或超时后释放回调时选中的节点
+	ReleaseRun(taskId string, runTimes int)
 }
 
 func NewCallbackSrvResp(isRunInAsync, isSuccess bool, extra string) *TaskCallbackSrvResp {
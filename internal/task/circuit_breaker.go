@@ -0,0 +1,247 @@
+package task
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type CircuitBreakerState int
+
+const (
+	// 正常回调
+	CircuitBreakerStateClosed CircuitBreakerState = iota
+	// 失败率过高,冷却时间内不再选择该节点
+	CircuitBreakerStateOpen
+	// 冷却时间结束,放行回调试探节点是否恢复,成功则关闭,失败则重新打开
+	CircuitBreakerStateHalfOpen
+)
+
+const (
+	defaultCircuitBreakerWindowSize = 20
+	defaultCircuitBreakerMinCalls = 5
+	defaultCircuitBreakerFailureRateThreshold = 0.5
+	defaultCircuitBreakerCoolDownSec = 30
+)
+
+type routeBreaker struct {
+	route *TaskCallbackSrvRoute
+	state CircuitBreakerState
+	// 最近windowSize次回调是否失败,循环写入
+	results []bool
+	resultCursor int
+	openedAt time.Time
+	// 半开时是否已经放行了试探回调,试探结果记录前不再放行其他回调
+	isProbing bool
+}
+
+func (b *routeBreaker) failures() int {
+	var failures int
+	for _, isFailure := range b.results {
+		if isFailure {
+			failures++
+		}
+	}
+	return failures
+}
+
+// 熔断器在某个节点上的状态快照
+type RouteCircuitBreakerState struct {
+	route *TaskCallbackSrvRoute
+	state CircuitBreakerState
+	calls int
+	failures int
+	openedAt int64
+}
+
+func (s *RouteCircuitBreakerState) GetRoute() *TaskCallbackSrvRoute {
+	return s.route
+}
+
+func (s *RouteCircuitBreakerState) GetState() CircuitBreakerState {
+	return s.state
+}
+
+// 统计窗口内的回调次数
+func (s *RouteCircuitBreakerState) GetCalls() int {
+	return s.calls
+}
+
+func (s *RouteCircuitBreakerState) GetFailures() int {
+	return s.failures
+}
+
+func (s *RouteCircuitBreakerState) GetOpenedAt() int64 {
+	return s.openedAt
+}
+
+// 按节点统计最近的回调结果,失败率超过阈值时熔断节点.状态只保存在当前调度节点的内存中
+type RouteCircuitBreaker struct {
+	mu sync.Mutex
+	windowSize int
+	minCalls int
+	failureRateThreshold float64
+	coolDown time.Duration
+	breakers map[string]*routeBreaker
+	now func() time.Time
+}
+
+// 参数小于等于0时使用默认值:窗口20次,至少5次回调才计算失败率,失败率阈值0.5,冷却30秒
+func NewRouteCircuitBreaker(windowSize, minCalls int, failureRateThreshold float64, coolDownSec int) *RouteCircuitBreaker {
+	if windowSize <= 0 {
+		windowSize = defaultCircuitBreakerWindowSize
+	}
+	if minCalls <= 0 {
+		minCalls = defaultCircuitBreakerMinCalls
+	}
+	if minCalls > windowSize {
+		minCalls = windowSize
+	}
+	if failureRateThreshold <= 0 || failureRateThreshold > 1 {
+		failureRateThreshold = defaultCircuitBreakerFailureRateThreshold
+	}
+	if coolDownSec <= 0 {
+		coolDownSec = defaultCircuitBreakerCoolDownSec
+	}
+	return &RouteCircuitBreaker{
+		windowSize: windowSize,
+		minCalls: minCalls,
+		failureRateThreshold: failureRateThreshold,
+		coolDown: time.Duration(coolDownSec) * time.Second,
+		breakers: map[string]*routeBreaker{},
+		now: time.Now,
+	}
+}
+
+// 过滤掉熔断中的节点和已经在试探的半开节点,冷却时间已过的节点转为半开放行
+func (c *RouteCircuitBreaker) FilterAllowedRoutes(routes []*TaskCallbackSrvRoute) []*TaskCallbackSrvRoute {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var allowedRoutes []*TaskCallbackSrvRoute
+	for _, route := range routes {
+		breaker, ok := c.breakers[route.GetId()]
+		if ok && breaker.state == CircuitBreakerStateOpen {
+			if c.now().Sub(breaker.openedAt) < c.coolDown {
+				continue
+			}
+			breaker.state = CircuitBreakerStateHalfOpen
+		}
+		if ok && breaker.state == CircuitBreakerStateHalfOpen && breaker.isProbing {
+			continue
+		}
+		allowedRoutes = append(allowedRoutes, route)
+	}
+	return allowedRoutes
+}
+
+// 回调节点前调用,返回false代表不能回调该节点.半开的节点同时只放行一次试探回调,试探结果由Record记录
+func (c *RouteCircuitBreaker) AllowCall(route *TaskCallbackSrvRoute) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	breaker, ok := c.breakers[route.GetId()]
+	if !ok {
+		return true
+	}
+
+	switch breaker.state {
+	case CircuitBreakerStateOpen:
+		if c.now().Sub(breaker.openedAt) < c.coolDown {
+			return false
+		}
+		breaker.state = CircuitBreakerStateHalfOpen
+	case CircuitBreakerStateClosed:
+		return true
+	}
+
+	if breaker.isProbing {
+		return false
+	}
+	breaker.isProbing = true
+	return true
+}
+
+// 只保留仍然注册着的节点的熔断状态,已经注销的节点不再统计
+func (c *RouteCircuitBreaker) RetainRoutes(routeIds map[string]struct{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for routeId := range c.breakers {
+		if _, ok := routeIds[routeId]; !ok {
+			delete(c.breakers, routeId)
+		}
+	}
+}
+
+// 记录一次回调结果,isFailure代表没有拿到节点的正常响应,业务上的失败不算
+func (c *RouteCircuitBreaker) Record(route *TaskCallbackSrvRoute, isFailure bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	breaker, ok := c.breakers[route.GetId()]
+	if !ok {
+		breaker = &routeBreaker{}
+		c.breakers[route.GetId()] = breaker
+	}
+	breaker.route = route
+
+	switch breaker.state {
+	case CircuitBreakerStateHalfOpen:
+		breaker.isProbing = false
+		if isFailure {
+			breaker.state = CircuitBreakerStateOpen
+			breaker.openedAt = c.now()
+			return
+		}
+		breaker.state = CircuitBreakerStateClosed
+		breaker.results = nil
+		breaker.resultCursor = 0
+		return
+	case CircuitBreakerStateOpen:
+		// 熔断前已经发出的回调,结果不影响状态
+		return
+	}
+
+	if len(breaker.results) < c.windowSize {
+		breaker.results = append(breaker.results, isFailure)
+	} else {
+		breaker.results[breaker.resultCursor] = isFailure
+		breaker.resultCursor = (breaker.resultCursor + 1) % c.windowSize
+	}
+
+	if len(breaker.results) < c.minCalls {
+		return
+	}
+
+	if float64(breaker.failures()) / float64(len(breaker.results)) >= c.failureRateThreshold {
+		breaker.state = CircuitBreakerStateOpen
+		breaker.openedAt = c.now()
+		breaker.results = nil
+		breaker.resultCursor = 0
+	}
+}
+
+// 所有回调过的节点的熔断状态,按节点id排序
+func (c *RouteCircuitBreaker) GetStates() []*RouteCircuitBreakerState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var states []*RouteCircuitBreakerState
+	for _, breaker := range c.breakers {
+		state := &RouteCircuitBreakerState{
+			route: breaker.route,
+			state: breaker.state,
+			calls: len(breaker.results),
+			failures: breaker.failures(),
+		}
+		if breaker.state != CircuitBreakerStateClosed {
+			state.openedAt = breaker.openedAt.Unix()
+		}
+		states = append(states, state)
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].route.GetId() < states[j].route.GetId()
+	})
+	return states
+}
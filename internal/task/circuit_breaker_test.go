@@ -0,0 +1,94 @@
+package task
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRouteCircuitBreaker(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	breaker := NewRouteCircuitBreaker(4, 4, 0.5, 10)
+	breaker.now = func() time.Time {
+		return now
+	}
+
+	routes := newTestRoutes(2)
+	flapping := routes[0]
+
+	breaker.Record(flapping, true)
+	breaker.Record(flapping, false)
+	breaker.Record(flapping, true)
+	if allowed := breaker.FilterAllowedRoutes(routes); len(allowed) != 2 {
+		t.Fatalf("expect breaker closed before min calls, got %d allowed routes", len(allowed))
+	}
+
+	breaker.Record(flapping, false)
+	allowed := breaker.FilterAllowedRoutes(routes)
+	if len(allowed) != 1 || allowed[0] != routes[1] {
+		t.Fatalf("expect flapping route tripped, got %d allowed routes", len(allowed))
+	}
+	if states := breaker.GetStates(); len(states) != 1 || states[0].GetState() != CircuitBreakerStateOpen || states[0].GetOpenedAt() != now.Unix() {
+		t.Fatalf("expect one open breaker opened at %d", now.Unix())
+	}
+
+	// 冷却后半开,试探失败重新熔断
+	now = now.Add(10 * time.Second)
+	if allowed := breaker.FilterAllowedRoutes(routes); len(allowed) != 2 {
+		t.Fatalf("expect half open route allowed after cool down, got %d allowed routes", len(allowed))
+	}
+	breaker.Record(flapping, true)
+	if allowed := breaker.FilterAllowedRoutes(routes); len(allowed) != 1 {
+		t.Fatalf("expect route tripped again after failed probe, got %d allowed routes", len(allowed))
+	}
+
+	// 冷却后试探成功关闭熔断
+	now = now.Add(10 * time.Second)
+	breaker.FilterAllowedRoutes(routes)
+	breaker.Record(flapping, false)
+	if states := breaker.GetStates(); states[0].GetState() != CircuitBreakerStateClosed || states[0].GetCalls() != 0 {
+		t.Errorf("expect breaker closed with reset window, got state %d calls %d", states[0].GetState(), states[0].GetCalls())
+	}
+}
+
+func TestRouteCircuitBreakerSingleProbe(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	breaker := NewRouteCircuitBreaker(2, 2, 0.5, 10)
+	breaker.now = func() time.Time {
+		return now
+	}
+
+	routes := newTestRoutes(2)
+	flapping := routes[0]
+	breaker.Record(flapping, true)
+	breaker.Record(flapping, true)
+
+	// 冷却后只放行一次试探回调,试探结果记录前其他回调不再选择该节点
+	now = now.Add(10 * time.Second)
+	if !breaker.AllowCall(flapping) {
+		t.Fatal("expect probe allowed after cool down")
+	}
+	if breaker.AllowCall(flapping) {
+		t.Error("expect second probe rejected while first probe in flight")
+	}
+	if allowed := breaker.FilterAllowedRoutes(routes); len(allowed) != 1 || allowed[0] != routes[1] {
+		t.Errorf("expect probing route filtered, got %d allowed routes", len(allowed))
+	}
+
+	breaker.Record(flapping, false)
+	if !breaker.AllowCall(flapping) || !breaker.AllowCall(flapping) {
+		t.Error("expect calls allowed after successful probe")
+	}
+}
+
+func TestRouteCircuitBreakerRetainRoutes(t *testing.T) {
+	breaker := NewRouteCircuitBreaker(0, 0, 0, 0)
+	routes := newTestRoutes(2)
+	breaker.Record(routes[0], false)
+	breaker.Record(routes[1], true)
+
+	// 节点2已经注销
+	breaker.RetainRoutes(map[string]struct{}{routes[0].GetId(): {}})
+	if states := breaker.GetStates(); len(states) != 1 || states[0].GetRoute() != routes[0] {
+		t.Errorf("expect only registered route reported, got %d states", len(states))
+	}
+}
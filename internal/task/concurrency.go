@@ -0,0 +1,50 @@
+package task
+
+import "errors"
+
+type ConcurrencyPolicy int
+
+const (
+	// 允许上次执行未结束时开始下一次执行(默认)
+	ConcurrencyPolicyAllow ConcurrencyPolicy = iota
+	// 上次执行未结束时跳过本次触发
+	ConcurrencyPolicyForbid
+	// 上次执行未结束时本次触发排队,等上次执行结束后再执行
+	ConcurrencyPolicyQueue
+)
+
+func (p ConcurrencyPolicy) check() error {
+	switch p {
+	case ConcurrencyPolicyAllow, ConcurrencyPolicyForbid, ConcurrencyPolicyQueue:
+		return nil
+	}
+	return errors.New("invalid concurrency policy")
+}
+
+type SkipReason int
+
+const (
+	SkipReasonNil SkipReason = iota
+	// 错过触发策略决定跳过
+	SkipReasonMisfire
+	// 上次执行未结束,并发策略禁止并发
+	SkipReasonConcurrencyForbidden
+)
+
+// 调度计划触发时按并发策略处理上次还没结束的执行,返回false代表本次不锁定任务,等上次执行结束后再触发.
+// 需要先调用DecideSched.触发队列中的任务不经过这里,其中调度计划触发的执行的重试由仓储锁定触发时按并发策略处理
+func (t *Task) ResolveConcurrency(isLastRunRunning bool) bool {
+	if !isLastRunRunning || t.trigger != nil || t.schedDecision == nil || t.schedDecision.IsSkipped() {
+		return true
+	}
+
+	switch t.concurrencyPolicy {
+	case ConcurrencyPolicyForbid:
+		t.schedDecision.skipReason = SkipReasonConcurrencyForbidden
+	case ConcurrencyPolicyQueue:
+		t.schedDecision.isQueued = true
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,140 @@
+package task
+
+import (
+	"github.com/gorhill/cronexpr"
+	"strings"
+	"sync"
+	"time"
+)
+
+var locationCache sync.Map
+
+func loadLocation(timeZone string) (*time.Location, error) {
+	if timeZone == "" {
+		return time.Local, nil
+	}
+	if loc, ok := locationCache.Load(timeZone); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return nil, err
+	}
+	locationCache.Store(timeZone, loc)
+	return loc, nil
+}
+
+func CheckTimeZone(timeZone string) error {
+	_, err := loadLocation(timeZone)
+	return err
+}
+
+// 小时字段是否固定(具体的小时、列表或范围),通配或者按步长的小时不算固定
+func isCronHourFixed(cronExpr string) bool {
+	fields := strings.Fields(cronExpr)
+	switch len(fields) {
+	case 1:
+		return fields[0] != "@hourly"
+	case 5, 6:
+		return !strings.ContainsAny(fields[1], "*/")
+	case 7:
+		return !strings.ContainsAny(fields[2], "*/")
+	}
+	return true
+}
+
+// cronexpr直接在有夏令时的时区计算会出错(跳过的时间段内会返回更早的时间并原地打转),
+// 所以先把时间转成墙上时间放到UTC里计算,再映射回目标时区:
+// 1.夏令时跳过的墙上时间,在跳变结束的那一刻触发(同一段跳过的时间只触发一次);
+// 2.夏令时回拨导致重复的墙上时间,小时固定的表达式只在第一次出现时触发,避免同一天触发两次;
+// 通配或者按步长的小时保持正常的触发间隔,重复的墙上时间两次出现都触发。
+func nextCronAt(expr *cronexpr.Expression, isHourFixed bool, from time.Time, loc *time.Location) time.Time {
+	wall := toWallClock(from.In(loc))
+	// from处于回拨前第一次出现的时间段时,回拨后第二次出现的时间可能对应更早的墙上时间,从重复时间段的开头找
+	if !isHourFixed {
+		if overlapWall, ok := dstOverlapWallStart(from, loc); ok && overlapWall.Before(wall) {
+			wall = overlapWall.Add(-time.Second)
+		}
+	}
+
+	var next time.Time
+	for {
+		wall = expr.Next(wall)
+		if wall.IsZero() {
+			return next
+		}
+		if !isHourFixed {
+			if later := laterWallClockIn(wall, loc); later.After(from) && (next.IsZero() || later.Before(next)) {
+				next = later
+			}
+		}
+		if at := wallClockIn(wall, loc); at.After(from) {
+			if next.IsZero() || at.Before(next) {
+				next = at
+			}
+			return next
+		}
+	}
+}
+
+// from所在时区段结束时回拨,且from处于回拨前会重复的时间段内时,返回重复时间段开始的墙上时间
+func dstOverlapWallStart(from time.Time, loc *time.Location) (time.Time, bool) {
+	t := from.In(loc)
+	_, end := t.ZoneBounds()
+	if end.IsZero() {
+		return time.Time{}, false
+	}
+	_, offset := t.Zone()
+	_, nextOffset := end.Zone()
+	if nextOffset >= offset || end.Sub(from) > time.Duration(offset - nextOffset) * time.Second {
+		return time.Time{}, false
+	}
+	return toWallClock(end.In(loc)), true
+}
+
+func toWallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+func wallClockIn(wall time.Time, loc *time.Location) time.Time {
+	t := time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), wall.Nanosecond(), loc)
+	start, end := t.ZoneBounds()
+
+	switch tWall := toWallClock(t); {
+	case tWall.After(wall):
+		return start
+	case tWall.Before(wall):
+		return end
+	}
+
+	if start.IsZero() {
+		return t
+	}
+	_, offset := t.Zone()
+	_, prevOffset := start.Add(-time.Second).Zone()
+	if prevOffset <= offset {
+		return t
+	}
+	if earlier := t.Add(-time.Duration(prevOffset - offset) * time.Second); earlier.Before(start) && toWallClock(earlier.In(loc)).Equal(wall) {
+		return earlier
+	}
+	return t
+}
+
+// 夏令时回拨导致重复的墙上时间返回第二次出现的时间,其他情况同wallClockIn
+func laterWallClockIn(wall time.Time, loc *time.Location) time.Time {
+	t := wallClockIn(wall, loc)
+	_, end := t.ZoneBounds()
+	if end.IsZero() {
+		return t
+	}
+	_, offset := t.Zone()
+	_, nextOffset := end.Zone()
+	if nextOffset >= offset {
+		return t
+	}
+	if later := t.Add(time.Duration(offset - nextOffset) * time.Second); !later.Before(end) && toWallClock(later.In(loc)).Equal(wall) {
+		return later
+	}
+	return t
+}
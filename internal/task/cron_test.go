@@ -0,0 +1,129 @@
+package task
+
+import (
+	"github.com/gorhill/cronexpr"
+	"testing"
+	"time"
+)
+
+func mustLoadLocation(t *testing.T, timeZone string) *time.Location {
+	loc, err := loadLocation(timeZone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestNextCronAtInTimeZone(t *testing.T) {
+	tokyo := mustLoadLocation(t, "Asia/Tokyo")
+	expr := cronexpr.MustParse("0 9 * * *")
+
+	from := time.Date(2023, 6, 1, 10, 0, 0, 0, time.UTC)
+	next := nextCronAt(expr, true, from, tokyo)
+	if expect := time.Date(2023, 6, 2, 9, 0, 0, 0, tokyo); !next.Equal(expect) {
+		t.Errorf("expect %s, got %s", expect, next)
+	}
+}
+
+func TestNextCronAtDstGap(t *testing.T) {
+	newYork := mustLoadLocation(t, "America/New_York")
+
+	// 2023-03-12 02:00 EST跳到03:00 EDT, 02:30不存在
+	daily := cronexpr.MustParse("30 2 * * *")
+	next := nextCronAt(daily, true, time.Date(2023, 3, 11, 12, 0, 0, 0, newYork), newYork)
+	if expect := time.Date(2023, 3, 12, 7, 0, 0, 0, time.UTC); !next.Equal(expect) {
+		t.Errorf("expect fire at end of gap %s, got %s", expect, next.UTC())
+	}
+	next = nextCronAt(daily, true, next, newYork)
+	if expect := time.Date(2023, 3, 13, 2, 30, 0, 0, newYork); !next.Equal(expect) {
+		t.Errorf("expect %s, got %s", expect, next)
+	}
+
+	quarterly := cronexpr.MustParse("*/15 * * * *")
+	at := time.Date(2023, 3, 12, 1, 40, 0, 0, newYork)
+	var fires []time.Time
+	for i := 0; i < 4; i++ {
+		at = nextCronAt(quarterly, false, at, newYork)
+		fires = append(fires, at)
+	}
+	expects := []time.Time{
+		time.Date(2023, 3, 12, 6, 45, 0, 0, time.UTC),
+		time.Date(2023, 3, 12, 7, 0, 0, 0, time.UTC),
+		time.Date(2023, 3, 12, 7, 15, 0, 0, time.UTC),
+		time.Date(2023, 3, 12, 7, 30, 0, 0, time.UTC),
+	}
+	for i, expect := range expects {
+		if !fires[i].Equal(expect) {
+			t.Errorf("fire %d expect %s, got %s", i, expect, fires[i].UTC())
+		}
+	}
+}
+
+func TestNextCronAtDstOverlap(t *testing.T) {
+	newYork := mustLoadLocation(t, "America/New_York")
+
+	// 2023-11-05 02:00 EDT回拨到01:00 EST, 01:00~02:00出现两次
+	daily := cronexpr.MustParse("30 1 * * *")
+	next := nextCronAt(daily, true, time.Date(2023, 11, 4, 12, 0, 0, 0, newYork), newYork)
+	if expect := time.Date(2023, 11, 5, 5, 30, 0, 0, time.UTC); !next.Equal(expect) {
+		t.Errorf("expect first occurrence %s, got %s", expect, next.UTC())
+	}
+	next = nextCronAt(daily, true, next, newYork)
+	if expect := time.Date(2023, 11, 6, 1, 30, 0, 0, newYork); !next.Equal(expect) {
+		t.Errorf("expect %s, got %s", expect, next)
+	}
+
+	// 通配的小时保持正常的触发间隔,重复的墙上时间第二次出现也触发
+	from := time.Date(2023, 11, 5, 6, 10, 0, 0, time.UTC)
+	hourly := cronexpr.MustParse("30 * * * *")
+	if next = nextCronAt(hourly, false, from, newYork); !next.Equal(time.Date(2023, 11, 5, 6, 30, 0, 0, time.UTC)) {
+		t.Errorf("expect 01:30 EST, got %s", next.In(newYork))
+	}
+}
+
+func TestNextCronAtDstOverlapStepped(t *testing.T) {
+	newYork := mustLoadLocation(t, "America/New_York")
+
+	// 2022-11-06 02:00 EDT回拨到01:00 EST,按步长的表达式在重复的一个小时内照常每15分钟触发
+	quarterly := cronexpr.MustParse("*/15 * * * *")
+	at := time.Date(2022, 11, 6, 1, 40, 0, 0, newYork)
+	var fires []time.Time
+	for i := 0; i < 10; i++ {
+		at = nextCronAt(quarterly, false, at, newYork)
+		fires = append(fires, at)
+	}
+	for i, fire := range fires {
+		if expect := time.Date(2022, 11, 6, 5, 45 + 15 * i, 0, 0, time.UTC); !fire.Equal(expect) {
+			t.Errorf("fire %d expect %s, got %s", i, expect, fire.UTC())
+		}
+	}
+}
+
+func TestIsCronHourFixed(t *testing.T) {
+	for cronExpr, expect := range map[string]bool{
+		"30 1 * * *": true,
+		"0 1,13 * * *": true,
+		"0 1-3 * * *": true,
+		"*/15 * * * *": false,
+		"0 */2 * * *": false,
+		"0 0 1 * * * *": true,
+		"0 0 * * * * *": false,
+		"@daily": true,
+		"@hourly": false,
+	} {
+		if isCronHourFixed(cronExpr) != expect {
+			t.Errorf("%s: expect hour fixed %v", cronExpr, expect)
+		}
+	}
+}
+
+func TestGetSchedNextAtInvalidTimeZone(t *testing.T) {
+	oneTask := &Task{
+		schedMode: SchedModeTimeCron,
+		timeCronExpr: "* * * * *",
+		timeZone: "Mars/Olympus_Mons",
+	}
+	if _, err := oneTask.GetSchedNextAt(); err == nil {
+		t.Error("expect error for invalid time zone")
+	}
+}
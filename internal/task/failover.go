@@ -0,0 +1,48 @@
+package task
+
+// 一次回调尝试,传输层失败后换节点重试时每个节点记为一次尝试
+type TaskCallbackAttempt struct {
+	route *TaskCallbackSrvRoute
+	err error
+	callbackAt int64
+}
+
+func (a *TaskCallbackAttempt) GetRoute() *TaskCallbackSrvRoute {
+	return a.route
+}
+
+func (a *TaskCallbackAttempt) GetErr() error {
+	return a.err
+}
+
+func (a *TaskCallbackAttempt) GetCallbackAt() int64 {
+	return a.callbackAt
+}
+
+func NewTaskCallbackAttempt(route *TaskCallbackSrvRoute, err error, callbackAt int64) *TaskCallbackAttempt {
+	return &TaskCallbackAttempt{
+		route: route,
+		err: err,
+		callbackAt: callbackAt,
+	}
+}
+
+// 回调在传输层失败(连接失败,超时等)时最多换几个节点重试,业务上的失败不重试
+func (t *Task) GetFailoverMaxAttempts() int {
+	return t.failoverMaxAttempts
+}
+
+// 还没有尝试过的匹配标签选择器的路由
+func (t *Task) GetUntriedCallbackRoutes(attempts []*TaskCallbackAttempt) []*TaskCallbackSrvRoute {
+	triedRouteIds := map[string]bool{}
+	for _, attempt := range attempts {
+		triedRouteIds[attempt.route.GetId()] = true
+	}
+	var routes []*TaskCallbackSrvRoute
+	for _, route := range t.GetCallbackRoutes() {
+		if !triedRouteIds[route.GetId()] {
+			routes = append(routes, route)
+		}
+	}
+	return routes
+}
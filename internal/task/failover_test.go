@@ -0,0 +1,33 @@
+package task
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestGetUntriedCallbackRoutes(t *testing.T) {
+	routes := []*TaskCallbackSrvRoute{
+		NewTaskCallbackSrvRoute("1", "http", "127.0.0.1", 8000, 0, false, 0, map[string]string{"zone": "a"}),
+		NewTaskCallbackSrvRoute("2", "http", "127.0.0.1", 8001, 0, false, 0, map[string]string{"zone": "a"}),
+		NewTaskCallbackSrvRoute("3", "http", "127.0.0.1", 8002, 0, false, 0, map[string]string{"zone": "b"}),
+	}
+	oneTask := &Task{
+		callbackSrv: NewTaskCallbackSrv("1", "srv", routes, false, nil, "", "", nil, RouteStrategyRandom, 0),
+		labelSelector: map[string]string{"zone": "a"},
+	}
+
+	untried := oneTask.GetUntriedCallbackRoutes([]*TaskCallbackAttempt{
+		NewTaskCallbackAttempt(routes[0], errors.New("connection refused"), 1700000000),
+	})
+	if len(untried) != 1 || untried[0] != routes[1] {
+		t.Fatalf("expect only route 2 untried, got %d routes", len(untried))
+	}
+
+	untried = oneTask.GetUntriedCallbackRoutes([]*TaskCallbackAttempt{
+		NewTaskCallbackAttempt(routes[0], errors.New("connection refused"), 1700000000),
+		NewTaskCallbackAttempt(routes[1], errors.New("timeout"), 1700000001),
+	})
+	if len(untried) != 0 {
+		t.Errorf("expect no untried route, got %d routes", len(untried))
+	}
+}
@@ -0,0 +1,147 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// 回调http节点的请求方法,为空时为POST
+type CallbackMethod string
+
+const (
+	CallbackMethodNil CallbackMethod = ""
+	// 回调参数按query string传递,没有请求体
+	CallbackMethodGet CallbackMethod = http.MethodGet
+	CallbackMethodPost CallbackMethod = http.MethodPost
+	CallbackMethodPut CallbackMethod = http.MethodPut
+)
+
+func (m CallbackMethod) check() error {
+	switch m {
+	case CallbackMethodNil, CallbackMethodGet, CallbackMethodPost, CallbackMethodPut:
+		return nil
+	}
+	return errors.New("invalid callback method")
+}
+
+// 回调http节点POST或PUT时请求体的格式,为空时为json
+type CallbackContentType string
+
+const (
+	CallbackContentTypeNil CallbackContentType = ""
+	CallbackContentTypeJson CallbackContentType = "application/json"
+	CallbackContentTypeForm CallbackContentType = "application/x-www-form-urlencoded"
+)
+
+func (t CallbackContentType) check() error {
+	switch t {
+	case CallbackContentTypeNil, CallbackContentTypeJson, CallbackContentTypeForm:
+		return nil
+	}
+	return errors.New("invalid callback content type")
+}
+
+// 回调日志中代替密钥header值的内容
+const RedactedHeaderValue = "******"
+
+// 由easytask设置的header(签名,链路追踪等都以x-easy-task-开头),注册服务时不能指定
+const reservedCallbackHeaderPrefix = "x-easy-task-"
+
+var reservedCallbackHeaders = map[string]struct{}{
+	"content-type": {},
+	"content-length": {},
+	"host": {},
+}
+
+// 回调服务注册的静态header,回调和心跳http节点时都会带上.
+// secretHeaders用于token,api key等密钥,加密存储并且在回调日志中隐藏
+type CallbackSrvHeaders struct {
+	headers map[string]string
+	secretHeaders map[string]string
+}
+
+func (h *CallbackSrvHeaders) GetHeaders() map[string]string {
+	if h == nil {
+		return nil
+	}
+	return h.headers
+}
+
+func (h *CallbackSrvHeaders) GetSecretHeaders() map[string]string {
+	if h == nil {
+		return nil
+	}
+	return h.secretHeaders
+}
+
+// 所有的header,回调请求时使用
+func (h *CallbackSrvHeaders) GetAll() map[string]string {
+	if h == nil {
+		return nil
+	}
+	all := make(map[string]string, len(h.headers) + len(h.secretHeaders))
+	for name, value := range h.headers {
+		all[name] = value
+	}
+	for name, value := range h.secretHeaders {
+		all[name] = value
+	}
+	return all
+}
+
+// 密钥header的值替换为******,用于记录回调日志
+func (h *CallbackSrvHeaders) GetRedacted() map[string]string {
+	if h == nil || (len(h.headers) == 0 && len(h.secretHeaders) == 0) {
+		return nil
+	}
+	redacted := make(map[string]string, len(h.headers) + len(h.secretHeaders))
+	for name, value := range h.headers {
+		redacted[name] = value
+	}
+	for name := range h.secretHeaders {
+		redacted[name] = RedactedHeaderValue
+	}
+	return redacted
+}
+
+func (h *CallbackSrvHeaders) Check() error {
+	if h == nil {
+		return nil
+	}
+	for name := range h.headers {
+		if err := checkCallbackHeaderName(name); err != nil {
+			return err
+		}
+	}
+	for name := range h.secretHeaders {
+		if err := checkCallbackHeaderName(name); err != nil {
+			return err
+		}
+		for plainName := range h.headers {
+			if strings.EqualFold(plainName, name) {
+				return fmt.Errorf("header %s can not be both plain and secret", name)
+			}
+		}
+	}
+	return nil
+}
+
+func checkCallbackHeaderName(name string) error {
+	if name == "" || strings.ContainsAny(name, " \t\r\n:") {
+		return fmt.Errorf("invalid header name %q", name)
+	}
+	lowerName := strings.ToLower(name)
+	if _, ok := reservedCallbackHeaders[lowerName]; ok || strings.HasPrefix(lowerName, reservedCallbackHeaderPrefix) {
+		return fmt.Errorf("header %s is reserved", name)
+	}
+	return nil
+}
+
+func NewCallbackSrvHeaders(headers, secretHeaders map[string]string) *CallbackSrvHeaders {
+	return &CallbackSrvHeaders{
+		headers: headers,
+		secretHeaders: secretHeaders,
+	}
+}
@@ -0,0 +1,68 @@
+package task
+
+import "testing"
+
+func TestCallbackSrvHeaders(t *testing.T) {
+	headers := NewCallbackSrvHeaders(map[string]string{"X-Tenant": "a"}, map[string]string{"Authorization": "Bearer token"})
+	if err := headers.Check(); err != nil {
+		t.Fatal(err)
+	}
+
+	all := headers.GetAll()
+	if len(all) != 2 || all["Authorization"] != "Bearer token" || all["X-Tenant"] != "a" {
+		t.Errorf("unexpected headers %v", all)
+	}
+
+	redacted := headers.GetRedacted()
+	if redacted["Authorization"] != RedactedHeaderValue || redacted["X-Tenant"] != "a" {
+		t.Errorf("expect secret header redacted, got %v", redacted)
+	}
+
+	var nilHeaders *CallbackSrvHeaders
+	if nilHeaders.GetAll() != nil || nilHeaders.GetRedacted() != nil || nilHeaders.Check() != nil {
+		t.Error("expect nil headers empty")
+	}
+
+	invalids := []*CallbackSrvHeaders{
+		NewCallbackSrvHeaders(map[string]string{"Content-Type": "text/plain"}, nil),
+		NewCallbackSrvHeaders(nil, map[string]string{"x-easy-task-signature": "x"}),
+		NewCallbackSrvHeaders(map[string]string{"Bad Header": "x"}, nil),
+		NewCallbackSrvHeaders(map[string]string{"authorization": "a"}, map[string]string{"Authorization": "b"}),
+	}
+	for i, invalid := range invalids {
+		if err := invalid.Check(); err == nil {
+			t.Errorf("case %d: expect invalid headers rejected", i)
+		}
+	}
+}
+
+func TestTaskCallbackMethod(t *testing.T) {
+	srv := NewTaskCallbackSrv("1", "srv", nil, false, nil, "", "", nil, RouteStrategyRandom, 0)
+	newReq := func(method CallbackMethod, contentType CallbackContentType) *NewTaskReq {
+		return &NewTaskReq{
+			CallbackSrv: srv,
+			Name: "task",
+			SchedMode: SchedModeTimeInterval,
+			CallbackMethod: method,
+			CallbackContentType: contentType,
+		}
+	}
+
+	oneTask, err := NewTask(newReq(CallbackMethodNil, CallbackContentTypeNil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if oneTask.GetCallbackMethod() != CallbackMethodPost || oneTask.GetCallbackContentType() != CallbackContentTypeJson {
+		t.Errorf("expect default POST json, got %s %s", oneTask.GetCallbackMethod(), oneTask.GetCallbackContentType())
+	}
+
+	if _, err = NewTask(newReq(CallbackMethodPut, CallbackContentTypeForm)); err != nil {
+		t.Error(err)
+	}
+	if _, err = NewTask(newReq("DELETE", CallbackContentTypeNil)); err == nil {
+		t.Error("expect invalid callback method rejected")
+	}
+	if _, err = NewTask(newReq(CallbackMethodPost, "text/plain")); err == nil {
+		t.Error("expect invalid callback content type rejected")
+	}
+}
@@ -0,0 +1,194 @@
+package callback
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/995933447/easytask/internal/task"
+	"github.com/995933447/easytask/internal/util/logger"
+	"github.com/995933447/easytask/pkg/rpc/proto/httpproto"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const RouteSchemaLocalCmd = "cmd"
+
+// 默认每个输出最多记录64KB
+const defaultLocalCmdOutputLimitBytes = 64 * 1024
+
+// 执行本地命令时传给命令的环境变量
+const (
+	LocalCmdEnvTaskId = "EASYTASK_TASK_ID"
+	LocalCmdEnvTaskName = "EASYTASK_TASK_NAME"
+	LocalCmdEnvTaskArg = "EASYTASK_TASK_ARG"
+	LocalCmdEnvRunTimes = "EASYTASK_RUN_TIMES"
+	LocalCmdEnvBizId = "EASYTASK_BIZ_ID"
+	LocalCmdEnvShardIndex = "EASYTASK_SHARD_INDEX"
+	LocalCmdEnvShardTotal = "EASYTASK_SHARD_TOTAL"
+)
+
+// 记录到回调日志的命令执行结果
+type LocalCmdResult struct {
+	ExitCode int `json:"exit_code"`
+	Stdout string `json:"stdout"`
+	Stderr string `json:"stderr"`
+	// 输出超过记录上限时只保留前面的部分
+	IsOutputTruncated bool `json:"is_output_truncated"`
+}
+
+// 只保留前limit个字节,超出部分丢弃
+type truncatedBuffer struct {
+	buf bytes.Buffer
+	limit int
+	isTruncated bool
+}
+
+func (b *truncatedBuffer) Write(p []byte) (int, error) {
+	if remain := b.limit - b.buf.Len(); remain < len(p) {
+		b.isTruncated = true
+		if remain > 0 {
+			b.buf.Write(p[:remain])
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+// 命令行只按空白拆分参数,不经过shell,所以不支持引号、转义和shell操作符
+const localCmdUnsupportedChars = "\"'\\`$|&;<>(){}*?~"
+
+// 按空白把命令行拆分成参数,命令行为空或者包含引号、转义、shell操作符时返回错误,
+// 避免配置的命令按shell的语义理解后拆分出不符合预期的参数
+func parseLocalCmdLine(cmdLine string) ([]string, error) {
+	if i := strings.IndexAny(cmdLine, localCmdUnsupportedChars); i >= 0 {
+		return nil, fmt.Errorf("unsupported char %q in command line %q, command line is split by whitespace without shell", cmdLine[i], cmdLine)
+	}
+	cmdArgs := strings.Fields(cmdLine)
+	if len(cmdArgs) == 0 {
+		return nil, errors.New("command line is empty")
+	}
+	return cmdArgs, nil
+}
+
+// 在当前调度节点上执行配置文件中允许的本地命令,任务的回调路径为命令名称.
+// 任务参数通过环境变量和标准输入传给命令,退出码为0代表执行成功
+type LocalCmdRouteExec struct {
+	// 命令名称对应的命令参数,第一个为可执行文件
+	cmds map[string][]string
+	outputLimitBytes int
+}
+
+// cmds的key为命令名称,value为按空白拆分参数的命令行,有不能拆分的命令行时返回错误.
+// outputLimitBytes小于等于0时使用默认值64KB
+func NewLocalCmdRouteExec(cmds map[string]string, outputLimitBytes int) (*LocalCmdRouteExec, error) {
+	if outputLimitBytes <= 0 {
+		outputLimitBytes = defaultLocalCmdOutputLimitBytes
+	}
+	cmdArgsMap := make(map[string][]string, len(cmds))
+	for cmdName, cmdLine := range cmds {
+		cmdArgs, err := parseLocalCmdLine(cmdLine)
+		if err != nil {
+			return nil, fmt.Errorf("local command %s: %w", cmdName, err)
+		}
+		cmdArgsMap[cmdName] = cmdArgs
+	}
+	return &LocalCmdRouteExec{
+		cmds: cmdArgsMap,
+		outputLimitBytes: outputLimitBytes,
+	}, nil
+}
+
+var _ RouteExec = (*LocalCmdRouteExec)(nil)
+
+func (e *LocalCmdRouteExec) Callback(ctx context.Context, input *RouteCallbackInput) (*httpproto.TaskCallbackResp, []byte, error) {
+	cmdName := strings.Trim(strings.TrimSpace(input.Path), "/")
+	cmdArgs, ok := e.cmds[cmdName]
+	if !ok {
+		err := fmt.Errorf("local command %s is not allowed", cmdName)
+		logger.MustGetCallbackLogger().Error(ctx, err)
+		return nil, nil, err
+	}
+
+	// 超时时间取任务的最大执行时间,超时后结束命令
+	cmdCtx, cancel := context.WithCancel(ctx)
+	if input.TimeoutSec > 0 {
+		cmdCtx, cancel = context.WithTimeout(ctx, time.Duration(input.TimeoutSec) * time.Second)
+	}
+	defer cancel()
+
+	var (
+		stdout = &truncatedBuffer{limit: e.outputLimitBytes}
+		stderr = &truncatedBuffer{limit: e.outputLimitBytes}
+	)
+	cmd := exec.CommandContext(cmdCtx, cmdArgs[0], cmdArgs[1:]...)
+	cmd.Stdin = strings.NewReader(input.Req.Arg)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.Env = append(
+		os.Environ(),
+		LocalCmdEnvTaskId + "=" + input.Req.TaskId,
+		LocalCmdEnvTaskName + "=" + input.Req.TaskName,
+		LocalCmdEnvTaskArg + "=" + input.Req.Arg,
+		fmt.Sprintf("%s=%d", LocalCmdEnvRunTimes, input.Req.RunTimes),
+		LocalCmdEnvBizId + "=" + input.Req.BizId,
+		fmt.Sprintf("%s=%d", LocalCmdEnvShardIndex, input.Req.ShardIndex),
+		fmt.Sprintf("%s=%d", LocalCmdEnvShardTotal, input.Req.ShardTotal),
+	)
+
+	logger.MustGetCallbackLogger().Infof(ctx, "run local command:%s task(id:%s)", strings.Join(cmdArgs, " "), input.Req.TaskId)
+
+	runErr := cmd.Run()
+
+	// 命令没有启动时退出码为-1
+	result := &LocalCmdResult{
+		ExitCode: cmd.ProcessState.ExitCode(),
+		Stdout: stdout.buf.String(),
+		Stderr: stderr.buf.String(),
+		IsOutputTruncated: stdout.isTruncated || stderr.isTruncated,
+	}
+
+	respRaw, err := json.Marshal(result)
+	if err != nil {
+		logger.MustGetCallbackLogger().Error(ctx, err)
+		return nil, nil, err
+	}
+
+	if cmdCtx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("local command %s exceeded timeout %d sec", cmdName, input.TimeoutSec)
+		logger.MustGetCallbackLogger().Error(ctx, err)
+		return nil, respRaw, err
+	}
+
+	// 命令正常退出但是退出码不为0属于执行失败,其他错误(例如命令不存在)按回调出错处理
+	var exitErr *exec.ExitError
+	if runErr != nil && !errors.As(runErr, &exitErr) {
+		logger.MustGetCallbackLogger().Error(ctx, runErr)
+		return nil, respRaw, runErr
+	}
+
+	logger.MustGetCallbackLogger().Infof(ctx, "resp:%s", string(respRaw))
+
+	return &httpproto.TaskCallbackResp{
+		IsSuccess: result.ExitCode == 0,
+	}, respRaw, nil
+}
+
+// 命令在调度节点本地执行,节点总是可用
+func (e *LocalCmdRouteExec) HeartBeat(context.Context, *task.TaskCallbackSrv, *task.TaskCallbackSrvRoute, int) (*httpproto.HeartBeatResp, error) {
+	return &httpproto.HeartBeatResp{
+		Pong: true,
+	}, nil
+}
+
+func (e *LocalCmdRouteExec) IsTransportErr(error) bool {
+	return false
+}
+
+// 命令在回调时同步执行完成,没有可以通知取消的异步执行
+func (e *LocalCmdRouteExec) Cancel(context.Context, *RouteCancelInput) error {
+	return nil
+}
@@ -0,0 +1,93 @@
+package callback
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/995933447/easytask/internal/util/logger"
+	"github.com/995933447/easytask/pkg/rpc/proto/httpproto"
+	"testing"
+)
+
+func TestLocalCmdRouteExec(t *testing.T) {
+	logger.Init(&logger.Conf{
+		LogDir: t.TempDir(),
+		FileSize: 1024 * 1024 * 100,
+	})
+
+	exec, err := NewLocalCmdRouteExec(map[string]string{
+		"echo_arg": "cat",
+		"fail": "false",
+		"slow": "sleep 5",
+	}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(cmdName string, timeoutSec int) (*httpproto.TaskCallbackResp, *LocalCmdResult, error) {
+		resp, respRaw, err := exec.Callback(context.TODO(), &RouteCallbackInput{
+			Path: "/" + cmdName,
+			TimeoutSec: timeoutSec,
+			Req: &httpproto.TaskCallbackReq{
+				TaskId: "task-1",
+				Arg: "abcdef",
+			},
+		})
+		var result LocalCmdResult
+		if respRaw != nil {
+			if err := json.Unmarshal(respRaw, &result); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return resp, &result, err
+	}
+
+	resp, result, err := run("echo_arg", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.IsSuccess || result.Stdout != "abc" || !result.IsOutputTruncated {
+		t.Errorf("expect success with truncated stdout, got %+v %+v", resp, result)
+	}
+
+	resp, result, err = run("fail", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.IsSuccess || result.ExitCode != 1 {
+		t.Errorf("expect failed with exit code 1, got %+v %+v", resp, result)
+	}
+
+	if _, _, err = run("slow", 1); err == nil {
+		t.Error("expect timeout error")
+	}
+
+	if _, _, err = run("rm", 3); err == nil {
+		t.Error("expect not allowed command error")
+	}
+}
+
+func TestNewLocalCmdRouteExecRejectUnsupportedCmdLine(t *testing.T) {
+	for _, cmdLine := range []string{
+		"",
+		"   ",
+		`sh -c "echo hello"`,
+		"rm '/tmp/a b'",
+		`/opt/a\ b.sh`,
+		"clean.sh > /dev/null",
+		"clean.sh; rm -rf /",
+		"echo $HOME",
+		"rm /tmp/*.log",
+	} {
+		if _, err := NewLocalCmdRouteExec(map[string]string{"cmd": cmdLine}, 0); err == nil {
+			t.Errorf("expect error for command line %q", cmdLine)
+		}
+	}
+
+	exec, err := NewLocalCmdRouteExec(map[string]string{"clean_log": " /opt/scripts/clean_log.sh  --days\t7 "}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args := exec.cmds["clean_log"]; len(args) != 3 || args[0] != "/opt/scripts/clean_log.sh" || args[2] != "7" {
+		t.Errorf("unexpected args %q", args)
+	}
+}
@@ -0,0 +1,476 @@
+package callback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/995933447/easytask/internal/task"
+	"github.com/995933447/easytask/internal/util/logger"
+	"github.com/995933447/easytask/pkg/rpc/proto/httpproto"
+	"math"
+	"sync"
+	"time"
+)
+
+// 按节点协议回调单个节点,不同协议的节点可以注册在同一个回调服务下
+type RouteExec interface {
+	// 回调节点执行任务,respRaw为节点返回的原始响应,用于记录回调日志
+	Callback(ctx context.Context, input *RouteCallbackInput) (resp *httpproto.TaskCallbackResp, respRaw []byte, err error)
+	HeartBeat(ctx context.Context, srv *task.TaskCallbackSrv, route *task.TaskCallbackSrvRoute, timeoutSec int) (*httpproto.HeartBeatResp, error)
+	// 是否是连接失败,超时等没有拿到节点响应的错误,这类错误可以换节点重试
+	IsTransportErr(err error) bool
+	// 通知节点取消一次执行
+	Cancel(ctx context.Context, input *RouteCancelInput) error
+}
+
+type RouteCallbackInput struct {
+	Path string
+	// 只对http节点有效
+	Method task.CallbackMethod
+	ContentType task.CallbackContentType
+	CallbackSrv *task.TaskCallbackSrv
+	Route *task.TaskCallbackSrvRoute
+	TimeoutSec int
+	Req *httpproto.TaskCallbackReq
+}
+
+type RouteCancelInput struct {
+	// 取消的执行回调时的回调路径
+	Path string
+	CallbackSrv *task.TaskCallbackSrv
+	Route *task.TaskCallbackSrvRoute
+	TimeoutSec int
+	Req *httpproto.TaskCancelReq
+}
+
+// 负责选择节点,失败转移,广播和分片等调度逻辑,具体回调节点时按节点协议交给对应的RouteExec
+type Exec struct {
+	taskLogger *task.TaskLogger
+	routeSelector task.RouteSelector
+	circuitBreaker *task.RouteCircuitBreaker
+	routeExecs map[string]RouteExec
+	asyncRunRoutesMu sync.Mutex
+	// 异步执行的回调选中的节点,执行确认或超时前一直计入节点的执行中回调数
+	asyncRunRoutes map[asyncRunKey][]*task.TaskCallbackSrvRoute
+}
+
+type asyncRunKey struct {
+	taskId string
+	runTimes int
+}
+
+// routeExecs为节点协议到RouteExec的映射
+func NewExec(taskLogger *task.TaskLogger, circuitBreaker *task.RouteCircuitBreaker, routeExecs map[string]RouteExec) *Exec {
+	return &Exec{
+		taskLogger: taskLogger,
+		routeSelector: task.NewStrategyRouteSelector(),
+		circuitBreaker: circuitBreaker,
+		routeExecs: routeExecs,
+		asyncRunRoutes: map[asyncRunKey][]*task.TaskCallbackSrvRoute{},
+	}
+}
+
+var _ task.TaskCallbackSrvExec = (*Exec)(nil)
+
+var errRouteProbing = errors.New("route of half open circuit breaker is being probed")
+
+func (e *Exec) getRouteExec(route *task.TaskCallbackSrvRoute) (RouteExec, error) {
+	routeExec, ok := e.routeExecs[route.GetSchema()]
+	if !ok {
+		return nil, fmt.Errorf("route(id:%s) schema %s is not supported", route.GetId(), route.GetSchema())
+	}
+	return routeExec, nil
+}
+
+func (e *Exec) CallbackSrv(ctx context.Context, oneTask *task.Task, _ any) (*task.TaskCallbackSrvResp, error) {
+	var deadline time.Time
+	if maxRunTimeSec := oneTask.GetMaxRunTimeSec(); maxRunTimeSec > 0 {
+		deadline = time.Now().Add(time.Duration(maxRunTimeSec) * time.Second)
+	}
+
+	// 匹配任务标签选择器并且没有被熔断的节点,随机、广播和分片执行都只回调这些节点
+	routes := e.circuitBreaker.FilterAllowedRoutes(oneTask.GetCallbackRoutes())
+
+	if shardTotal := oneTask.GetShardTotal(); shardTotal > 0 {
+		return e.dispatchSubRuns(ctx, oneTask, task.GetShardRoutes(routes, shardTotal), deadline)
+	}
+
+	if oneTask.GetDispatchMode() == task.DispatchModeBroadcast {
+		return e.dispatchSubRuns(ctx, oneTask, routes, deadline)
+	}
+
+	route := e.routeSelector.SelectRoute(oneTask, routes)
+	if route == nil {
+		err := errors.New("callback server has no available route")
+		logger.MustGetCallbackLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	httpResp, err := e.callbackWithFailover(ctx, oneTask, &callbackRouteInput{
+		Route: route,
+		IsRouteSelected: true,
+		Deadline: deadline,
+	})
+	if err != nil {
+		logger.MustGetCallbackLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	return task.NewCallbackSrvResp(httpResp.IsRunInAsync, httpResp.IsSuccess, httpResp.Extra), nil
+}
+
+// 并发回调每个子执行对应的节点(广播时为所有节点,分片时为分片分配到的节点),汇总子执行的结果.
+// 有异步执行的子执行导致结果还不能确定时按异步执行返回,由子执行的确认推进
+func (e *Exec) dispatchSubRuns(ctx context.Context, oneTask *task.Task, routes []*task.TaskCallbackSrvRoute, deadline time.Time) (*task.TaskCallbackSrvResp, error) {
+	if len(routes) == 0 {
+		err := errors.New("callback server has no route to dispatch sub runs")
+		logger.MustGetCallbackLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	err := e.taskLogger.Log(ctx, task.MustNewTaskLog(
+		task.TaskLogTypeSubRunsStarted,
+		task.NewTaskSubRunsStartedLogDetail(oneTask.GetId(), oneTask.GetRunTimes(), routes),
+	))
+	if err != nil {
+		logger.MustGetCallbackLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	var (
+		wg sync.WaitGroup
+		subRunStatuses = make([]task.Status, len(routes))
+	)
+	for i, route := range routes {
+		wg.Add(1)
+		go func(i int, route *task.TaskCallbackSrvRoute) {
+			defer wg.Done()
+
+			input := &callbackRouteInput{
+				Route: route,
+				SubRunIdx: i + 1,
+				SubRunTotal: len(routes),
+				Deadline: deadline,
+			}
+
+			var (
+				httpResp *httpproto.TaskCallbackResp
+				err error
+			)
+			// 广播执行的每个子执行固定对应一个节点,只有分片可以换节点重试
+			if oneTask.GetShardTotal() > 0 {
+				httpResp, err = e.callbackWithFailover(ctx, oneTask, input)
+			} else {
+				httpResp, err = e.callbackRoute(ctx, oneTask, input)
+			}
+			if err != nil {
+				logger.MustGetCallbackLogger().Error(ctx, err)
+				subRunStatuses[i] = task.StatusFailed
+				return
+			}
+
+			if httpResp.IsSuccess {
+				subRunStatuses[i] = task.StatusSuccess
+			} else if httpResp.IsRunInAsync {
+				subRunStatuses[i] = task.StatusRunning
+			} else {
+				subRunStatuses[i] = task.StatusFailed
+			}
+		}(i, route)
+	}
+	wg.Wait()
+
+	var status task.Status
+	if oneTask.GetShardTotal() > 0 {
+		status = task.AggregateShardStatus(subRunStatuses)
+	} else {
+		status = task.AggregateSubRunStatus(oneTask.GetBroadcastSuccessRule(), subRunStatuses)
+	}
+
+	return task.NewCallbackSrvResp(status == task.StatusRunning, status == task.StatusSuccess, ""), nil
+}
+
+type callbackRouteInput struct {
+	Route *task.TaskCallbackSrvRoute
+	// Route是否由routeSelector选出,选出的节点在回调结束后需要释放
+	IsRouteSelected bool
+	// 广播或分片执行的第几个子执行和子执行总数,0代表不是子执行
+	SubRunIdx int
+	SubRunTotal int
+	// 本次执行的截止时间,零值代表不限制
+	Deadline time.Time
+	// 本次执行之前在其他节点上失败的回调尝试
+	Attempts []*task.TaskCallbackAttempt
+}
+
+// 回调在传输层失败时在截止时间之前换一个没有尝试过的节点重试,最多重试任务配置的次数
+func (e *Exec) callbackWithFailover(ctx context.Context, oneTask *task.Task, input *callbackRouteInput) (*httpproto.TaskCallbackResp, error) {
+	for {
+		callbackAt := time.Now().Unix()
+		httpResp, err := e.callbackRoute(ctx, oneTask, input)
+		if input.IsRouteSelected {
+			e.releaseRoute(oneTask, input.Route, httpResp, err)
+		}
+		if err == nil {
+			return httpResp, nil
+		}
+
+		if !e.isTransportErr(input.Route, err) || len(input.Attempts) >= oneTask.GetFailoverMaxAttempts() {
+			return nil, err
+		}
+
+		if !input.Deadline.IsZero() && !time.Now().Before(input.Deadline) {
+			return nil, err
+		}
+
+		attempts := append(input.Attempts, task.NewTaskCallbackAttempt(input.Route, err, callbackAt))
+		route := e.routeSelector.SelectRoute(oneTask, e.circuitBreaker.FilterAllowedRoutes(oneTask.GetUntriedCallbackRoutes(attempts)))
+		if route == nil {
+			return nil, err
+		}
+
+		logger.MustGetCallbackLogger().Warnf(
+			ctx, "task(id:%s) callback route(id:%s) failed, failover to route(id:%s)",
+			oneTask.GetId(), input.Route.GetId(), route.GetId(),
+			)
+
+		input = &callbackRouteInput{
+			Route: route,
+			IsRouteSelected: true,
+			SubRunIdx: input.SubRunIdx,
+			SubRunTotal: input.SubRunTotal,
+			Deadline: input.Deadline,
+			Attempts: attempts,
+		}
+	}
+}
+
+// 每次尝试结束后释放本次选中的节点,回调服务异步执行时等执行确认或超时后再由ReleaseRun释放
+func (e *Exec) releaseRoute(oneTask *task.Task, route *task.TaskCallbackSrvRoute, httpResp *httpproto.TaskCallbackResp, err error) {
+	if err != nil || !httpResp.IsRunInAsync || httpResp.IsSuccess {
+		e.routeSelector.ReleaseRoute(route)
+		return
+	}
+
+	e.asyncRunRoutesMu.Lock()
+	defer e.asyncRunRoutesMu.Unlock()
+	key := asyncRunKey{taskId: oneTask.GetId(), runTimes: oneTask.GetRunTimes()}
+	e.asyncRunRoutes[key] = append(e.asyncRunRoutes[key], route)
+}
+
+func (e *Exec) ReleaseRun(taskId string, runTimes int) {
+	e.asyncRunRoutesMu.Lock()
+	key := asyncRunKey{taskId: taskId, runTimes: runTimes}
+	routes := e.asyncRunRoutes[key]
+	delete(e.asyncRunRoutes, key)
+	e.asyncRunRoutesMu.Unlock()
+
+	for _, route := range routes {
+		e.routeSelector.ReleaseRoute(route)
+	}
+}
+
+func (e *Exec) isTransportErr(route *task.TaskCallbackSrvRoute, err error) bool {
+	if err == errRouteProbing {
+		return true
+	}
+	routeExec, ok := e.routeExecs[route.GetSchema()]
+	return ok && routeExec.IsTransportErr(err)
+}
+
+func (e *Exec) callbackRoute(ctx context.Context, oneTask *task.Task, input *callbackRouteInput) (*httpproto.TaskCallbackResp, error) {
+	var (
+		route = input.Route
+		subRunIdx = input.SubRunIdx
+		callbackAt = time.Now().Unix()
+	)
+
+	var (
+		httpReq = &httpproto.TaskCallbackReq{
+			Cmd: 	  httpproto.CallbackCmdTaskCallback,
+			TaskId:   oneTask.GetId(),
+			Arg:      oneTask.GetArg(),
+			TaskName: oneTask.GetName(),
+			RunTimes: oneTask.GetRunTimes(),
+			BizId:    oneTask.GetBizId(),
+			SubRunIdx: subRunIdx,
+			SubRunTotal: input.SubRunTotal,
+		}
+		httpResp = &httpproto.TaskCallbackResp{}
+	)
+
+	if trigger := oneTask.GetTrigger(); trigger != nil {
+		httpReq.WorkflowRunId = trigger.GetWorkflowRunId()
+	}
+
+	if shardTotal := oneTask.GetShardTotal(); shardTotal > 0 {
+		httpReq.ShardIndex = subRunIdx - 1
+		httpReq.ShardTotal = shardTotal
+	}
+
+	timeoutSec := route.GetCallbackTimeoutSec()
+	if !input.Deadline.IsZero() {
+		remainSec := int(math.Ceil(time.Until(input.Deadline).Seconds()))
+		if remainSec <= 0 {
+			remainSec = 1
+		}
+		if timeoutSec <= 0 || timeoutSec > remainSec {
+			timeoutSec = remainSec
+		}
+	}
+
+	var (
+		callbackErr error
+		callbackRespRaw []byte
+	)
+	defer func() {
+		newLogDetailReq := &task.NewTaskCallbackLogDetailReq{
+			TaskId: oneTask.GetId(),
+			RunTimes: oneTask.GetRunTimes(),
+			SubRunIdx: subRunIdx,
+			Route: route,
+			IsRunInAsync: httpResp.IsRunInAsync,
+			Err: callbackErr,
+			CallbackPath: oneTask.GetCallbackPath(),
+			Attempts: append(input.Attempts[:len(input.Attempts):len(input.Attempts)], task.NewTaskCallbackAttempt(route, callbackErr, callbackAt)),
+		}
+		if callbackRespRaw != nil {
+			newLogDetailReq.RespRaw = string(callbackRespRaw)
+		}
+		if isHttpRoute(route) {
+			newLogDetailReq.CallbackMethod = oneTask.GetCallbackMethod()
+			newLogDetailReq.CallbackContentType = oneTask.GetCallbackContentType()
+			newLogDetailReq.CallbackHeaders = oneTask.GetCallbackSrv().GetHeaders().GetRedacted()
+		}
+		if httpResp.IsSuccess {
+			newLogDetailReq.TaskStatus = task.StatusSuccess
+		} else if httpResp.IsRunInAsync {
+			newLogDetailReq.TaskStatus = task.StatusRunning
+		} else {
+			newLogDetailReq.TaskStatus = task.StatusFailed
+		}
+		taskLogDetail, err := task.NewTaskCallbackLogDetail(newLogDetailReq)
+		if err != nil {
+			logger.MustGetCallbackLogger().Error(ctx, err)
+			return
+		}
+		err = e.taskLogger.Log(ctx, task.MustNewTaskLog(task.TaskLogTypeCallback, taskLogDetail))
+		if err != nil {
+			logger.MustGetCallbackLogger().Error(ctx, err)
+			return
+		}
+	}()
+	// 半开的节点已经有试探回调时不回调,请求没有发出,可以换节点重试
+	if !e.circuitBreaker.AllowCall(route) {
+		callbackErr = errRouteProbing
+		logger.MustGetCallbackLogger().Error(ctx, callbackErr)
+		return nil, callbackErr
+	}
+
+	routeExec, callbackErr := e.getRouteExec(route)
+	if callbackErr == nil {
+		var resp *httpproto.TaskCallbackResp
+		resp, callbackRespRaw, callbackErr = routeExec.Callback(ctx, &RouteCallbackInput{
+			Path: oneTask.GetCallbackPath(),
+			Method: oneTask.GetCallbackMethod(),
+			ContentType: oneTask.GetCallbackContentType(),
+			CallbackSrv: oneTask.GetCallbackSrv(),
+			Route: route,
+			TimeoutSec: timeoutSec,
+			Req: httpReq,
+		})
+		if resp != nil {
+			httpResp = resp
+		}
+	}
+	// 回调服务返回的业务失败不影响熔断
+	e.circuitBreaker.Record(route, callbackErr != nil)
+	if callbackErr != nil {
+		logger.MustGetCallbackLogger().Error(ctx, callbackErr)
+		return nil, callbackErr
+	}
+
+	return httpResp, nil
+}
+
+// 依次通知执行过这次执行的节点取消,返回第一个通知失败的错误
+func (e *Exec) CancelRun(ctx context.Context, oneTask *task.Task, runTimes int, routes []*task.TaskCallbackSrvRoute) error {
+	req := &httpproto.TaskCancelReq{
+		Cmd: httpproto.CallbackCmdTaskCancel,
+		TaskId: oneTask.GetId(),
+		TaskName: oneTask.GetName(),
+		RunTimes: runTimes,
+		BizId: oneTask.GetBizId(),
+	}
+
+	var firstErr error
+	for _, route := range routes {
+		routeExec, err := e.getRouteExec(route)
+		if err == nil {
+			err = routeExec.Cancel(ctx, &RouteCancelInput{
+				Path: oneTask.GetCallbackPath(),
+				CallbackSrv: oneTask.GetCallbackSrv(),
+				Route: route,
+				TimeoutSec: route.GetCallbackTimeoutSec(),
+				Req: req,
+			})
+		}
+		if err != nil {
+			logger.MustGetCallbackLogger().Error(ctx, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	return firstErr
+}
+
+func (e *Exec) HeartBeat(ctx context.Context, srv *task.TaskCallbackSrv) (*task.HeartBeatResp, error) {
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+		noReplyRoutes []*task.TaskCallbackSrvRoute
+		replyRoutes []*task.TaskCallbackSrvRoute
+	)
+	for _, route := range srv.GetRoutes() {
+		wg.Add(1)
+		go func(route *task.TaskCallbackSrvRoute) {
+			defer wg.Done()
+
+			isReplied := e.heartBeatRoute(ctx, srv, route)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if !isReplied {
+				noReplyRoutes = append(noReplyRoutes, route)
+				return
+			}
+			replyRoutes = append(replyRoutes, route)
+		}(route)
+	}
+	wg.Wait()
+
+	return task.NewHeartBeatResp(replyRoutes, noReplyRoutes), nil
+}
+
+func (e *Exec) heartBeatRoute(ctx context.Context, srv *task.TaskCallbackSrv, route *task.TaskCallbackSrvRoute) bool {
+	routeExec, err := e.getRouteExec(route)
+	if err != nil {
+		logger.MustGetCallbackLogger().Error(ctx, err)
+		return false
+	}
+
+	resp, err := routeExec.HeartBeat(ctx, srv, route, route.GetCallbackTimeoutSec())
+	if err != nil {
+		logger.MustGetCallbackLogger().Error(ctx, err)
+		return false
+	}
+
+	if !resp.Pong {
+		logger.MustGetCallbackLogger().Warnf(ctx, "route(id:%s) heart beat resp.Pong is false", route.GetId())
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,54 @@
+package callback
+
+import (
+	"errors"
+	"github.com/995933447/easytask/internal/task"
+	"github.com/995933447/easytask/pkg/rpc/proto/httpproto"
+	"testing"
+)
+
+func TestExecReleaseRouteKeepAsyncRunInFlight(t *testing.T) {
+	routes := []*task.TaskCallbackSrvRoute{
+		task.NewTaskCallbackSrvRoute("1", "http", "127.0.0.1", 8001, 0, false, 0, nil),
+		task.NewTaskCallbackSrvRoute("2", "http", "127.0.0.1", 8002, 0, false, 0, nil),
+	}
+	srv := task.NewTaskCallbackSrv("1", "srv", routes, false, nil, "", "", nil, task.RouteStrategyLeastInFlight, 0)
+	oneTask, err := task.NewTask(&task.NewTaskReq{
+		Id: "1",
+		Name: "task",
+		CallbackSrv: srv,
+		SchedMode: task.SchedModeTimeInterval,
+		TimeIntervalSec: 60,
+		RunTimes: 3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exec := NewExec(nil, nil, nil)
+
+	// 传输层失败的节点在本次尝试结束后就释放
+	failed := exec.routeSelector.SelectRoute(oneTask, routes)
+	exec.releaseRoute(oneTask, failed, nil, errors.New("connection refused"))
+
+	// 异步执行的节点在执行确认前一直算执行中,最少执行中策略会选另一个节点
+	async := exec.routeSelector.SelectRoute(oneTask, routes)
+	exec.releaseRoute(oneTask, async, &httpproto.TaskCallbackResp{IsRunInAsync: true}, nil)
+	for i := 0; i < 5; i++ {
+		route := exec.routeSelector.SelectRoute(oneTask, routes)
+		if route == async {
+			t.Fatalf("expect async route %s still in flight", async.GetId())
+		}
+		exec.releaseRoute(oneTask, route, &httpproto.TaskCallbackResp{IsSuccess: true}, nil)
+	}
+
+	exec.ReleaseRun(oneTask.GetId(), oneTask.GetRunTimes())
+	selected := map[string]bool{}
+	for i := 0; i < 20; i++ {
+		route := exec.routeSelector.SelectRoute(oneTask, routes)
+		selected[route.GetId()] = true
+		exec.releaseRoute(oneTask, route, &httpproto.TaskCallbackResp{IsSuccess: true}, nil)
+	}
+	if !selected[async.GetId()] {
+		t.Errorf("expect route %s selectable after async run released", async.GetId())
+	}
+}
@@ -0,0 +1,176 @@
+package callback
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/995933447/easytask/internal/task"
+	"github.com/995933447/easytask/internal/util/logger"
+	"github.com/995933447/easytask/pkg/rpc/proto/grpcproto"
+	"github.com/995933447/easytask/pkg/rpc/proto/httpproto"
+	simpletracectx "github.com/995933447/simpletrace/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"sync"
+	"time"
+)
+
+const RouteSchemaGrpc = "grpc"
+
+// 以grpc协议回调节点,节点需要实现pkg/rpc/proto/grpcproto/callback.proto中的TaskCallbackService
+type GrpcRouteExec struct {
+	mu sync.Mutex
+	// 按节点地址复用连接
+	conns map[string]*grpc.ClientConn
+}
+
+func NewGrpcRouteExec() *GrpcRouteExec {
+	return &GrpcRouteExec{
+		conns: map[string]*grpc.ClientConn{},
+	}
+}
+
+var _ RouteExec = (*GrpcRouteExec)(nil)
+
+func (e *GrpcRouteExec) getConn(ctx context.Context, route *task.TaskCallbackSrvRoute) (*grpc.ClientConn, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	addr := fmt.Sprintf("%s:%d", route.GetHost(), route.GetPort())
+	if conn, ok := e.conns[addr]; ok {
+		return conn, nil
+	}
+
+	// 非阻塞建立连接,连接失败在调用时返回Unavailable
+	conn, err := grpc.Dial(addr, grpc.WithInsecure())
+	if err != nil {
+		logger.MustGetCallbackLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	e.conns[addr] = conn
+
+	return conn, nil
+}
+
+func (e *GrpcRouteExec) newCallCtx(ctx context.Context, timeoutSec int) (context.Context, context.CancelFunc) {
+	callCtx := context.Context(ctx)
+	if traceCtx, ok := ctx.(*simpletracectx.Context); ok {
+		callCtx = metadata.AppendToOutgoingContext(
+			callCtx,
+			httpproto.HeaderSimpleTraceId, traceCtx.GetTraceId(),
+			httpproto.HeaderSimpleTraceSpanId, traceCtx.GetSpanId(),
+			httpproto.HeaderSimpleTraceParentSpanId, traceCtx.GetParentSpanId(),
+		)
+	}
+	if timeoutSec > 0 {
+		return context.WithTimeout(callCtx, time.Duration(timeoutSec) * time.Second)
+	}
+	return context.WithCancel(callCtx)
+}
+
+func (e *GrpcRouteExec) Callback(ctx context.Context, input *RouteCallbackInput) (*httpproto.TaskCallbackResp, []byte, error) {
+	conn, err := e.getConn(ctx, input.Route)
+	if err != nil {
+		logger.MustGetCallbackLogger().Error(ctx, err)
+		return nil, nil, err
+	}
+
+	grpcReq := &grpcproto.TaskCallbackReq{
+		TaskId: input.Req.TaskId,
+		TaskName: input.Req.TaskName,
+		Arg: input.Req.Arg,
+		RunTimes: int32(input.Req.RunTimes),
+		BizId: input.Req.BizId,
+		WorkflowRunId: input.Req.WorkflowRunId,
+		SubRunIdx: int32(input.Req.SubRunIdx),
+		SubRunTotal: int32(input.Req.SubRunTotal),
+		ShardIndex: int32(input.Req.ShardIndex),
+		ShardTotal: int32(input.Req.ShardTotal),
+		CallbackPath: input.Path,
+	}
+
+	callCtx, cancel := e.newCallCtx(ctx, input.TimeoutSec)
+	defer cancel()
+
+	logger.MustGetCallbackLogger().Infof(ctx, "grpc call:%s param:%s", conn.Target(), grpcReq.String())
+
+	grpcResp, err := grpcproto.NewTaskCallbackServiceClient(conn).TaskCallback(callCtx, grpcReq)
+	if err != nil {
+		logger.MustGetCallbackLogger().Error(ctx, err)
+		return nil, nil, err
+	}
+
+	logger.MustGetCallbackLogger().Infof(ctx, "resp:%s", grpcResp.String())
+
+	resp := &httpproto.TaskCallbackResp{
+		IsRunInAsync: grpcResp.IsRunInAsync,
+		IsSuccess: grpcResp.IsSuccess,
+		Extra: grpcResp.Extra,
+	}
+
+	// 回调日志统一记录json格式的响应
+	respRaw, err := json.Marshal(resp)
+	if err != nil {
+		logger.MustGetCallbackLogger().Error(ctx, err)
+		return nil, nil, err
+	}
+
+	return resp, respRaw, nil
+}
+
+func (e *GrpcRouteExec) HeartBeat(ctx context.Context, _ *task.TaskCallbackSrv, route *task.TaskCallbackSrvRoute, timeoutSec int) (*httpproto.HeartBeatResp, error) {
+	conn, err := e.getConn(ctx, route)
+	if err != nil {
+		logger.MustGetCallbackLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	callCtx, cancel := e.newCallCtx(ctx, timeoutSec)
+	defer cancel()
+
+	grpcResp, err := grpcproto.NewTaskCallbackServiceClient(conn).HeartBeat(callCtx, &grpcproto.HeartBeatReq{})
+	if err != nil {
+		logger.MustGetCallbackLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	return &httpproto.HeartBeatResp{
+		Pong: grpcResp.Pong,
+	}, nil
+}
+
+func (e *GrpcRouteExec) Cancel(ctx context.Context, input *RouteCancelInput) error {
+	conn, err := e.getConn(ctx, input.Route)
+	if err != nil {
+		logger.MustGetCallbackLogger().Error(ctx, err)
+		return err
+	}
+
+	grpcReq := &grpcproto.TaskCancelReq{
+		TaskId: input.Req.TaskId,
+		TaskName: input.Req.TaskName,
+		RunTimes: int32(input.Req.RunTimes),
+		BizId: input.Req.BizId,
+		CallbackPath: input.Path,
+	}
+
+	callCtx, cancel := e.newCallCtx(ctx, input.TimeoutSec)
+	defer cancel()
+
+	logger.MustGetCallbackLogger().Infof(ctx, "grpc call:%s param:%s", conn.Target(), grpcReq.String())
+
+	if _, err = grpcproto.NewTaskCallbackServiceClient(conn).TaskCancel(callCtx, grpcReq); err != nil {
+		logger.MustGetCallbackLogger().Error(ctx, err)
+		return err
+	}
+
+	return nil
+}
+
+// 连接不可用时请求没有发到节点.超时时节点可能已经处理,算作回调失败
+func (e *GrpcRouteExec) IsTransportErr(err error) bool {
+	return status.Code(err) == codes.Unavailable
+}
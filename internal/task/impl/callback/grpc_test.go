@@ -0,0 +1,103 @@
+package callback
+
+import (
+	"context"
+	"github.com/995933447/easytask/internal/task"
+	"github.com/995933447/easytask/internal/util/logger"
+	"github.com/995933447/easytask/pkg/rpc/proto/grpcproto"
+	"github.com/995933447/easytask/pkg/rpc/proto/httpproto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net"
+	"testing"
+)
+
+type testTaskCallbackService struct {
+}
+
+func (s *testTaskCallbackService) TaskCallback(_ context.Context, req *grpcproto.TaskCallbackReq) (*grpcproto.TaskCallbackResp, error) {
+	return &grpcproto.TaskCallbackResp{
+		IsSuccess: req.ShardIndex == 1 && req.CallbackPath == "/order/close",
+		Extra: req.TaskId,
+	}, nil
+}
+
+func (s *testTaskCallbackService) HeartBeat(context.Context, *grpcproto.HeartBeatReq) (*grpcproto.HeartBeatResp, error) {
+	return &grpcproto.HeartBeatResp{Pong: true}, nil
+}
+
+func (s *testTaskCallbackService) TaskCancel(_ context.Context, req *grpcproto.TaskCancelReq) (*grpcproto.TaskCancelResp, error) {
+	if req.TaskId != "task-1" || req.RunTimes != 2 || req.CallbackPath != "/order/close" {
+		return nil, status.Error(codes.NotFound, "run not found")
+	}
+	return &grpcproto.TaskCancelResp{}, nil
+}
+
+func TestGrpcRouteExec(t *testing.T) {
+	logger.Init(&logger.Conf{
+		LogDir: t.TempDir(),
+		FileSize: 1024 * 1024 * 100,
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	grpcproto.RegisterTaskCallbackServiceServer(srv, &testTaskCallbackService{})
+	go srv.Serve(listener)
+	defer srv.Stop()
+
+	port := listener.Addr().(*net.TCPAddr).Port
+	route := task.NewTaskCallbackSrvRoute("1", RouteSchemaGrpc, "127.0.0.1", port, 3, true, 1, nil)
+	exec := NewGrpcRouteExec()
+
+	resp, respRaw, err := exec.Callback(context.TODO(), &RouteCallbackInput{
+		Path: "/order/close",
+		Route: route,
+		TimeoutSec: 3,
+		Req: &httpproto.TaskCallbackReq{
+			TaskId: "task-1",
+			ShardIndex: 1,
+			ShardTotal: 2,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.IsSuccess || resp.Extra != "task-1" {
+		t.Fatalf("unexpected callback resp %+v", resp)
+	}
+	if string(respRaw) != `{"is_run_in_async":false,"is_success":true,"extra":"task-1"}` {
+		t.Errorf("unexpected callback resp raw %s", respRaw)
+	}
+
+	heartBeatResp, err := exec.HeartBeat(context.TODO(), nil, route, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !heartBeatResp.Pong {
+		t.Error("expect heart beat pong")
+	}
+
+	err = exec.Cancel(context.TODO(), &RouteCancelInput{
+		Path: "/order/close",
+		Route: route,
+		TimeoutSec: 3,
+		Req: &httpproto.TaskCancelReq{TaskId: "task-1", RunTimes: 2},
+	})
+	if err != nil {
+		t.Errorf("expect cancel accepted, got %v", err)
+	}
+
+	srv.Stop()
+	_, _, err = exec.Callback(context.TODO(), &RouteCallbackInput{
+		Route: route,
+		TimeoutSec: 1,
+		Req: &httpproto.TaskCallbackReq{TaskId: "task-1"},
+	})
+	if err == nil || !exec.IsTransportErr(err) {
+		t.Errorf("expect transport error after server stopped, got %v", err)
+	}
+}
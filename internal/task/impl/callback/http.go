@@ -3,111 +3,121 @@ package callback
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/995933447/easytask/internal/task"
 	"github.com/995933447/easytask/internal/util/logger"
+	"github.com/995933447/easytask/pkg/rpc"
 	"github.com/995933447/easytask/pkg/rpc/proto/httpproto"
 	simpletracectx "github.com/995933447/simpletrace/context"
 	"github.com/go-playground/validator"
 	"io"
 	"net/http"
+	"net/http/httptrace"
+	"net/url"
 	"strings"
-	"sync"
+	"sync/atomic"
 	"time"
 )
 
-type HttpExec struct {
-	taskLogger *task.TaskLogger
+const (
+	RouteSchemaHttp = "http"
+	RouteSchemaHttps = "https"
+)
+
+var doReqValidator = validator.New()
+
+func isHttpRoute(route *task.TaskCallbackSrvRoute) bool {
+	return route.GetSchema() == RouteSchemaHttp || route.GetSchema() == RouteSchemaHttps
 }
 
-func NewHttpExec(taskLogger *task.TaskLogger) *HttpExec {
-	return &HttpExec{
-		taskLogger: taskLogger,
-	}
+// 以http协议回调节点,用于http和https协议的节点,响应为json格式.
+// 所有回调共用同一个client和连接池,单独配置了tls的服务使用自己的client
+type HttpRouteExec struct {
+	client *http.Client
+	// 服务名称对应单独配置了tls的client
+	srvClients map[string]*http.Client
+	maxRespBodyBytes int64
 }
 
-var _ task.TaskCallbackSrvExec = (*HttpExec)(nil)
+// transportOpts为空时使用默认的连接参数.tlsConfig为所有服务默认的tls配置,srvTlsConfigs为按服务名称单独指定的tls配置,都可以为空
+func NewHttpRouteExec(transportOpts *HttpTransportOpts, tlsConfig *tls.Config, srvTlsConfigs map[string]*tls.Config) *HttpRouteExec {
+	transportOpts = transportOpts.withDefaults()
+	exec := &HttpRouteExec{
+		client: &http.Client{
+			Transport: newHttpTransport(transportOpts, tlsConfig),
+		},
+		srvClients: map[string]*http.Client{},
+		maxRespBodyBytes: transportOpts.MaxRespBodyBytes,
+	}
+	for srvName, srvTlsConfig := range srvTlsConfigs {
+		exec.srvClients[srvName] = &http.Client{
+			Transport: newHttpTransport(transportOpts, srvTlsConfig),
+		}
+	}
+	return exec
+}
 
-func (e *HttpExec) CallbackSrv(ctx context.Context, oneTask *task.Task, _ any) (*task.TaskCallbackSrvResp, error) {
-	var (
-		httpReq = &httpproto.TaskCallbackReq{
-			Cmd: 	  httpproto.CallbackCmdTaskCallback,
-			TaskId:   oneTask.GetId(),
-			Arg:      oneTask.GetArg(),
-			TaskName: oneTask.GetName(),
-			RunTimes: oneTask.GetRunTimes(),
-			BizId:    oneTask.GetBizId(),
+func (e *HttpRouteExec) getClient(srv *task.TaskCallbackSrv) *http.Client {
+	if srv != nil {
+		if client, ok := e.srvClients[srv.GetName()]; ok {
+			return client
 		}
-		httpResp = &httpproto.TaskCallbackResp{}
-	)
+	}
+	return e.client
+}
 
-	httpReqBytes, err := json.Marshal(httpReq)
-	if err != nil {
-		logger.MustGetCallbackLogger().Error(ctx, err)
-		return nil, err
+var _ RouteExec = (*HttpRouteExec)(nil)
+
+func (e *HttpRouteExec) Callback(ctx context.Context, input *RouteCallbackInput) (*httpproto.TaskCallbackResp, []byte, error) {
+	method, contentType := input.Method, input.ContentType
+	if method == task.CallbackMethodNil {
+		method = task.CallbackMethodPost
+	}
+	if contentType == task.CallbackContentTypeNil {
+		contentType = task.CallbackContentTypeJson
 	}
 
 	var (
-		route = oneTask.GetCallbackSrv().GetRandomRoute()
-		timeoutSec int
+		httpReqBytes []byte
+		err error
 	)
-	if route.GetCallbackTimeoutSec() > oneTask.GetMaxRunTimeSec() {
-		timeoutSec = oneTask.GetMaxRunTimeSec()
+	if method == task.CallbackMethodGet || contentType == task.CallbackContentTypeForm {
+		var values url.Values
+		values, err = toCallbackReqValues(input.Req)
+		if err == nil {
+			httpReqBytes = []byte(values.Encode())
+		}
 	} else {
-		timeoutSec = route.GetCallbackTimeoutSec()
+		httpReqBytes, err = json.Marshal(input.Req)
+	}
+	if err != nil {
+		logger.MustGetCallbackLogger().Error(ctx, err)
+		return nil, nil, err
 	}
 
-	var (
-		callbackErr error
-		callbackRespRaw []byte
-	)
-	defer func() {
-		newLogDetailReq := &task.NewTaskCallbackLogDetailReq{
-			TaskId: oneTask.GetId(),
-			RunTimes: oneTask.GetRunTimes(),
-			Route: route,
-			IsRunInAsync: httpResp.IsRunInAsync,
-			Err: err,
-			CallbackPath: oneTask.GetCallbackPath(),
-		}
-		if callbackRespRaw != nil {
-			newLogDetailReq.RespRaw = string(callbackRespRaw)
-		}
-		if httpResp.IsSuccess {
-			newLogDetailReq.TaskStatus = task.StatusSuccess
-		} else if httpResp.IsRunInAsync {
-			newLogDetailReq.TaskStatus = task.StatusRunning
-		} else {
-			newLogDetailReq.TaskStatus = task.StatusFailed
-		}
-		taskLogDetail, err := task.NewTaskCallbackLogDetail(newLogDetailReq)
-		if err != nil {
-			logger.MustGetCallbackLogger().Error(ctx, err)
-			return
-		}
-		err = e.taskLogger.Log(ctx, task.MustNewTaskLog(task.TaskLogTypeCallback, taskLogDetail))
-		if err != nil {
-			logger.MustGetCallbackLogger().Error(ctx, err)
-			return
-		}
-	}()
-	callbackRespRaw, callbackErr = e.doReq(ctx, &doReqInput{
-		Path: oneTask.GetCallbackPath(),
-		Route: route,
-		TimeoutSec: timeoutSec,
+	httpResp := &httpproto.TaskCallbackResp{}
+	respRaw, err := e.doReq(ctx, &doReqInput{
+		Path: input.Path,
+		Method: string(method),
+		ContentType: string(contentType),
+		CallbackSrv: input.CallbackSrv,
+		Route: input.Route,
+		TimeoutSec: input.TimeoutSec,
 		ReqBytes: httpReqBytes,
 		Resp: httpResp,
 	})
-	if callbackErr != nil {
-		logger.MustGetCallbackLogger().Error(ctx, callbackErr)
-		return nil, callbackErr
+	if err != nil {
+		logger.MustGetCallbackLogger().Error(ctx, err)
+		return nil, respRaw, err
 	}
 
-	return task.NewCallbackSrvResp(httpResp.IsRunInAsync, httpResp.IsSuccess, httpResp.Extra), nil
+	return httpResp, respRaw, nil
 }
 
-func (e *HttpExec) HeartBeat(ctx context.Context, srv *task.TaskCallbackSrv) (*task.HeartBeatResp, error) {
+func (e *HttpRouteExec) HeartBeat(ctx context.Context, srv *task.TaskCallbackSrv, route *task.TaskCallbackSrvRoute, timeoutSec int) (*httpproto.HeartBeatResp, error) {
 	var (
 		httpReq = &httpproto.HeartBeatReq{
 			Cmd: httpproto.CallbackCmdTaskSrvHeartBeat,
@@ -121,44 +131,98 @@ func (e *HttpExec) HeartBeat(ctx context.Context, srv *task.TaskCallbackSrv) (*t
 		return nil, err
 	}
 
-	var (
-		wg sync.WaitGroup
-		noReplyRoutes []*task.TaskCallbackSrvRoute
-		replyRoutes []*task.TaskCallbackSrvRoute
-	)
-	for _, route := range srv.GetRoutes() {
-		wg.Add(1)
-		go func(route *task.TaskCallbackSrvRoute) {
-			defer wg.Done()
-
-			_, err = e.doReq(ctx, &doReqInput{
-				Route: route,
-				TimeoutSec: route.GetCallbackTimeoutSec(),
-				ReqBytes: httpReqBytes,
-				Resp: httpResp,
-			})
-			if err != nil {
-				logger.MustGetCallbackLogger().Error(ctx, err)
-				noReplyRoutes = append(noReplyRoutes, route)
-				return
-			}
-
-			if !httpResp.Pong {
-				logger.MustGetCallbackLogger().Warnf(ctx, "route(id:%s) heart beat resp.Pong is false", route.GetId())
-				noReplyRoutes = append(noReplyRoutes, route)
-				return
-			}
-
-			replyRoutes = append(replyRoutes, route)
-		}(route)
-	}
-	wg.Wait()
-
-	return task.NewHeartBeatResp(replyRoutes, noReplyRoutes), nil
+	_, err = e.doReq(ctx, &doReqInput{
+		Method: http.MethodPost,
+		ContentType: string(task.CallbackContentTypeJson),
+		CallbackSrv: srv,
+		Route: route,
+		TimeoutSec: timeoutSec,
+		ReqBytes: httpReqBytes,
+		Resp: httpResp,
+	})
+	if err != nil {
+		logger.MustGetCallbackLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	return httpResp, nil
+}
+
+// 不管任务的回调请求方法和格式,取消总是以json格式POST到回调路径
+func (e *HttpRouteExec) Cancel(ctx context.Context, input *RouteCancelInput) error {
+	httpReqBytes, err := json.Marshal(input.Req)
+	if err != nil {
+		logger.MustGetCallbackLogger().Error(ctx, err)
+		return err
+	}
+
+	_, err = e.doReq(ctx, &doReqInput{
+		Path: input.Path,
+		Method: http.MethodPost,
+		ContentType: string(task.CallbackContentTypeJson),
+		CallbackSrv: input.CallbackSrv,
+		Route: input.Route,
+		TimeoutSec: input.TimeoutSec,
+		ReqBytes: httpReqBytes,
+		Resp: &httpproto.TaskCancelResp{},
+	})
+	if err != nil {
+		logger.MustGetCallbackLogger().Error(ctx, err)
+		return err
+	}
+
+	return nil
+}
+
+// 请求发出之前的错误(建立连接失败,连接被拒绝,TLS握手失败等),回调服务一定没有收到请求.
+// 请求发出之后的超时和读取响应失败时回调服务可能已经处理,算作回调失败
+func (e *HttpRouteExec) IsTransportErr(err error) bool {
+	var notSentErr *reqNotSentErr
+	return errors.As(err, &notSentErr)
+}
+
+// 请求还没有写到连接上就失败的错误
+type reqNotSentErr struct {
+	err error
+}
+
+func (e *reqNotSentErr) Error() string {
+	return e.err.Error()
+}
+
+func (e *reqNotSentErr) Unwrap() error {
+	return e.err
+}
+
+// GET和表单格式的回调参数,字段名和json格式相同
+func toCallbackReqValues(req interface{}) (url.Values, error) {
+	j, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]interface{}{}
+	decoder := json.NewDecoder(bytes.NewReader(j))
+	decoder.UseNumber()
+	if err = decoder.Decode(&fields); err != nil {
+		return nil, err
+	}
+
+	values := url.Values{}
+	for name, value := range fields {
+		values.Set(name, fmt.Sprint(value))
+	}
+
+	return values, nil
 }
 
 type doReqInput struct {
 	Path string
+	Method string `validate:"required"`
+	// GET时为query string,没有请求体
+	ContentType string
+	// 按服务的配置签名,使用tls和带上静态header,可以为空
+	CallbackSrv *task.TaskCallbackSrv
 	Route *task.TaskCallbackSrvRoute `validate:"required"`
 	TimeoutSec int
 	ReqBytes []byte `validate:"required"`
@@ -166,7 +230,7 @@ type doReqInput struct {
 }
 
 func (i *doReqInput) Check() error {
-	if err := validator.New().Struct(i); err != nil {
+	if err := doReqValidator.Struct(i); err != nil {
 		return err
 	}
 	i.Path = strings.TrimSpace(i.Path)
@@ -176,27 +240,55 @@ func (i *doReqInput) Check() error {
 	return nil
 }
 
-func (e *HttpExec) doReq(ctx context.Context, input *doReqInput) (respRaw []byte, err error) {
+func (e *HttpRouteExec) doReq(ctx context.Context, input *doReqInput) (respRaw []byte, err error) {
 	if err = input.Check(); err != nil {
 		logger.MustGetCallbackLogger().Error(ctx, err)
 		return
 	}
 
+	// 共用的client不设置超时,每个请求单独控制
+	reqCtx, cancel := context.WithCancel(ctx)
+	if input.TimeoutSec > 0 {
+		reqCtx, cancel = context.WithTimeout(ctx, time.Duration(input.TimeoutSec) * time.Second)
+	}
+	defer cancel()
+
+	var body io.Reader
+	if input.Method != http.MethodGet {
+		body = bytes.NewReader(input.ReqBytes)
+	}
+
+	// 记录请求头是否已经写到连接上,用于区分请求发出前后的错误
+	var isReqWritten int32
+	reqCtx = httptrace.WithClientTrace(reqCtx, &httptrace.ClientTrace{
+		WroteHeaders: func() {
+			atomic.StoreInt32(&isReqWritten, 1)
+		},
+	})
+
 	reqUrl := fmt.Sprintf("%s://%s:%d%s", input.Route.GetSchema(), input.Route.GetHost(), input.Route.GetPort(), input.Path)
-	httpReq, err := http.NewRequest(
-		http.MethodPost,
-		reqUrl,
-		bytes.NewBuffer(input.ReqBytes),
-	)
+	httpReq, err := http.NewRequestWithContext(reqCtx, input.Method, reqUrl, body)
 	if err != nil {
 		logger.MustGetCallbackLogger().Error(ctx, err)
 		return
 	}
 
-	httpCli := http.Client{}
+	// 签名的内容,GET时为完整的query string
+	signPayload := input.ReqBytes
+	if input.Method == http.MethodGet {
+		if httpReq.URL.RawQuery != "" {
+			httpReq.URL.RawQuery += "&"
+		}
+		httpReq.URL.RawQuery += string(input.ReqBytes)
+		signPayload = []byte(httpReq.URL.RawQuery)
+	} else {
+		httpReq.Header.Set("Content-Type", input.ContentType)
+	}
 
-	if input.TimeoutSec > 0 {
-		httpCli.Timeout = time.Duration(input.TimeoutSec) * time.Second
+	if input.CallbackSrv != nil {
+		for name, value := range input.CallbackSrv.GetHeaders().GetAll() {
+			httpReq.Header.Set(name, value)
+		}
 	}
 
 	if traceCtx, ok := ctx.(*simpletracectx.Context); ok {
@@ -205,20 +297,39 @@ func (e *HttpExec) doReq(ctx context.Context, input *doReqInput) (respRaw []byte
 		httpReq.Header.Add(httpproto.HeaderSimpleTraceParentSpanId, traceCtx.GetParentSpanId())
 	}
 
-	logger.MustGetCallbackLogger().Infof(ctx, "post:%s param:%s", reqUrl, string(input.ReqBytes))
+	if input.CallbackSrv != nil && input.CallbackSrv.GetSecret() != "" {
+		if err = rpc.SignCallbackHttpReq(httpReq, input.CallbackSrv.GetSecret(), signPayload); err != nil {
+			logger.MustGetCallbackLogger().Error(ctx, err)
+			return
+		}
+	}
 
-	httpResp, err := httpCli.Do(httpReq)
+	logger.MustGetCallbackLogger().Infof(ctx, "%s:%s param:%s", strings.ToLower(input.Method), reqUrl, string(input.ReqBytes))
+
+	httpResp, err := e.getClient(input.CallbackSrv).Do(httpReq)
 	if err != nil {
 		logger.MustGetCallbackLogger().Error(ctx, err)
+		if atomic.LoadInt32(&isReqWritten) == 0 {
+			err = &reqNotSentErr{err: err}
+		}
 		return
 	}
+	defer httpResp.Body.Close()
 
-	respRaw, err = io.ReadAll(httpResp.Body)
+	// 多读一个字节用于判断是否超过上限
+	respRaw, err = io.ReadAll(io.LimitReader(httpResp.Body, e.maxRespBodyBytes + 1))
 	if err != nil {
 		logger.MustGetCallbackLogger().Error(ctx, err)
 		return
 	}
 
+	if int64(len(respRaw)) > e.maxRespBodyBytes {
+		respRaw = respRaw[:e.maxRespBodyBytes]
+		err = fmt.Errorf("callback resp body exceeds %d bytes", e.maxRespBodyBytes)
+		logger.MustGetCallbackLogger().Error(ctx, err)
+		return
+	}
+
 	logger.MustGetCallbackLogger().Infof(ctx, "resp:%s", string(respRaw))
 
 	err = json.Unmarshal(respRaw, &input.Resp)
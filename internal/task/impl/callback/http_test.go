@@ -0,0 +1,322 @@
+package callback
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/995933447/easytask/internal/task"
+	"github.com/995933447/easytask/internal/util/logger"
+	"github.com/995933447/easytask/pkg/rpc"
+	"github.com/995933447/easytask/pkg/rpc/proto/httpproto"
+	"github.com/go-playground/validator"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHttpRouteExecSign(t *testing.T) {
+	logger.Init(&logger.Conf{
+		LogDir: t.TempDir(),
+		FileSize: 1024 * 1024 * 100,
+	})
+
+	verifier := rpc.NewCallbackVerifier("secret", 0)
+	httpSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := verifier.VerifyHttpReq(r); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"is_success":true,"pong":true}`))
+	}))
+	defer httpSrv.Close()
+
+	addr := httpSrv.Listener.Addr().(*net.TCPAddr)
+	route := task.NewTaskCallbackSrvRoute("1", RouteSchemaHttp, addr.IP.String(), addr.Port, 3, true, 1, nil)
+	srv := task.NewTaskCallbackSrv("1", "srv", []*task.TaskCallbackSrvRoute{route}, true, nil, "", "secret", nil, task.RouteStrategyRandom, 0)
+	exec := NewHttpRouteExec(nil, nil, nil)
+
+	resp, _, err := exec.Callback(context.TODO(), &RouteCallbackInput{
+		CallbackSrv: srv,
+		Route: route,
+		TimeoutSec: 3,
+		Req: &httpproto.TaskCallbackReq{TaskId: "1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.IsSuccess {
+		t.Error("expect signed callback accepted")
+	}
+
+	heartBeatResp, err := exec.HeartBeat(context.TODO(), srv, route, 3)
+	if err != nil || !heartBeatResp.Pong {
+		t.Errorf("expect signed heart beat accepted, got err %v", err)
+	}
+
+	err = exec.Cancel(context.TODO(), &RouteCancelInput{
+		CallbackSrv: srv,
+		Route: route,
+		TimeoutSec: 3,
+		Req: &httpproto.TaskCancelReq{Cmd: httpproto.CallbackCmdTaskCancel, TaskId: "1", RunTimes: 1},
+	})
+	if err != nil {
+		t.Errorf("expect signed cancel accepted, got err %v", err)
+	}
+
+	// 没有签名的请求被拒绝,响应体不是json
+	unsigned := task.NewTaskCallbackSrv("1", "srv", []*task.TaskCallbackSrvRoute{route}, true, nil, "", "", nil, task.RouteStrategyRandom, 0)
+	if _, err = exec.HeartBeat(context.TODO(), unsigned, route, 3); err == nil {
+		t.Error("expect unsigned heart beat rejected")
+	}
+	if err = exec.Cancel(context.TODO(), &RouteCancelInput{CallbackSrv: unsigned, Route: route, Req: &httpproto.TaskCancelReq{}}); err == nil {
+		t.Error("expect unsigned cancel rejected")
+	}
+}
+
+func TestHttpRouteExecMethodAndHeaders(t *testing.T) {
+	logger.Init(&logger.Conf{
+		LogDir: t.TempDir(),
+		FileSize: 1024 * 1024 * 100,
+	})
+
+	type received struct {
+		method string
+		contentType string
+		auth string
+		tenant string
+		values url.Values
+	}
+	var last received
+	verifier := rpc.NewCallbackVerifier("secret", 0)
+	httpSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, err := verifier.VerifyHttpReq(r)
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		last = received{
+			method: r.Method,
+			contentType: r.Header.Get("Content-Type"),
+			auth: r.Header.Get("Authorization"),
+			tenant: r.Header.Get("X-Tenant"),
+		}
+		if r.Method == http.MethodGet || r.Header.Get("Content-Type") == string(task.CallbackContentTypeForm) {
+			last.values, _ = url.ParseQuery(string(payload))
+		} else {
+			var req httpproto.TaskCallbackReq
+			_ = json.Unmarshal(payload, &req)
+			last.values = url.Values{"task_id": {req.TaskId}, "arg": {req.Arg}}
+		}
+		_, _ = w.Write([]byte(`{"is_success":true,"pong":true}`))
+	}))
+	defer httpSrv.Close()
+
+	addr := httpSrv.Listener.Addr().(*net.TCPAddr)
+	route := task.NewTaskCallbackSrvRoute("1", RouteSchemaHttp, addr.IP.String(), addr.Port, 3, true, 1, nil)
+	headers := task.NewCallbackSrvHeaders(map[string]string{"X-Tenant": "a"}, map[string]string{"Authorization": "Bearer token"})
+	srv := task.NewTaskCallbackSrv("1", "srv", []*task.TaskCallbackSrvRoute{route}, true, nil, "", "secret", headers, task.RouteStrategyRandom, 0)
+	exec := NewHttpRouteExec(nil, nil, nil)
+
+	cases := []struct {
+		path string
+		method task.CallbackMethod
+		contentType task.CallbackContentType
+		expectContentType string
+	}{
+		{"/order", task.CallbackMethodNil, task.CallbackContentTypeNil, string(task.CallbackContentTypeJson)},
+		{"/order?source=easytask", task.CallbackMethodGet, task.CallbackContentTypeNil, ""},
+		{"/order", task.CallbackMethodPut, task.CallbackContentTypeForm, string(task.CallbackContentTypeForm)},
+	}
+	for i, c := range cases {
+		last = received{}
+		resp, _, err := exec.Callback(context.TODO(), &RouteCallbackInput{
+			Path: c.path,
+			Method: c.method,
+			ContentType: c.contentType,
+			CallbackSrv: srv,
+			Route: route,
+			TimeoutSec: 3,
+			Req: &httpproto.TaskCallbackReq{TaskId: "1", Arg: "a=1&b=2", RunTimes: 3},
+		})
+		if err != nil || !resp.IsSuccess {
+			t.Errorf("case %d: expect callback accepted, got %v", i, err)
+			continue
+		}
+		expectMethod := string(c.method)
+		if c.method == task.CallbackMethodNil {
+			expectMethod = http.MethodPost
+		}
+		if last.method != expectMethod || last.contentType != c.expectContentType {
+			t.Errorf("case %d: unexpected method %s content type %s", i, last.method, last.contentType)
+		}
+		if last.auth != "Bearer token" || last.tenant != "a" {
+			t.Errorf("case %d: expect static headers sent, got %+v", i, last)
+		}
+		if last.values.Get("task_id") != "1" || last.values.Get("arg") != "a=1&b=2" {
+			t.Errorf("case %d: unexpected callback params %v", i, last.values)
+		}
+	}
+	if last.values.Get("run_times") != "3" {
+		t.Errorf("expect form encoded run_times, got %v", last.values)
+	}
+}
+
+func TestHttpRouteExecTransport(t *testing.T) {
+	logger.Init(&logger.Conf{
+		LogDir: t.TempDir(),
+		FileSize: 1024 * 1024 * 100,
+	})
+
+	var newConns int32
+	httpSrv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/big" {
+			_, _ = w.Write([]byte(`{"extra":"` + strings.Repeat("a", 64) + `"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"is_success":true}`))
+	}))
+	httpSrv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&newConns, 1)
+		}
+	}
+	httpSrv.Start()
+	defer httpSrv.Close()
+
+	addr := httpSrv.Listener.Addr().(*net.TCPAddr)
+	route := task.NewTaskCallbackSrvRoute("1", RouteSchemaHttp, addr.IP.String(), addr.Port, 3, true, 1, nil)
+	exec := NewHttpRouteExec(&HttpTransportOpts{MaxRespBodyBytes: 32}, nil, nil)
+
+	for i := 0; i < 10; i++ {
+		if _, _, err := exec.Callback(context.TODO(), &RouteCallbackInput{
+			Route: route,
+			TimeoutSec: 3,
+			Req: &httpproto.TaskCallbackReq{TaskId: "1"},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := atomic.LoadInt32(&newConns); n != 1 {
+		t.Errorf("expect sequential callbacks reuse one connection, got %d connections", n)
+	}
+
+	_, respRaw, err := exec.Callback(context.TODO(), &RouteCallbackInput{
+		Path: "/big",
+		Route: route,
+		TimeoutSec: 3,
+		Req: &httpproto.TaskCallbackReq{TaskId: "1"},
+	})
+	if err == nil || len(respRaw) != 32 {
+		t.Errorf("expect resp body over limit rejected, got err %v and %d bytes", err, len(respRaw))
+	}
+}
+
+func TestHttpRouteExecIsTransportErr(t *testing.T) {
+	logger.Init(&logger.Conf{
+		LogDir: t.TempDir(),
+		FileSize: 1024 * 1024 * 100,
+	})
+
+	httpSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(2 * time.Second)
+		_, _ = w.Write([]byte(`{"is_success":true}`))
+	}))
+	addr := httpSrv.Listener.Addr().(*net.TCPAddr)
+	route := task.NewTaskCallbackSrvRoute("1", RouteSchemaHttp, addr.IP.String(), addr.Port, 1, true, 1, nil)
+	exec := NewHttpRouteExec(nil, nil, nil)
+
+	// 请求已经发出,回调服务可能已经处理,超时不算传输错误
+	_, _, err := exec.Callback(context.TODO(), &RouteCallbackInput{
+		Route: route,
+		TimeoutSec: 1,
+		Req: &httpproto.TaskCallbackReq{TaskId: "1"},
+	})
+	if err == nil || exec.IsTransportErr(err) {
+		t.Errorf("expect resp timeout not transport error, got %v", err)
+	}
+
+	httpSrv.Close()
+	_, _, err = exec.Callback(context.TODO(), &RouteCallbackInput{
+		Route: route,
+		TimeoutSec: 1,
+		Req: &httpproto.TaskCallbackReq{TaskId: "1"},
+	})
+	if err == nil || !exec.IsTransportErr(err) {
+		t.Errorf("expect connection refused transport error, got %v", err)
+	}
+}
+
+func newBenchCallbackSrv(b *testing.B) (*httptest.Server, *task.TaskCallbackSrvRoute) {
+	logger.Init(&logger.Conf{
+		LogDir: b.TempDir(),
+		FileSize: 1024 * 1024 * 100,
+		Level: "error",
+	})
+
+	httpSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		_, _ = w.Write([]byte(`{"is_success":true}`))
+	}))
+	addr := httpSrv.Listener.Addr().(*net.TCPAddr)
+	return httpSrv, task.NewTaskCallbackSrvRoute("1", RouteSchemaHttp, addr.IP.String(), addr.Port, 3, true, 1, nil)
+}
+
+// 改为共用连接池之前的回调方式:每次请求新建client和validator,不关闭响应体,连接无法复用
+func BenchmarkHttpCallbackClientPerReq(b *testing.B) {
+	httpSrv, route := newBenchCallbackSrv(b)
+	defer httpSrv.Close()
+
+	reqBytes, err := json.Marshal(&httpproto.TaskCallbackReq{TaskId: "1"})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		input := &doReqInput{Route: route, ReqBytes: reqBytes, Resp: &httpproto.TaskCallbackResp{}}
+		if err = validator.New().Struct(input); err != nil {
+			b.Fatal(err)
+		}
+		httpReq, err := http.NewRequest(http.MethodPost, httpSrv.URL, bytes.NewBuffer(reqBytes))
+		if err != nil {
+			b.Fatal(err)
+		}
+		httpCli := http.Client{Timeout: 3 * time.Second}
+		httpResp, err := httpCli.Do(httpReq)
+		if err != nil {
+			b.Fatal(err)
+		}
+		respRaw, err := io.ReadAll(httpResp.Body)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err = json.Unmarshal(respRaw, input.Resp); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkHttpRouteExecCallback(b *testing.B) {
+	httpSrv, route := newBenchCallbackSrv(b)
+	defer httpSrv.Close()
+
+	exec := NewHttpRouteExec(nil, nil, nil)
+	input := &RouteCallbackInput{
+		Route: route,
+		TimeoutSec: 3,
+		Req: &httpproto.TaskCallbackReq{TaskId: "1"},
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := exec.Callback(context.TODO(), input); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
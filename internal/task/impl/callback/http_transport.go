@@ -0,0 +1,80 @@
+package callback
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultHttpMaxIdleConns = 1000
+	defaultHttpMaxIdleConnsPerHost = 100
+	defaultHttpIdleConnTimeout = 90 * time.Second
+	defaultHttpDialTimeout = 5 * time.Second
+	defaultHttpTlsHandshakeTimeout = 10 * time.Second
+	defaultHttpMaxRespBodyBytes = 4 * 1024 * 1024
+)
+
+// 回调http节点的连接参数,所有回调共用连接池.零值字段使用默认值
+type HttpTransportOpts struct {
+	// 所有节点最多保持的空闲连接数,默认1000
+	MaxIdleConns int
+	// 每个节点最多保持的空闲连接数,默认100
+	MaxIdleConnsPerHost int
+	// 空闲连接保持时间,默认90秒
+	IdleConnTimeout time.Duration
+	// 建立连接超时时间,默认5秒
+	DialTimeout time.Duration
+	// tls握手超时时间,默认10秒
+	TlsHandshakeTimeout time.Duration
+	// 发出请求后等待响应header的超时时间,默认不限制(只受回调超时时间限制)
+	ResponseHeaderTimeout time.Duration
+	// 响应体最大字节数,超过时按回调出错处理,默认4MB
+	MaxRespBodyBytes int64
+}
+
+func (o *HttpTransportOpts) withDefaults() *HttpTransportOpts {
+	opts := HttpTransportOpts{}
+	if o != nil {
+		opts = *o
+	}
+	if opts.MaxIdleConns <= 0 {
+		opts.MaxIdleConns = defaultHttpMaxIdleConns
+	}
+	if opts.MaxIdleConnsPerHost <= 0 {
+		opts.MaxIdleConnsPerHost = defaultHttpMaxIdleConnsPerHost
+	}
+	if opts.IdleConnTimeout <= 0 {
+		opts.IdleConnTimeout = defaultHttpIdleConnTimeout
+	}
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = defaultHttpDialTimeout
+	}
+	if opts.TlsHandshakeTimeout <= 0 {
+		opts.TlsHandshakeTimeout = defaultHttpTlsHandshakeTimeout
+	}
+	if opts.MaxRespBodyBytes <= 0 {
+		opts.MaxRespBodyBytes = defaultHttpMaxRespBodyBytes
+	}
+	return &opts
+}
+
+// tlsConfig为空时使用系统根证书
+func newHttpTransport(opts *HttpTransportOpts, tlsConfig *tls.Config) *http.Transport {
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout: opts.DialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2: true,
+		MaxIdleConns: opts.MaxIdleConns,
+		MaxIdleConnsPerHost: opts.MaxIdleConnsPerHost,
+		IdleConnTimeout: opts.IdleConnTimeout,
+		TLSHandshakeTimeout: opts.TlsHandshakeTimeout,
+		ResponseHeaderTimeout: opts.ResponseHeaderTimeout,
+		ExpectContinueTimeout: time.Second,
+		TLSClientConfig: tlsConfig,
+	}
+}
@@ -0,0 +1,155 @@
+package callback
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/995933447/easytask/internal/task"
+	"github.com/995933447/easytask/internal/util/logger"
+	"github.com/995933447/easytask/pkg/rpc/proto/httpproto"
+	"github.com/go-redis/redis/v8"
+	"hash/crc32"
+	"io"
+	"net"
+	"time"
+)
+
+const RouteSchemaRedis = "redis"
+
+const (
+	// stream消息中回调参数的字段,值为json格式的httpproto.TaskCallbackReq
+	RedisStreamFieldPayload = "payload"
+	// stream消息中注册任务时指定的回调路径的字段
+	RedisStreamFieldCallbackPath = "callback_path"
+	// stream消息中本次执行选中的节点(host:port),广播和分片执行时消费者按该字段认领自己的消息
+	RedisStreamFieldRoute = "route"
+)
+
+// 把到期任务投递到配置文件redis.nodes组成的redis组的stream中,不直接回调任务服务.
+// 节点只代表消费者,不代表redis地址.投递成功按异步执行处理,消费者执行完成后调用确认任务api确认结果
+type RedisStreamRouteExec struct {
+	// 和选主共用的redis节点,按stream key选择节点,同一个stream总是投递到同一个节点
+	clients []*redis.Client
+}
+
+// clients为空时投递和健康检查都会失败
+func NewRedisStreamRouteExec(clients []*redis.Client) *RedisStreamRouteExec {
+	return &RedisStreamRouteExec{
+		clients: clients,
+	}
+}
+
+var _ RouteExec = (*RedisStreamRouteExec)(nil)
+
+func (e *RedisStreamRouteExec) getClient(streamKey string) (*redis.Client, error) {
+	if len(e.clients) == 0 {
+		return nil, errors.New("redis nodes are not configured, can not publish to redis stream")
+	}
+	return e.clients[crc32.ChecksumIEEE([]byte(streamKey)) % uint32(len(e.clients))], nil
+}
+
+func (e *RedisStreamRouteExec) publish(ctx context.Context, srv *task.TaskCallbackSrv, route *task.TaskCallbackSrvRoute, path string, req interface{}, timeoutSec int) (string, error) {
+	if srv == nil {
+		return "", errors.New("callback server is required to publish to redis stream")
+	}
+
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+
+	streamKey := srv.GetStreamKey()
+	client, err := e.getClient(streamKey)
+	if err != nil {
+		return "", err
+	}
+
+	callCtx, cancel := e.newCallCtx(ctx, timeoutSec)
+	defer cancel()
+
+	logger.MustGetCallbackLogger().Infof(ctx, "xadd:%s route:%s:%d param:%s", streamKey, route.GetHost(), route.GetPort(), string(payload))
+
+	// 按近似长度裁剪,消费者长时间不消费时stream不会无限增长
+	return client.XAdd(callCtx, &redis.XAddArgs{
+		Stream: streamKey,
+		MaxLen: srv.GetStreamMaxLen(),
+		Approx: true,
+		Values: map[string]interface{}{
+			RedisStreamFieldPayload: string(payload),
+			RedisStreamFieldCallbackPath: path,
+			RedisStreamFieldRoute: fmt.Sprintf("%s:%d", route.GetHost(), route.GetPort()),
+		},
+	}).Result()
+}
+
+func (e *RedisStreamRouteExec) newCallCtx(ctx context.Context, timeoutSec int) (context.Context, context.CancelFunc) {
+	if timeoutSec > 0 {
+		return context.WithTimeout(ctx, time.Duration(timeoutSec) * time.Second)
+	}
+	return context.WithCancel(ctx)
+}
+
+func (e *RedisStreamRouteExec) Callback(ctx context.Context, input *RouteCallbackInput) (*httpproto.TaskCallbackResp, []byte, error) {
+	msgId, err := e.publish(ctx, input.CallbackSrv, input.Route, input.Path, input.Req, input.TimeoutSec)
+	if err != nil {
+		logger.MustGetCallbackLogger().Error(ctx, err)
+		return nil, nil, err
+	}
+
+	// 投递成功只代表任务已经进入队列,执行结果由消费者异步确认
+	resp := &httpproto.TaskCallbackResp{
+		IsRunInAsync: true,
+		Extra: msgId,
+	}
+
+	respRaw, err := json.Marshal(resp)
+	if err != nil {
+		logger.MustGetCallbackLogger().Error(ctx, err)
+		return nil, nil, err
+	}
+
+	return resp, respRaw, nil
+}
+
+// 检查服务的stream所在的redis节点是否可用,消费者是否存活由消费者自己负责
+func (e *RedisStreamRouteExec) HeartBeat(ctx context.Context, srv *task.TaskCallbackSrv, _ *task.TaskCallbackSrvRoute, timeoutSec int) (*httpproto.HeartBeatResp, error) {
+	if srv == nil {
+		err := errors.New("callback server is required to check redis stream")
+		logger.MustGetCallbackLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	client, err := e.getClient(srv.GetStreamKey())
+	if err != nil {
+		logger.MustGetCallbackLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	callCtx, cancel := e.newCallCtx(ctx, timeoutSec)
+	defer cancel()
+
+	if err = client.Ping(callCtx).Err(); err != nil {
+		logger.MustGetCallbackLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	return &httpproto.HeartBeatResp{
+		Pong: true,
+	}, nil
+}
+
+// 取消消息投递到同一个stream中,消费者按payload中的cmd区分执行和取消
+func (e *RedisStreamRouteExec) Cancel(ctx context.Context, input *RouteCancelInput) error {
+	if _, err := e.publish(ctx, input.CallbackSrv, input.Route, input.Path, input.Req, input.TimeoutSec); err != nil {
+		logger.MustGetCallbackLogger().Error(ctx, err)
+		return err
+	}
+	return nil
+}
+
+// 连接失败,超时等没有拿到redis响应的错误
+func (e *RedisStreamRouteExec) IsTransportErr(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, context.DeadlineExceeded)
+}
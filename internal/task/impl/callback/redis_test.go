@@ -0,0 +1,150 @@
+package callback
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/995933447/easytask/internal/task"
+	"github.com/995933447/easytask/internal/util/logger"
+	"github.com/995933447/easytask/pkg/rpc/proto/httpproto"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"testing"
+)
+
+func TestRedisStreamRouteExec(t *testing.T) {
+	logger.Init(&logger.Conf{
+		LogDir: t.TempDir(),
+		FileSize: 1024 * 1024 * 100,
+	})
+
+	redisSrv := miniredis.RunT(t)
+	redisSrv.RequireAuth("secret")
+
+	// 节点代表消费者,和redis地址无关
+	route := task.NewTaskCallbackSrvRoute("1", RouteSchemaRedis, "10.0.0.1", 8080, 3, true, 1, nil)
+	srv := task.NewTaskCallbackSrv("1", "order", []*task.TaskCallbackSrvRoute{route}, true, nil, "", "", nil, task.RouteStrategyRandom, 0)
+	exec := NewRedisStreamRouteExec([]*redis.Client{
+		redis.NewClient(&redis.Options{Addr: redisSrv.Addr(), Password: "secret"}),
+	})
+
+	resp, _, err := exec.Callback(context.TODO(), &RouteCallbackInput{
+		Path: "/order/close",
+		CallbackSrv: srv,
+		Route: route,
+		TimeoutSec: 3,
+		Req: &httpproto.TaskCallbackReq{
+			Cmd: httpproto.CallbackCmdTaskCallback,
+			TaskId: "task-1",
+			RunTimes: 2,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.IsRunInAsync || resp.IsSuccess {
+		t.Fatalf("expect published task run in async, got %+v", resp)
+	}
+
+	msgs, err := redisSrv.Stream("easytask:task_callback:order")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 || msgs[0].ID != resp.Extra {
+		t.Fatalf("expect one message with id %s, got %+v", resp.Extra, msgs)
+	}
+
+	values := map[string]string{}
+	for i := 0; i + 1 < len(msgs[0].Values); i += 2 {
+		values[msgs[0].Values[i]] = msgs[0].Values[i + 1]
+	}
+	if values[RedisStreamFieldCallbackPath] != "/order/close" {
+		t.Errorf("unexpected callback path %s", values[RedisStreamFieldCallbackPath])
+	}
+	if values[RedisStreamFieldRoute] != "10.0.0.1:8080" {
+		t.Errorf("unexpected route %s", values[RedisStreamFieldRoute])
+	}
+	var req httpproto.TaskCallbackReq
+	if err = json.Unmarshal([]byte(values[RedisStreamFieldPayload]), &req); err != nil {
+		t.Fatal(err)
+	}
+	if req.TaskId != "task-1" || req.RunTimes != 2 {
+		t.Errorf("unexpected payload %+v", req)
+	}
+
+	// 取消消息投递到同一个stream,按cmd区分
+	err = exec.Cancel(context.TODO(), &RouteCancelInput{
+		Path: "/order/close",
+		CallbackSrv: srv,
+		Route: route,
+		TimeoutSec: 3,
+		Req: &httpproto.TaskCancelReq{
+			Cmd: httpproto.CallbackCmdTaskCancel,
+			TaskId: "task-1",
+			RunTimes: 2,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msgs, err = redisSrv.Stream("easytask:task_callback:order"); err != nil || len(msgs) != 2 {
+		t.Fatalf("expect cancel message published, got %+v, err %v", msgs, err)
+	}
+	for i := 0; i + 1 < len(msgs[1].Values); i += 2 {
+		values[msgs[1].Values[i]] = msgs[1].Values[i + 1]
+	}
+	var cancelReq httpproto.TaskCancelReq
+	if err = json.Unmarshal([]byte(values[RedisStreamFieldPayload]), &cancelReq); err != nil {
+		t.Fatal(err)
+	}
+	if cancelReq.Cmd != httpproto.CallbackCmdTaskCancel || cancelReq.TaskId != "task-1" || cancelReq.RunTimes != 2 {
+		t.Errorf("unexpected cancel payload %+v", cancelReq)
+	}
+
+	heartBeatResp, err := exec.HeartBeat(context.TODO(), srv, route, 3)
+	if err != nil || !heartBeatResp.Pong {
+		t.Errorf("expect heart beat pong, got err %v", err)
+	}
+
+	redisSrv.Close()
+	if _, err = exec.HeartBeat(context.TODO(), srv, route, 1); err == nil || !exec.IsTransportErr(err) {
+		t.Errorf("expect transport error after redis closed, got %v", err)
+	}
+
+	if _, _, err = NewRedisStreamRouteExec(nil).Callback(context.TODO(), &RouteCallbackInput{CallbackSrv: srv, Route: route, Req: &httpproto.TaskCallbackReq{}}); err == nil {
+		t.Error("expect publish failed without redis nodes")
+	}
+}
+
+func TestRedisStreamRouteExecMaxLen(t *testing.T) {
+	logger.Init(&logger.Conf{
+		LogDir: t.TempDir(),
+		FileSize: 1024 * 1024 * 100,
+	})
+
+	redisSrv := miniredis.RunT(t)
+	route := task.NewTaskCallbackSrvRoute("1", RouteSchemaRedis, "10.0.0.1", 8080, 3, true, 1, nil)
+	srv := task.NewTaskCallbackSrv("1", "order", []*task.TaskCallbackSrvRoute{route}, true, nil, "", "", nil, task.RouteStrategyNil, 2)
+	exec := NewRedisStreamRouteExec([]*redis.Client{
+		redis.NewClient(&redis.Options{Addr: redisSrv.Addr()}),
+	})
+
+	for i := 0; i < 5; i++ {
+		if _, _, err := exec.Callback(context.TODO(), &RouteCallbackInput{
+			CallbackSrv: srv,
+			Route: route,
+			TimeoutSec: 3,
+			Req: &httpproto.TaskCallbackReq{TaskId: "task-1", RunTimes: i + 1},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// redis按近似长度裁剪,实际长度可能略大于上限,miniredis按精确长度裁剪
+	msgs, err := redisSrv.Stream("easytask:task_callback:order")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 {
+		t.Errorf("expect stream trimmed to 2 messages, got %d", len(msgs))
+	}
+}
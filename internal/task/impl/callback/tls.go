@@ -0,0 +1,53 @@
+package callback
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// 回调https节点使用的tls配置.caFile为空时使用系统根证书,certFile和keyFile都不为空时开启双向认证,
+// serverName不为空时用它校验节点证书而不是节点host,minVersion为1.0,1.1,1.2或1.3,为空时使用go的默认值
+func NewTlsConfig(caFile, certFile, keyFile, serverName, minVersion string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName: serverName,
+	}
+
+	if caFile != "" {
+		caPem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPem) {
+			return nil, fmt.Errorf("no certificate found in ca file %s", caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if minVersion != "" {
+		version, ok := tlsVersions[minVersion]
+		if !ok {
+			return nil, fmt.Errorf("invalid tls min version %s", minVersion)
+		}
+		tlsConfig.MinVersion = version
+	}
+
+	return tlsConfig, nil
+}
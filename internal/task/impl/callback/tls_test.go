@@ -0,0 +1,166 @@
+package callback
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/995933447/easytask/internal/task"
+	"github.com/995933447/easytask/internal/util/logger"
+	"github.com/995933447/easytask/pkg/rpc/proto/httpproto"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key *ecdsa.PrivateKey
+	certFile string
+	keyFile string
+}
+
+// 生成自签名的ca(parent为空时)或者由parent签发的证书,写入临时目录
+func newTestCert(t *testing.T, name string, parent *testCert, dnsNames []string, extKeyUsage x509.ExtKeyUsage) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject: pkix.Name{CommonName: name},
+		NotBefore: time.Now().Add(-time.Hour),
+		NotAfter: time.Now().Add(time.Hour),
+		DNSNames: dnsNames,
+		KeyUsage: x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage: []x509.ExtKeyUsage{extKeyUsage},
+	}
+	signerCert, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+	} else {
+		signerCert, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signerCert, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	c := &testCert{
+		cert: cert,
+		key: key,
+		certFile: filepath.Join(dir, name + ".crt"),
+		keyFile: filepath.Join(dir, name + ".key"),
+	}
+	if err = os.WriteFile(c.certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(c.keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestHttpRouteExecTls(t *testing.T) {
+	logger.Init(&logger.Conf{
+		LogDir: t.TempDir(),
+		FileSize: 1024 * 1024 * 100,
+	})
+
+	var (
+		ca = newTestCert(t, "ca", nil, nil, x509.ExtKeyUsageAny)
+		srvCert = newTestCert(t, "server", ca, []string{"easytask.test"}, x509.ExtKeyUsageServerAuth)
+		cliCert = newTestCert(t, "client", ca, nil, x509.ExtKeyUsageClientAuth)
+	)
+
+	srvKeyPair, err := tls.LoadX509KeyPair(srvCert.certFile, srvCert.keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+
+	httpSrv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"is_success":true,"pong":true}`))
+	}))
+	httpSrv.TLS = &tls.Config{
+		Certificates: []tls.Certificate{srvKeyPair},
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs: clientCAs,
+	}
+	httpSrv.StartTLS()
+	defer httpSrv.Close()
+
+	addr := httpSrv.Listener.Addr().(*net.TCPAddr)
+	route := task.NewTaskCallbackSrvRoute("1", RouteSchemaHttps, addr.IP.String(), addr.Port, 3, true, 1, nil)
+	newSrv := func(name string) *task.TaskCallbackSrv {
+		return task.NewTaskCallbackSrv("1", name, []*task.TaskCallbackSrvRoute{route}, true, nil, "", "", nil, task.RouteStrategyRandom, 0)
+	}
+	callback := func(exec *HttpRouteExec, srv *task.TaskCallbackSrv) error {
+		_, _, err := exec.Callback(context.TODO(), &RouteCallbackInput{
+			CallbackSrv: srv,
+			Route: route,
+			TimeoutSec: 3,
+			Req: &httpproto.TaskCallbackReq{TaskId: "1"},
+		})
+		return err
+	}
+
+	mtlsConfig, err := NewTlsConfig(ca.certFile, cliCert.certFile, cliCert.keyFile, "easytask.test", "1.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	noCertConfig, err := NewTlsConfig(ca.certFile, "", "", "easytask.test", "1.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = callback(NewHttpRouteExec(nil, mtlsConfig, nil), newSrv("order")); err != nil {
+		t.Errorf("expect mtls callback succeeded, got %v", err)
+	}
+
+	exec := NewHttpRouteExec(nil, noCertConfig, nil)
+	if err = callback(exec, newSrv("order")); err == nil {
+		t.Error("expect callback without client cert rejected")
+	}
+	if _, err = exec.HeartBeat(context.TODO(), newSrv("order"), route, 3); err == nil {
+		t.Error("expect heart beat without client cert rejected")
+	}
+
+	// 单独配置的服务使用自己的tls配置,其他服务使用默认配置
+	exec = NewHttpRouteExec(nil, nil, map[string]*tls.Config{"order": mtlsConfig})
+	if err = callback(exec, newSrv("order")); err != nil {
+		t.Errorf("expect callback with service tls config succeeded, got %v", err)
+	}
+	if resp, err := exec.HeartBeat(context.TODO(), newSrv("order"), route, 3); err != nil || !resp.Pong {
+		t.Errorf("expect heart beat with service tls config succeeded, got %v", err)
+	}
+	if err = callback(exec, newSrv("user")); err == nil {
+		t.Error("expect callback with system roots rejected by self signed server")
+	}
+
+	if _, err = NewTlsConfig("", "", "", "", "1.4"); err == nil {
+		t.Error("expect invalid min version error")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/995933447/easytask/internal/task"
+	"github.com/995933447/easytask/internal/util/crypt"
 	"gorm.io/plugin/soft_delete"
 	"strconv"
 )
@@ -15,6 +16,7 @@ const (
 	schedModeTimeSpec
 	schedModeTimeCron
 	schedModeTimeInterval
+	schedModeFixedDelay
 )
 
 const (
@@ -22,6 +24,66 @@ const (
 	statusRunning
 	statusSuccess
 	statusFailed
+	statusSkipped
+)
+
+const (
+	misfirePolicyFireOnce = iota
+	misfirePolicyFireAll
+	misfirePolicySkip
+	misfirePolicySkipIfExpired
+)
+
+const (
+	misfireDecisionNil = iota
+	misfireDecisionFireOnce
+	misfireDecisionCatchUp
+	misfireDecisionSkip
+)
+
+const (
+	concurrencyPolicyAllow = iota
+	concurrencyPolicyForbid
+	concurrencyPolicyQueue
+)
+
+const (
+	skipReasonNil = iota
+	skipReasonMisfire
+	skipReasonConcurrencyForbidden
+)
+
+const (
+	dispatchModeSingle = iota
+	dispatchModeBroadcast
+)
+
+const (
+	broadcastSuccessRuleAll = iota
+	broadcastSuccessRuleAny
+)
+
+const (
+	routeStrategyNil = iota
+	routeStrategyRandom
+	routeStrategyRoundRobin
+	routeStrategyWeightedRandom
+	routeStrategyConsistentHash
+	routeStrategyLeastRecentlyUsed
+	routeStrategyLeastInFlight
+)
+
+const (
+	triggerTypeSched = iota
+	triggerTypeWorkflow
+	triggerTypeManual
+)
+
+const (
+	workflowEdgeConditionNil = iota
+	workflowEdgeConditionOnSuccess
+	workflowEdgeConditionOnFailure
+	workflowEdgeConditionAlways
 )
 
 //go:generate structfieldconstgen -findPkgPath ../mysql -outFile ../mysql/db_field.go -prefix DbField
@@ -41,13 +103,35 @@ type TaskModel struct {
 	PlanSchedNextAt int64 `gorm:"comment:'下次计划执行时间'"`
 	TimeCronExpr string `gorm:"comment:'定时cron表达式'"`
 	TimeIntervalSec int `gorm:"comment:'执行间隔'"`
-	SchedMode int `gorm:"comment:'执行模式.1.指定时间,2.cron表达式,3.间隔执行'"`
+	SchedMode int `gorm:"comment:'执行模式.1.指定时间,2.cron表达式,3.间隔执行,4.固定延时'"`
 	CallbackSrvId uint64 `gorm:"comment:'回调服务id'"`
 	RunTimes int `gorm:"comment:'已执行次数'"`
 	AllowMaxRunTimes int `gorm:"comment:'最大可执行次数'"`
-	MaxRunTimeSec int `gorm:"comment:'任务最长运行时间'"`
+	MaxRunTimeSec int `gorm:"comment:'任务最长运行时间,0不限制'"`
+	IsMaxRunTimeSecSet bool `gorm:"comment:'max_run_time_sec是否由注册任务时指定,旧版本写入的执行间隔不作为最长运行时间'"`
 	CallbackPath string `gorm:"comment:'回调路径'"`
+	CallbackMethod string `gorm:"comment:'回调http节点的请求方法,为空为POST'"`
+	CallbackContentType string `gorm:"comment:'回调http节点的请求体格式,为空为application/json'"`
 	BizId string `gorm:"index:task_biz,unique;comment:'用于指定任务唯一业务id'"`
+	LastSuccessAt int64 `gorm:"comment:'上次成功时间'"`
+	LastFailedAt int64 `gorm:"comment:'上次失败时间'"`
+	RetryMaxAttempts int `gorm:"comment:'失败最大重试次数,0不重试'"`
+	RetryInitialDelaySec int `gorm:"comment:'首次重试延迟'"`
+	RetryMultiplier float64 `gorm:"comment:'重试延迟倍数'"`
+	RetryMaxDelaySec int `gorm:"comment:'最大重试延迟'"`
+	RetryJitter float64 `gorm:"comment:'重试延迟随机抖动比例'"`
+	TimeZone string `gorm:"comment:'cron表达式时区,为空使用服务器本地时区'"`
+	MisfirePolicy int `gorm:"comment:'错过触发策略:0.补触发一次,1.逐个补触发,2.跳过,3.超过阈值跳过'"`
+	MisfireMaxCatchUpTimes int `gorm:"comment:'最多补触发次数,0不限制'"`
+	MisfireThresholdSec int `gorm:"comment:'错过超过该秒数则跳过'"`
+	ConcurrencyPolicy int `gorm:"comment:'并发策略:0.允许并发,1.上次未结束则跳过,2.上次未结束则排队'"`
+	IsPaused bool `gorm:"comment:'是否暂停调度'"`
+	DispatchMode int `gorm:"comment:'回调方式:0.随机一个节点,1.广播所有节点'"`
+	BroadcastSuccessRule int `gorm:"comment:'广播执行成功规则:0.全部成功,1.任一成功'"`
+	ShardTotal int `gorm:"comment:'分片总数,0代表不分片'"`
+	RouteStrategy int `gorm:"comment:'选择回调节点的策略:0.没有指定,1.随机,2.轮询,3.加权随机,4.按biz_id一致性哈希,5.最近最少使用,6.最少执行中'"`
+	LabelSelector Labels `gorm:"comment:'回调节点标签选择器'"`
+	FailoverMaxAttempts int `gorm:"comment:'回调传输层失败时最多换节点重试的次数'"`
 }
 
 func (*TaskModel) TableName() string {
@@ -55,24 +139,111 @@ func (*TaskModel) TableName() string {
 }
 
 func (t *TaskModel) toEntity(callbackSrv *task.TaskCallbackSrv) (*task.Task, error) {
+	req, err := t.toNewTaskReq(callbackSrv)
+	if err != nil {
+		return nil, err
+	}
+	return task.NewTask(req)
+}
+
+// 从触发队列中捞出的任务,重试次数以触发记录为准
+func (t *TaskModel) toTriggeredEntity(callbackSrv *task.TaskCallbackSrv, trigger *TaskTriggerModel) (*task.Task, error) {
+	req, err := t.toNewTaskReq(callbackSrv)
+	if err != nil {
+		return nil, err
+	}
+	req.Trigger, err = trigger.toEntity()
+	if err != nil {
+		return nil, err
+	}
+	req.RetryAttempt = trigger.Attempt
+	if trigger.HasArg {
+		req.Arg = trigger.Arg
+	}
+	return task.NewTask(req)
+}
+
+func (t *TaskModel) toNewTaskReq(callbackSrv *task.TaskCallbackSrv) (*task.NewTaskReq, error) {
 	entitySchedMode, err := t.toEntitySchedMode()
 	if err != nil {
 		return nil, err
 	}
-	return task.NewTask(&task.NewTaskReq{
+
+	misfirePolicy, err := toTaskEntityMisfirePolicy(t.MisfirePolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	concurrencyPolicy, err := toTaskEntityConcurrencyPolicy(t.ConcurrencyPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	dispatchMode, err := toTaskEntityDispatchMode(t.DispatchMode)
+	if err != nil {
+		return nil, err
+	}
+
+	broadcastSuccessRule, err := toTaskEntityBroadcastSuccessRule(t.BroadcastSuccessRule)
+	if err != nil {
+		return nil, err
+	}
+
+	routeStrategy, err := toTaskEntityRouteStrategy(t.RouteStrategy)
+	if err != nil {
+		return nil, err
+	}
+
+	var timeSpecAt int64
+	if t.SchedMode == schedModeTimeSpec {
+		timeSpecAt = t.PlanSchedNextAt
+	}
+
+	// 旧版本注册的任务max_run_time_sec被写成了执行间隔,重新注册前不按它判定超时
+	var maxRunTimeSec int
+	if t.IsMaxRunTimeSecSet {
+		maxRunTimeSec = t.MaxRunTimeSec
+	}
+
+	return &task.NewTaskReq{
 		Id: t.toEntityId(),
 		Name: t.Name,
 		Arg: t.Arg,
 		RunTimes: t.RunTimes,
 		LastRunAt: t.LastRunAt,
 		AllowMaxRunTimes: t.AllowMaxRunTimes,
-		MaxRunTimeSec: t.MaxRunTimeSec,
+		MaxRunTimeSec: maxRunTimeSec,
 		CallbackSrv: callbackSrv,
+		CallbackPath: t.CallbackPath,
+		CallbackMethod: task.CallbackMethod(t.CallbackMethod),
+		CallbackContentType: task.CallbackContentType(t.CallbackContentType),
 		TimeIntervalSec: t.TimeIntervalSec,
 		TimeCronExpr: t.TimeCronExpr,
+		TimeSpecAt: timeSpecAt,
 		SchedMode: entitySchedMode,
 		BizId: t.BizId,
-	})
+		RetryPolicy: t.toEntityRetryPolicy(),
+		TimeZone: t.TimeZone,
+		PlanSchedNextAt: t.PlanSchedNextAt,
+		MisfirePolicy: misfirePolicy,
+		MisfireMaxCatchUpTimes: t.MisfireMaxCatchUpTimes,
+		MisfireThresholdSec: t.MisfireThresholdSec,
+		ConcurrencyPolicy: concurrencyPolicy,
+		IsPaused: t.IsPaused,
+		DispatchMode: dispatchMode,
+		BroadcastSuccessRule: broadcastSuccessRule,
+		ShardTotal: t.ShardTotal,
+		RouteStrategy: routeStrategy,
+		LabelSelector: t.LabelSelector,
+		FailoverMaxAttempts: t.FailoverMaxAttempts,
+	}, nil
+}
+
+func (t *TaskModel) toEntityRetryPolicy() *task.RetryPolicy {
+	if t.RetryMaxAttempts <= 0 {
+		return nil
+	}
+	return task.NewRetryPolicy(t.RetryMaxAttempts, t.RetryInitialDelaySec, t.RetryMultiplier, t.RetryMaxDelaySec, t.RetryJitter)
 }
 
 func (t *TaskModel) toEntitySchedMode() (task.SchedMode, error) {
@@ -91,6 +262,8 @@ func toTaskModelSchedMode(entitySchedMode task.SchedMode) (int, error) {
 		return schedModeTimeSpec, nil
 	case task.SchedModeTimeInterval:
 		return schedModeTimeInterval, nil
+	case task.SchedModeFixedDelay:
+		return schedModeFixedDelay, nil
 	}
 	return schedModeNil, errors.New("invalid schedule mode")
 }
@@ -103,10 +276,166 @@ func toTaskEntitySchedMode(schedMode int) (task.SchedMode, error) {
 		return task.SchedModeTimeSpec, nil
 	case schedModeTimeInterval:
 		return task.SchedModeTimeInterval, nil
+	case schedModeFixedDelay:
+		return task.SchedModeFixedDelay, nil
 	}
 	return task.SchedModeNil, errors.New("invalid schedule mode")
 }
 
+func toTaskModelMisfirePolicy(entityMisfirePolicy task.MisfirePolicy) (int, error) {
+	switch entityMisfirePolicy {
+	case task.MisfirePolicyFireOnce:
+		return misfirePolicyFireOnce, nil
+	case task.MisfirePolicyFireAll:
+		return misfirePolicyFireAll, nil
+	case task.MisfirePolicySkip:
+		return misfirePolicySkip, nil
+	case task.MisfirePolicySkipIfExpired:
+		return misfirePolicySkipIfExpired, nil
+	}
+	return misfirePolicyFireOnce, errors.New("invalid misfire policy")
+}
+
+func toTaskEntityMisfirePolicy(misfirePolicy int) (task.MisfirePolicy, error) {
+	switch misfirePolicy {
+	case misfirePolicyFireOnce:
+		return task.MisfirePolicyFireOnce, nil
+	case misfirePolicyFireAll:
+		return task.MisfirePolicyFireAll, nil
+	case misfirePolicySkip:
+		return task.MisfirePolicySkip, nil
+	case misfirePolicySkipIfExpired:
+		return task.MisfirePolicySkipIfExpired, nil
+	}
+	return task.MisfirePolicyFireOnce, errors.New("invalid misfire policy")
+}
+
+func toTaskModelConcurrencyPolicy(entityConcurrencyPolicy task.ConcurrencyPolicy) (int, error) {
+	switch entityConcurrencyPolicy {
+	case task.ConcurrencyPolicyAllow:
+		return concurrencyPolicyAllow, nil
+	case task.ConcurrencyPolicyForbid:
+		return concurrencyPolicyForbid, nil
+	case task.ConcurrencyPolicyQueue:
+		return concurrencyPolicyQueue, nil
+	}
+	return concurrencyPolicyAllow, errors.New("invalid concurrency policy")
+}
+
+func toTaskEntityConcurrencyPolicy(concurrencyPolicy int) (task.ConcurrencyPolicy, error) {
+	switch concurrencyPolicy {
+	case concurrencyPolicyAllow:
+		return task.ConcurrencyPolicyAllow, nil
+	case concurrencyPolicyForbid:
+		return task.ConcurrencyPolicyForbid, nil
+	case concurrencyPolicyQueue:
+		return task.ConcurrencyPolicyQueue, nil
+	}
+	return task.ConcurrencyPolicyAllow, errors.New("invalid concurrency policy")
+}
+
+func toTaskModelDispatchMode(entityDispatchMode task.DispatchMode) (int, error) {
+	switch entityDispatchMode {
+	case task.DispatchModeSingle:
+		return dispatchModeSingle, nil
+	case task.DispatchModeBroadcast:
+		return dispatchModeBroadcast, nil
+	}
+	return dispatchModeSingle, errors.New("invalid dispatch mode")
+}
+
+func toTaskEntityDispatchMode(dispatchMode int) (task.DispatchMode, error) {
+	switch dispatchMode {
+	case dispatchModeSingle:
+		return task.DispatchModeSingle, nil
+	case dispatchModeBroadcast:
+		return task.DispatchModeBroadcast, nil
+	}
+	return task.DispatchModeSingle, errors.New("invalid dispatch mode")
+}
+
+func toTaskModelBroadcastSuccessRule(entityRule task.BroadcastSuccessRule) (int, error) {
+	switch entityRule {
+	case task.BroadcastSuccessRuleAll:
+		return broadcastSuccessRuleAll, nil
+	case task.BroadcastSuccessRuleAny:
+		return broadcastSuccessRuleAny, nil
+	}
+	return broadcastSuccessRuleAll, errors.New("invalid broadcast success rule")
+}
+
+func toTaskEntityBroadcastSuccessRule(rule int) (task.BroadcastSuccessRule, error) {
+	switch rule {
+	case broadcastSuccessRuleAll:
+		return task.BroadcastSuccessRuleAll, nil
+	case broadcastSuccessRuleAny:
+		return task.BroadcastSuccessRuleAny, nil
+	}
+	return task.BroadcastSuccessRuleAll, errors.New("invalid broadcast success rule")
+}
+
+func toTaskModelRouteStrategy(entityStrategy task.RouteStrategy) (int, error) {
+	switch entityStrategy {
+	case task.RouteStrategyNil:
+		return routeStrategyNil, nil
+	case task.RouteStrategyRandom:
+		return routeStrategyRandom, nil
+	case task.RouteStrategyRoundRobin:
+		return routeStrategyRoundRobin, nil
+	case task.RouteStrategyWeightedRandom:
+		return routeStrategyWeightedRandom, nil
+	case task.RouteStrategyConsistentHash:
+		return routeStrategyConsistentHash, nil
+	case task.RouteStrategyLeastRecentlyUsed:
+		return routeStrategyLeastRecentlyUsed, nil
+	case task.RouteStrategyLeastInFlight:
+		return routeStrategyLeastInFlight, nil
+	}
+	return routeStrategyNil, errors.New("invalid route strategy")
+}
+
+func toTaskEntityRouteStrategy(strategy int) (task.RouteStrategy, error) {
+	switch strategy {
+	case routeStrategyNil:
+		return task.RouteStrategyNil, nil
+	case routeStrategyRandom:
+		return task.RouteStrategyRandom, nil
+	case routeStrategyRoundRobin:
+		return task.RouteStrategyRoundRobin, nil
+	case routeStrategyWeightedRandom:
+		return task.RouteStrategyWeightedRandom, nil
+	case routeStrategyConsistentHash:
+		return task.RouteStrategyConsistentHash, nil
+	case routeStrategyLeastRecentlyUsed:
+		return task.RouteStrategyLeastRecentlyUsed, nil
+	case routeStrategyLeastInFlight:
+		return task.RouteStrategyLeastInFlight, nil
+	}
+	return task.RouteStrategyNil, errors.New("invalid route strategy")
+}
+
+func toTaskLogModelSkipReason(entitySkipReason task.SkipReason) int {
+	switch entitySkipReason {
+	case task.SkipReasonMisfire:
+		return skipReasonMisfire
+	case task.SkipReasonConcurrencyForbidden:
+		return skipReasonConcurrencyForbidden
+	}
+	return skipReasonNil
+}
+
+func toTaskLogModelMisfireDecision(entityMisfireDecision task.MisfireDecision) int {
+	switch entityMisfireDecision {
+	case task.MisfireDecisionFireOnce:
+		return misfireDecisionFireOnce
+	case task.MisfireDecisionCatchUp:
+		return misfireDecisionCatchUp
+	case task.MisfireDecisionSkip:
+		return misfireDecisionSkip
+	}
+	return misfireDecisionNil
+}
+
 func toTaskEntityId(modelId uint64) string {
 	return fmt.Sprintf("%d", modelId)
 }
@@ -120,14 +449,93 @@ type TaskCallbackSrvModel struct {
 	Name string `gorm:"index:server_name,unique;comment:'服务名称'"`
 	CheckedHealthAt int64 `gorm:"comment:'上次健康检查时间'"`
 	HasEnableHealthCheck bool `gorm:"comment:'是否有开启健康检查的路由'"`
+	MaxInFlight int `gorm:"comment:'同时进行中的回调数上限,0不限制'"`
+	MaxPerSec int `gorm:"comment:'每秒回调数上限,0不限制'"`
+	StreamKey string `gorm:"comment:'投递任务的redis stream key'"`
+	StreamMaxLen int64 `gorm:"comment:'redis stream的大致长度上限'"`
+	Secret string `gorm:"comment:'加密后的回调请求签名密钥'"`
+	Headers Headers `gorm:"comment:'回调http节点时带上的header'"`
+	SecretHeaders string `gorm:"comment:'加密后的密钥header'"`
+	RouteStrategy int `gorm:"comment:'任务没有指定时选择回调节点的策略:0.没有指定,1.随机,2.轮询,3.加权随机,4.按biz_id一致性哈希,5.最近最少使用,6.最少执行中'"`
 }
 
 func (*TaskCallbackSrvModel) TableName() string {
 	return "task_callback_srv"
 }
 
-func (m *TaskCallbackSrvModel) toEntity(routes []*task.TaskCallbackSrvRoute) *task.TaskCallbackSrv {
-	return task.NewTaskCallbackSrv(m.toEntityId(), m.Name, routes, m.HasEnableHealthCheck)
+func (m *TaskCallbackSrvModel) toEntity(routes []*task.TaskCallbackSrvRoute, secretCipher *crypt.Cipher) (*task.TaskCallbackSrv, error) {
+	secretHeaders, err := decryptSecretHeaders(m.SecretHeaders, secretCipher)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := decryptSecret(m.Secret, secretCipher)
+	if err != nil {
+		return nil, err
+	}
+	routeStrategy, err := toTaskEntityRouteStrategy(m.RouteStrategy)
+	if err != nil {
+		return nil, err
+	}
+	headers := task.NewCallbackSrvHeaders(m.Headers, secretHeaders)
+	return task.NewTaskCallbackSrv(m.toEntityId(), m.Name, routes, m.HasEnableHealthCheck, task.NewCallbackSrvLimit(m.MaxInFlight, m.MaxPerSec), m.StreamKey, secret, headers, routeStrategy, m.StreamMaxLen), nil
+}
+
+// 签名密钥和密钥header一样加密存储,没有配置secret_key时不能保存和读取
+func encryptSecret(secret string, secretCipher *crypt.Cipher) (string, error) {
+	if secret == "" {
+		return "", nil
+	}
+	if secretCipher == nil {
+		return "", errors.New("secret_key is not configured, can not store secret")
+	}
+	return secretCipher.Encrypt([]byte(secret))
+}
+
+func decryptSecret(ciphertext string, secretCipher *crypt.Cipher) (string, error) {
+	if ciphertext == "" {
+		return "", nil
+	}
+	if secretCipher == nil {
+		return "", errors.New("secret_key is not configured, can not read secret")
+	}
+	plaintext, err := secretCipher.Decrypt(ciphertext)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// 密钥header以json格式加密后存储,没有配置secret_key时不能保存和读取
+func encryptSecretHeaders(secretHeaders map[string]string, secretCipher *crypt.Cipher) (string, error) {
+	if len(secretHeaders) == 0 {
+		return "", nil
+	}
+	if secretCipher == nil {
+		return "", errors.New("secret_key is not configured, can not store secret headers")
+	}
+	plaintext, err := json.Marshal(secretHeaders)
+	if err != nil {
+		return "", err
+	}
+	return secretCipher.Encrypt(plaintext)
+}
+
+func decryptSecretHeaders(ciphertext string, secretCipher *crypt.Cipher) (map[string]string, error) {
+	if ciphertext == "" {
+		return nil, nil
+	}
+	if secretCipher == nil {
+		return nil, errors.New("secret_key is not configured, can not read secret headers")
+	}
+	plaintext, err := secretCipher.Decrypt(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	var secretHeaders map[string]string
+	if err = json.Unmarshal(plaintext, &secretHeaders); err != nil {
+		return nil, err
+	}
+	return secretHeaders, nil
 }
 
 func (m *TaskCallbackSrvModel) toEntityId() string {
@@ -151,6 +559,8 @@ type TaskCallbackSrvRouteModel struct {
 	CallbackTimeoutSec int `gorm:"comment:'回调超时时间'"`
 	CheckedHealthAt int64 `gorm:"comment:'上次健康检查时间'"`
 	EnableHealthCheck bool `gorm:"comment:'是否开启健康检查'"`
+	Weight int `gorm:"comment:'权重'"`
+	Labels Labels `gorm:"comment:'标签'"`
 }
 
 func (*TaskCallbackSrvRouteModel) TableName() string {
@@ -182,9 +592,39 @@ func (m *TaskCallbackSrvRouteModel) toEntity() *task.TaskCallbackSrvRoute {
 		m.Port,
 		m.CallbackTimeoutSec,
 		m.EnableHealthCheck,
+		m.Weight,
+		m.Labels,
 		)
 }
 
+type Labels map[string]string
+
+func (l Labels) Value() (driver.Value, error) {
+	j, err := json.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+	return string(j), nil
+}
+
+func (l *Labels) Scan(src interface{}) error {
+	return scanJsonColumn(src, l)
+}
+
+type Headers map[string]string
+
+func (h Headers) Value() (driver.Value, error) {
+	j, err := json.Marshal(h)
+	if err != nil {
+		return nil, err
+	}
+	return string(j), nil
+}
+
+func (h *Headers) Scan(src interface{}) error {
+	return scanJsonColumn(src, h)
+}
+
 type TaskLogCallbackReqSnapshot struct {
 	SrvSchema string `gorm:"index:route_addr,unique" json:"srv_schema"`
 	Host string `gorm:"index:route_addr,unique" json:"host"`
@@ -192,6 +632,10 @@ type TaskLogCallbackReqSnapshot struct {
 	TimeoutSec int `json:"timeout_sec"`
 	CallbackAt int64 `json:"callback_at"`
 	CallbackPath string `json:"callback_path"`
+	// 以下只在回调http节点时记录,密钥header的值为******
+	CallbackMethod string `json:"callback_method,omitempty"`
+	CallbackContentType string `json:"callback_content_type,omitempty"`
+	CallbackHeaders map[string]string `json:"callback_headers,omitempty"`
 }
 
 func (s TaskLogCallbackReqSnapshot) Value() (driver.Value, error) {
@@ -230,21 +674,404 @@ func (s *TaskLogCallbackRespSnapshot) Scan(src interface{}) error {
 	return nil
 }
 
+// 传输层失败换节点重试时每次回调尝试的节点和错误
+type TaskLogCallbackAttemptSnapshot struct {
+	RouteId string `json:"route_id"`
+	SrvSchema string `json:"srv_schema"`
+	Host string `json:"host"`
+	Port int `json:"port"`
+	CallbackAt int64 `json:"callback_at"`
+	Err string `json:"err"`
+}
+
+type TaskLogCallbackAttemptsSnapshot []*TaskLogCallbackAttemptSnapshot
+
+func (s TaskLogCallbackAttemptsSnapshot) Value() (driver.Value, error) {
+	j, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(j), nil
+}
+
+func (s *TaskLogCallbackAttemptsSnapshot) Scan(src interface{}) error {
+	return scanJsonColumn(src, s)
+}
+
+func toTaskLogCallbackAttemptsSnapshot(attempts []*task.TaskCallbackAttempt) TaskLogCallbackAttemptsSnapshot {
+	var snapshot TaskLogCallbackAttemptsSnapshot
+	for _, attempt := range attempts {
+		attemptSnapshot := &TaskLogCallbackAttemptSnapshot{
+			RouteId: attempt.GetRoute().GetId(),
+			SrvSchema: attempt.GetRoute().GetSchema(),
+			Host: attempt.GetRoute().GetHost(),
+			Port: attempt.GetRoute().GetPort(),
+			CallbackAt: attempt.GetCallbackAt(),
+		}
+		if attempt.GetErr() != nil {
+			attemptSnapshot.Err = attempt.GetErr().Error()
+		}
+		snapshot = append(snapshot, attemptSnapshot)
+	}
+	return snapshot
+}
+
 type TaskLogModel struct {
 	BaseModel
 	TaskId uint64 `json:"task_id" gorm:"index:task_run_times,unique;comment:'任务id'"`
 	StartedAt int64 `json:"started_at" gorm:"comment:'任务开始时间'"`
 	EndedAt int64 `json:"ended_at" gorm:"comment:'任务结束时间'"`
-	TaskStatus int `json:"task_status" gorm:"comment:'任务状态:2.进行中,3.成功,4.失败'"`
+	TaskStatus int `json:"task_status" gorm:"index:task_deadline,priority:1;index:task_heartbeat,priority:1;comment:'任务状态:1.进行中,2.成功,3.失败,4.跳过'"`
 	IsRunInAsync bool `json:"is_run_in_async" gorm:"comment:'是否异步模式'"`
 	RespExtra string `json:"resp_extra" gorm:"comment:'响应额外信息'"`
 	RunTimes int `json:"try_times" gorm:"index:task_run_times,unique;comment:'任务是第几次执行'"`
 	SrvId uint64 `json:"srv_id" gorm:"comment:'回调服务id'"`
 	ReqSnapshot *TaskLogCallbackReqSnapshot `json:"req_snapshot" gorm:"comment:'请求快照'"`
 	RespSnapshot *TaskLogCallbackRespSnapshot `json:"resp_snapshot" gorm:"comment:'响应快照'"`
+	CallbackAttempts TaskLogCallbackAttemptsSnapshot `json:"callback_attempts" gorm:"comment:'回调尝试记录'"`
 	CallbackErr string `gorm:"comment:'回调错误'"`
+	Attempt int `json:"attempt" gorm:"comment:'第几次重试,0代表正常调度'"`
+	MisfiredTimes int `json:"misfired_times" gorm:"comment:'调度时已错过的触发次数'"`
+	MisfireDecision int `json:"misfire_decision" gorm:"comment:'错过触发的处理:0.未错过,1.补触发一次,2.逐个补触发,3.跳过'"`
+	SkipReason int `json:"skip_reason" gorm:"comment:'跳过原因:0.未跳过,1.错过触发,2.上次执行未结束'"`
+	TriggerType int `json:"trigger_type" gorm:"comment:'触发方式:0.调度计划,1.工作流,2.手动'"`
+	WorkflowRunId uint64 `json:"workflow_run_id" gorm:"index;comment:'工作流运行id'"`
+	WorkflowNode string `json:"workflow_node" gorm:"comment:'工作流节点名称'"`
+	DeadlineAt int64 `json:"deadline_at" gorm:"index:task_deadline,priority:2;comment:'执行期限,超过后仍在执行中则判定为超时失败,0代表不限制'"`
+	IsTimeout bool `json:"is_timeout" gorm:"comment:'是否因超过执行期限被判定失败'"`
+	IsCancelled bool `json:"is_cancelled" gorm:"comment:'是否被手动取消'"`
+	Progress int `json:"progress" gorm:"comment:'最近上报的执行进度,0-100'"`
+	ProgressMsg string `json:"progress_msg" gorm:"comment:'最近上报的进度说明'"`
+	HeartbeatAt int64 `json:"heartbeat_at" gorm:"index:task_heartbeat,priority:2;comment:'最近一次上报进度的时间,0代表没有上报过'"`
 }
 
 func (*TaskLogModel) TableName() string {
 	return "task_log"
+}
+
+// 广播执行中每个节点的执行或者分片执行中每个分片的执行记录
+type TaskSubRunLogModel struct {
+	BaseModel
+	TaskId uint64 `json:"task_id" gorm:"index:task_sub_run,unique;comment:'任务id'"`
+	RunTimes int `json:"run_times" gorm:"index:task_sub_run,unique;comment:'任务是第几次执行'"`
+	SubRunIdx int `json:"sub_run_idx" gorm:"index:task_sub_run,unique;comment:'第几个子执行,从1开始'"`
+	RouteId uint64 `json:"route_id" gorm:"comment:'回调节点id'"`
+	StartedAt int64 `json:"started_at" gorm:"comment:'开始时间'"`
+	EndedAt int64 `json:"ended_at" gorm:"comment:'结束时间'"`
+	TaskStatus int `json:"task_status" gorm:"comment:'状态:1.进行中,2.成功,3.失败'"`
+	IsRunInAsync bool `json:"is_run_in_async" gorm:"comment:'是否异步模式'"`
+	RespExtra string `json:"resp_extra" gorm:"comment:'响应额外信息'"`
+	ReqSnapshot *TaskLogCallbackReqSnapshot `json:"req_snapshot" gorm:"comment:'请求快照'"`
+	RespSnapshot *TaskLogCallbackRespSnapshot `json:"resp_snapshot" gorm:"comment:'响应快照'"`
+	CallbackAttempts TaskLogCallbackAttemptsSnapshot `json:"callback_attempts" gorm:"comment:'回调尝试记录'"`
+	CallbackErr string `gorm:"comment:'回调错误'"`
+}
+
+func (*TaskSubRunLogModel) TableName() string {
+	return "task_sub_run_log"
+}
+
+type TaskTriggerModel struct {
+	BaseModel
+	TaskId uint64 `gorm:"index:trigger_task_run_times;comment:'任务id'"`
+	PlanAt int64 `gorm:"index:trigger_plan,priority:2;comment:'计划触发时间'"`
+	ConsumedAt int64 `gorm:"index:trigger_plan,priority:1;comment:'被调度的时间,0代表未调度'"`
+	RunTimes int `gorm:"index:trigger_task_run_times;comment:'调度后对应任务的第几次执行'"`
+	TriggerType int `gorm:"comment:'触发方式:0.调度计划触发的执行的重试,1.工作流,2.手动'"`
+	Attempt int `gorm:"comment:'第几次重试,0代表首次触发'"`
+	WorkflowRunId uint64 `gorm:"comment:'工作流运行id'"`
+	WorkflowNode string `gorm:"comment:'工作流节点名称'"`
+	Arg string `gorm:"comment:'覆盖任务参数的一次性参数'"`
+	HasArg bool `gorm:"comment:'是否覆盖任务参数'"`
+}
+
+func (*TaskTriggerModel) TableName() string {
+	return "task_trigger"
+}
+
+func (m *TaskTriggerModel) toEntity() (*task.TaskTrigger, error) {
+	triggerType, err := toTaskEntityTriggerType(m.TriggerType)
+	if err != nil {
+		return nil, err
+	}
+	req := &task.NewTaskTriggerReq{
+		Id: fmt.Sprintf("%d", m.Id),
+		TriggerType: triggerType,
+		PlanAt: m.PlanAt,
+		Attempt: m.Attempt,
+		WorkflowNode: m.WorkflowNode,
+		Arg: m.Arg,
+		HasArg: m.HasArg,
+	}
+	if m.WorkflowRunId > 0 {
+		req.WorkflowRunId = toWorkflowRunEntityId(m.WorkflowRunId)
+	}
+	return task.NewTaskTrigger(req), nil
+}
+
+func toTaskEntityTriggerType(triggerType int) (task.TriggerType, error) {
+	switch triggerType {
+	case triggerTypeSched:
+		return task.TriggerTypeSched, nil
+	case triggerTypeWorkflow:
+		return task.TriggerTypeWorkflow, nil
+	case triggerTypeManual:
+		return task.TriggerTypeManual, nil
+	}
+	return task.TriggerTypeSched, errors.New("invalid trigger type")
+}
+
+func toTaskModelTriggerType(entityTriggerType task.TriggerType) (int, error) {
+	switch entityTriggerType {
+	case task.TriggerTypeSched:
+		return triggerTypeSched, nil
+	case task.TriggerTypeWorkflow:
+		return triggerTypeWorkflow, nil
+	case task.TriggerTypeManual:
+		return triggerTypeManual, nil
+	}
+	return triggerTypeSched, errors.New("invalid trigger type")
+}
+
+func toModelStatus(entityStatus task.Status) int {
+	switch entityStatus {
+	case task.StatusRunning:
+		return statusRunning
+	case task.StatusSuccess:
+		return statusSuccess
+	case task.StatusFailed:
+		return statusFailed
+	case task.StatusSkipped:
+		return statusSkipped
+	}
+	return statusNil
+}
+
+func toEntityStatus(status int) task.Status {
+	switch status {
+	case statusRunning:
+		return task.StatusRunning
+	case statusSuccess:
+		return task.StatusSuccess
+	case statusFailed:
+		return task.StatusFailed
+	case statusSkipped:
+		return task.StatusSkipped
+	}
+	return task.StatusNil
+}
+
+type WorkflowNodeSnapshot struct {
+	Name string `json:"name"`
+	TaskId uint64 `json:"task_id"`
+}
+
+type WorkflowEdgeSnapshot struct {
+	From string `json:"from"`
+	To string `json:"to"`
+	Condition int `json:"condition"`
+}
+
+type WorkflowSnapshot struct {
+	Nodes []*WorkflowNodeSnapshot `json:"nodes"`
+	Edges []*WorkflowEdgeSnapshot `json:"edges"`
+}
+
+func (s WorkflowSnapshot) Value() (driver.Value, error) {
+	j, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(j), nil
+}
+
+func (s *WorkflowSnapshot) Scan(src interface{}) error {
+	return scanJsonColumn(src, s)
+}
+
+func (s *WorkflowSnapshot) toEntity(workflowId uint64, name string) (*task.Workflow, error) {
+	var (
+		nodes []*task.WorkflowNode
+		edges []*task.WorkflowEdge
+	)
+	for _, node := range s.Nodes {
+		nodes = append(nodes, task.NewWorkflowNode(node.Name, toTaskEntityId(node.TaskId)))
+	}
+	for _, edge := range s.Edges {
+		condition, err := toEntityWorkflowEdgeCondition(edge.Condition)
+		if err != nil {
+			return nil, err
+		}
+		edges = append(edges, task.NewWorkflowEdge(edge.From, edge.To, condition))
+	}
+	return task.NewWorkflow(&task.NewWorkflowReq{
+		Id: toWorkflowEntityId(workflowId),
+		Name: name,
+		Nodes: nodes,
+		Edges: edges,
+	})
+}
+
+func toWorkflowSnapshot(workflow *task.Workflow) (*WorkflowSnapshot, error) {
+	snapshot := &WorkflowSnapshot{}
+	for _, node := range workflow.GetNodes() {
+		taskModelId, err := toTaskModelId(node.GetTaskId())
+		if err != nil {
+			return nil, err
+		}
+		snapshot.Nodes = append(snapshot.Nodes, &WorkflowNodeSnapshot{
+			Name: node.GetName(),
+			TaskId: taskModelId,
+		})
+	}
+	for _, edge := range workflow.GetEdges() {
+		condition, err := toModelWorkflowEdgeCondition(edge.GetCondition())
+		if err != nil {
+			return nil, err
+		}
+		snapshot.Edges = append(snapshot.Edges, &WorkflowEdgeSnapshot{
+			From: edge.GetFrom(),
+			To: edge.GetTo(),
+			Condition: condition,
+		})
+	}
+	return snapshot, nil
+}
+
+func toModelWorkflowEdgeCondition(entityCondition task.WorkflowEdgeCondition) (int, error) {
+	switch entityCondition {
+	case task.WorkflowEdgeConditionOnSuccess:
+		return workflowEdgeConditionOnSuccess, nil
+	case task.WorkflowEdgeConditionOnFailure:
+		return workflowEdgeConditionOnFailure, nil
+	case task.WorkflowEdgeConditionAlways:
+		return workflowEdgeConditionAlways, nil
+	}
+	return workflowEdgeConditionNil, errors.New("invalid workflow edge condition")
+}
+
+func toEntityWorkflowEdgeCondition(condition int) (task.WorkflowEdgeCondition, error) {
+	switch condition {
+	case workflowEdgeConditionOnSuccess:
+		return task.WorkflowEdgeConditionOnSuccess, nil
+	case workflowEdgeConditionOnFailure:
+		return task.WorkflowEdgeConditionOnFailure, nil
+	case workflowEdgeConditionAlways:
+		return task.WorkflowEdgeConditionAlways, nil
+	}
+	return task.WorkflowEdgeConditionNil, errors.New("invalid workflow edge condition")
+}
+
+type WorkflowModel struct {
+	BaseModel
+	Name string `gorm:"index:workflow_name,unique;comment:'工作流名称'"`
+	Definition *WorkflowSnapshot `gorm:"comment:'工作流定义'"`
+}
+
+func (*WorkflowModel) TableName() string {
+	return "workflow"
+}
+
+func (m *WorkflowModel) toEntity() (*task.Workflow, error) {
+	return m.Definition.toEntity(m.Id, m.Name)
+}
+
+func toWorkflowEntityId(modelId uint64) string {
+	return fmt.Sprintf("%d", modelId)
+}
+
+func toWorkflowModelId(entityId string) (uint64, error) {
+	return strconv.ParseUint(entityId, 10, 64)
+}
+
+type WorkflowNodeRunSnapshot struct {
+	Status int `json:"status"`
+	TaskRunTimes int `json:"task_run_times"`
+}
+
+// 节点名称 => 节点运行状态
+type WorkflowNodeRunsSnapshot map[string]*WorkflowNodeRunSnapshot
+
+func (s WorkflowNodeRunsSnapshot) Value() (driver.Value, error) {
+	j, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(j), nil
+}
+
+func (s *WorkflowNodeRunsSnapshot) Scan(src interface{}) error {
+	return scanJsonColumn(src, s)
+}
+
+func (s WorkflowNodeRunsSnapshot) toEntityStatuses() map[string]task.Status {
+	statuses := make(map[string]task.Status, len(s))
+	for name, nodeRun := range s {
+		statuses[name] = toEntityStatus(nodeRun.Status)
+	}
+	return statuses
+}
+
+type WorkflowRunModel struct {
+	BaseModel
+	WorkflowId uint64 `gorm:"index;comment:'工作流id'"`
+	WorkflowName string `gorm:"comment:'工作流名称'"`
+	Definition *WorkflowSnapshot `gorm:"comment:'启动时的工作流定义快照'"`
+	Status int `gorm:"comment:'状态:1.进行中,2.成功,3.失败'"`
+	NodeRuns WorkflowNodeRunsSnapshot `gorm:"comment:'各节点运行状态'"`
+	StartedAt int64 `gorm:"comment:'开始时间'"`
+	EndedAt int64 `gorm:"comment:'结束时间'"`
+}
+
+func (*WorkflowRunModel) TableName() string {
+	return "workflow_run"
+}
+
+func (m *WorkflowRunModel) toEntity() (*task.WorkflowRun, error) {
+	workflow, err := m.Definition.toEntity(m.WorkflowId, m.WorkflowName)
+	if err != nil {
+		return nil, err
+	}
+
+	var nodeRuns []*task.WorkflowNodeRun
+	for _, node := range workflow.GetNodes() {
+		var (
+			status = task.StatusNil
+			taskRunTimes int
+		)
+		if nodeRun, ok := m.NodeRuns[node.GetName()]; ok {
+			status = toEntityStatus(nodeRun.Status)
+			taskRunTimes = nodeRun.TaskRunTimes
+		}
+		nodeRuns = append(nodeRuns, task.NewWorkflowNodeRun(node.GetName(), node.GetTaskId(), status, taskRunTimes))
+	}
+
+	return task.NewWorkflowRun(&task.NewWorkflowRunReq{
+		Id: toWorkflowRunEntityId(m.Id),
+		Workflow: workflow,
+		Status: toEntityStatus(m.Status),
+		NodeRuns: nodeRuns,
+		StartedAt: m.StartedAt,
+		EndedAt: m.EndedAt,
+	})
+}
+
+func toWorkflowRunEntityId(modelId uint64) string {
+	return fmt.Sprintf("%d", modelId)
+}
+
+func toWorkflowRunModelId(entityId string) (uint64, error) {
+	return strconv.ParseUint(entityId, 10, 64)
+}
+
+func scanJsonColumn(src interface{}, dest interface{}) error {
+	switch raw := src.(type) {
+	case []byte:
+		return json.Unmarshal(raw, dest)
+	case string:
+		return json.Unmarshal([]byte(raw), dest)
+	case nil:
+		return nil
+	}
+	return fmt.Errorf("unsupported json column type %T", src)
 }
\ No newline at end of file
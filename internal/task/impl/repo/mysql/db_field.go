@@ -24,8 +24,41 @@ const (
 	DbFieldLastSuccessAt = "last_success_at"
 	DbFieldAllowMaxRunTimes = "allow_max_run_times"
 	DbFieldMaxRunTimeSec = "max_run_time_sec"
+	DbFieldIsMaxRunTimeSecSet = "is_max_run_time_sec_set"
 	DbFieldCallbackPath = "callback_path"
 	DbFieldBizId = "biz_id"
+	DbFieldRetryMaxAttempts = "retry_max_attempts"
+	DbFieldRetryInitialDelaySec = "retry_initial_delay_sec"
+	DbFieldRetryMultiplier = "retry_multiplier"
+	DbFieldRetryMaxDelaySec = "retry_max_delay_sec"
+	DbFieldRetryJitter = "retry_jitter"
+	DbFieldTimeZone = "time_zone"
+	DbFieldMisfirePolicy = "misfire_policy"
+	DbFieldMisfireMaxCatchUpTimes = "misfire_max_catch_up_times"
+	DbFieldMisfireThresholdSec = "misfire_threshold_sec"
+	DbFieldConcurrencyPolicy = "concurrency_policy"
+	DbFieldIsPaused = "is_paused"
+	DbFieldHasArg = "has_arg"
+	DbFieldDispatchMode = "dispatch_mode"
+	DbFieldBroadcastSuccessRule = "broadcast_success_rule"
+	DbFieldSubRunIdx = "sub_run_idx"
+	DbFieldRouteId = "route_id"
+	DbFieldShardTotal = "shard_total"
+	DbFieldRouteStrategy = "route_strategy"
+	DbFieldWeight = "weight"
+	DbFieldLabels = "labels"
+	DbFieldLabelSelector = "label_selector"
+	DbFieldCallbackAttempts = "callback_attempts"
+	DbFieldFailoverMaxAttempts = "failover_max_attempts"
+	DbFieldMaxInFlight = "max_in_flight"
+	DbFieldMaxPerSec = "max_per_sec"
+	DbFieldStreamKey = "stream_key"
+	DbFieldStreamMaxLen = "stream_max_len"
+	DbFieldSecret = "secret"
+	DbFieldHeaders = "headers"
+	DbFieldSecretHeaders = "secret_headers"
+	DbFieldCallbackMethod = "callback_method"
+	DbFieldCallbackContentType = "callback_content_type"
 	DbFieldCheckedHealthAt = "checked_health_at"
 	DbFieldHasEnableHealthCheck = "has_enable_health_check"
 	DbFieldSrvSchema = "srv_schema"
@@ -45,6 +78,26 @@ const (
 	DbFieldReqSnapshot = "req_snapshot"
 	DbFieldRespSnapshot = "resp_snapshot"
 	DbFieldCallbackErr = "callback_err"
+	DbFieldAttempt = "attempt"
+	DbFieldMisfiredTimes = "misfired_times"
+	DbFieldMisfireDecision = "misfire_decision"
+	DbFieldSkipReason = "skip_reason"
+	DbFieldDeadlineAt = "deadline_at"
+	DbFieldIsTimeout = "is_timeout"
+	DbFieldIsCancelled = "is_cancelled"
+	DbFieldProgress = "progress"
+	DbFieldProgressMsg = "progress_msg"
+	DbFieldHeartbeatAt = "heartbeat_at"
+	DbFieldTriggerType = "trigger_type"
+	DbFieldWorkflowRunId = "workflow_run_id"
+	DbFieldWorkflowNode = "workflow_node"
+	DbFieldPlanAt = "plan_at"
+	DbFieldConsumedAt = "consumed_at"
+	DbFieldDefinition = "definition"
+	DbFieldWorkflowId = "workflow_id"
+	DbFieldWorkflowName = "workflow_name"
+	DbFieldStatus = "status"
+	DbFieldNodeRuns = "node_runs"
 	DbFieldbaseLogger = "base_logger"
 	DbFieldslowThreshold = "slow_threshold"
 	DbFielddb = "db"
@@ -20,6 +20,7 @@ const (
 	FieldTimeCron = "TimeCron"
 	FieldExecMode = "ExecMode"
 	FieldCallbackSrvId = "CallbackSrvId"
+	FieldTaskId = "TaskId"
 	FieldTriedCnt = "TriedCnt"
 	FieldIsRunInAsync = "IsRunInAsync"
 	FieldLastFailedAt = "LastFailedAt"
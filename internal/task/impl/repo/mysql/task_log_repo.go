@@ -4,7 +4,9 @@ import (
 	"context"
 	"github.com/995933447/easytask/internal/task"
 	"github.com/995933447/easytask/internal/util/logger"
+	"github.com/995933447/easytask/pkg/errs"
 	"github.com/995933447/optionstream"
+	"gorm.io/gorm"
 	"sync/atomic"
 	"time"
 )
@@ -16,31 +18,100 @@ type TaskLogRepo struct {
 }
 
 func (r *TaskLogRepo) SaveTaskStartedLog(ctx context.Context, detail *task.TaskStartedLogDetail) error {
+	if err := createTaskStartedLog(r.mustGetConn(ctx), detail); err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return err
+	}
+	return nil
+}
+
+// 触发队列中的任务需要和锁定任务在同一个事务中写日志,所以单独抽出来
+func createTaskStartedLog(conn *gorm.DB, detail *task.TaskStartedLogDetail) error {
 	taskModelId, err := toTaskModelId(detail.GetTask().GetId())
 	if err != nil {
-		logger.MustGetRepoLogger().Error(ctx, err)
 		return err
 	}
 	srvModelId, err := toCallbackSrvRouteModelId(detail.GetTask().GetCallbackSrv().GetId())
 	if err != nil {
-		logger.MustGetRepoLogger().Error(ctx, err)
 		return err
 	}
-	err = r.mustGetConn(ctx).Create(&TaskLogModel{
+	now := time.Now().Unix()
+	taskLogModel := &TaskLogModel{
 		TaskId: taskModelId,
-		StartedAt: time.Now().Unix(),
+		StartedAt: now,
 		TaskStatus: statusRunning,
 		RunTimes: detail.GetTask().GetRunTimes(),
 		SrvId: srvModelId,
-	}).Error
+		Attempt: detail.GetTask().GetRetryAttempt(),
+	}
+	if decision := detail.GetTask().GetSchedDecision(); decision != nil {
+		taskLogModel.MisfiredTimes = decision.GetMisfiredTimes()
+		taskLogModel.MisfireDecision = toTaskLogModelMisfireDecision(decision.GetMisfireDecision())
+		if decision.IsSkipped() {
+			taskLogModel.SkipReason = toTaskLogModelSkipReason(decision.GetSkipReason())
+			taskLogModel.TaskStatus = statusSkipped
+			taskLogModel.EndedAt = now
+		}
+	}
+	if maxRunTimeSec := detail.GetTask().GetMaxRunTimeSec(); maxRunTimeSec > 0 && taskLogModel.TaskStatus == statusRunning {
+		taskLogModel.DeadlineAt = now + int64(maxRunTimeSec)
+	}
+	if trigger := detail.GetTask().GetTrigger(); trigger != nil {
+		if taskLogModel.TriggerType, err = toTaskModelTriggerType(trigger.GetTriggerType()); err != nil {
+			return err
+		}
+		if trigger.GetWorkflowRunId() != "" {
+			if taskLogModel.WorkflowRunId, err = toWorkflowRunModelId(trigger.GetWorkflowRunId()); err != nil {
+				return err
+			}
+			taskLogModel.WorkflowNode = trigger.GetWorkflowNode()
+		}
+	}
+	return conn.Create(taskLogModel).Error
+}
+
+func (r *TaskLogRepo) SaveTaskSubRunsStartedLog(ctx context.Context, detail *task.TaskSubRunsStartedLogDetail) error {
+	taskModelId, err := toTaskModelId(detail.GetTaskId())
 	if err != nil {
 		logger.MustGetRepoLogger().Error(ctx, err)
 		return err
 	}
+
+	now := time.Now().Unix()
+	var subRunLogModels []*TaskSubRunLogModel
+	for i, route := range detail.GetRoutes() {
+		routeModelId, err := toCallbackSrvRouteModelId(route.GetId())
+		if err != nil {
+			logger.MustGetRepoLogger().Error(ctx, err)
+			return err
+		}
+		subRunLogModels = append(subRunLogModels, &TaskSubRunLogModel{
+			TaskId: taskModelId,
+			RunTimes: detail.GetRunTimes(),
+			SubRunIdx: i + 1,
+			RouteId: routeModelId,
+			StartedAt: now,
+			TaskStatus: statusRunning,
+		})
+	}
+
+	if len(subRunLogModels) == 0 {
+		return nil
+	}
+
+	if err = r.mustGetConn(ctx).Create(&subRunLogModels).Error; err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return err
+	}
+
 	return nil
 }
 
 func (r *TaskLogRepo) SaveTaskCallbackLog(ctx context.Context, detail *task.TaskCallbackLogDetail) error {
+	if detail.GetSubRunIdx() > 0 {
+		return r.saveTaskSubRunCallbackLog(ctx, detail)
+	}
+
 	updateMap := map[string]interface{}{
 		DbFieldIsRunInAsync: detail.IsRunInAsync(),
 		DbFieldReqSnapshot: &TaskLogCallbackReqSnapshot{
@@ -50,6 +121,9 @@ func (r *TaskLogRepo) SaveTaskCallbackLog(ctx context.Context, detail *task.Task
 			TimeoutSec: detail.GetRoute().GetCallbackTimeoutSec(),
 			CallbackAt: time.Now().Unix(),
 			CallbackPath: detail.GetCallbackPath(),
+			CallbackMethod: string(detail.GetCallbackMethod()),
+			CallbackContentType: string(detail.GetCallbackContentType()),
+			CallbackHeaders: detail.GetCallbackHeaders(),
 		},
 		DbFieldRespSnapshot: &TaskLogCallbackRespSnapshot{
 			RespRaw: detail.GetRespRaw(),
@@ -63,12 +137,18 @@ func (r *TaskLogRepo) SaveTaskCallbackLog(ctx context.Context, detail *task.Task
 		updateMap[DbFieldEndedAt] = time.Now().Unix()
 	}
 	if detail.GetErr() != nil {
-		updateMap[DbFieldCallbackErr] = detail.GetErr()
+		updateMap[DbFieldCallbackErr] = detail.GetErr().Error()
+	}
+	if len(detail.GetAttempts()) > 0 {
+		updateMap[DbFieldCallbackAttempts] = toTaskLogCallbackAttemptsSnapshot(detail.GetAttempts())
 	}
+	// 已经超时或取消的执行不再被回调结果覆盖
 	err := r.mustGetConn(ctx).
 		Model(&TaskLogModel{}).
 		Where(DbFieldTaskId, detail.GetTaskId()).
 		Where(DbFieldRunTimes, detail.GetRunTimes()).
+		Where(DbFieldIsTimeout + " = ?", false).
+		Where(DbFieldIsCancelled + " = ?", false).
 		Updates(updateMap).Error
 	if err != nil {
 		logger.MustGetRepoLogger().Error(ctx, err)
@@ -77,29 +157,145 @@ func (r *TaskLogRepo) SaveTaskCallbackLog(ctx context.Context, detail *task.Task
 	return nil
 }
 
+// 子执行的结果可能已经被异步确认,只在回调给出最终结果时更新状态
+func (r *TaskLogRepo) saveTaskSubRunCallbackLog(ctx context.Context, detail *task.TaskCallbackLogDetail) error {
+	now := time.Now().Unix()
+	updateMap := map[string]interface{}{
+		DbFieldIsRunInAsync: detail.IsRunInAsync(),
+		DbFieldReqSnapshot: &TaskLogCallbackReqSnapshot{
+			SrvSchema: detail.GetRoute().GetSchema(),
+			Host: detail.GetRoute().GetHost(),
+			Port: detail.GetRoute().GetPort(),
+			TimeoutSec: detail.GetRoute().GetCallbackTimeoutSec(),
+			CallbackAt: now,
+			CallbackPath: detail.GetCallbackPath(),
+			CallbackMethod: string(detail.GetCallbackMethod()),
+			CallbackContentType: string(detail.GetCallbackContentType()),
+			CallbackHeaders: detail.GetCallbackHeaders(),
+		},
+		DbFieldRespSnapshot: &TaskLogCallbackRespSnapshot{
+			RespRaw: detail.GetRespRaw(),
+		},
+	}
+	if detail.GetErr() != nil {
+		updateMap[DbFieldCallbackErr] = detail.GetErr().Error()
+	}
+	if len(detail.GetAttempts()) > 0 {
+		updateMap[DbFieldCallbackAttempts] = toTaskLogCallbackAttemptsSnapshot(detail.GetAttempts())
+	}
+
+	taskModelId, err := toTaskModelId(detail.GetTaskId())
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return err
+	}
+
+	conn := r.mustGetConn(ctx)
+	err = conn.Model(&TaskSubRunLogModel{}).
+		Where(DbFieldTaskId + " = ?", taskModelId).
+		Where(DbFieldRunTimes + " = ?", detail.GetRunTimes()).
+		Where(DbFieldSubRunIdx + " = ?", detail.GetSubRunIdx()).
+		Updates(updateMap).
+		Error
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return err
+	}
+
+	if !task.IsTaskSuccess(detail.GetTaskStatus()) && !task.IsTaskFailed(detail.GetTaskStatus()) {
+		return nil
+	}
+
+	err = conn.Model(&TaskSubRunLogModel{}).
+		Where(DbFieldTaskId + " = ?", taskModelId).
+		Where(DbFieldRunTimes + " = ?", detail.GetRunTimes()).
+		Where(DbFieldSubRunIdx + " = ?", detail.GetSubRunIdx()).
+		Where(DbFieldTaskStatus + " = ?", statusRunning).
+		Updates(map[string]interface{}{
+			DbFieldTaskStatus: toModelStatus(detail.GetTaskStatus()),
+			DbFieldEndedAt: now,
+		}).
+		Error
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return err
+	}
+
+	return nil
+}
+
 func (r *TaskLogRepo) SaveTaskConfirmedLog(ctx context.Context, detail *task.TaskConfirmedLogDetail) error {
 	updateMap := map[string]interface{}{
 		DbFieldEndedAt: time.Now().Unix(),
 		DbFieldRespExtra: detail.GetTaskResp().GetExtra(),
 	}
 
+	var isFinal bool
 	if task.IsTaskSuccess(detail.GetTaskResp().GetTaskStatus()) {
 		updateMap[DbFieldTaskStatus] = statusSuccess
+		isFinal = true
 	} else if task.IsTaskFailed(detail.GetTaskResp().GetTaskStatus()) {
 		updateMap[DbFieldTaskStatus] = statusFailed
+		isFinal = true
 	}
 
-	err := r.mustGetConn(ctx).
+	if detail.GetTaskResp().IsTimeout() {
+		updateMap[DbFieldIsTimeout] = true
+	}
+
+	if detail.GetTaskResp().IsCancelled() {
+		updateMap[DbFieldIsCancelled] = true
+	}
+
+	conn := r.mustGetConn(ctx).
 		Model(&TaskLogModel{}).
 		Where(DbFieldTaskId, detail.GetTaskResp().GetTaskId()).
+		Where(DbFieldRunTimes, detail.GetTaskResp().GetTaskRunTimes())
+	// 异步确认(包括超时判定和取消)只能确认执行中的任务,同步执行的结果已经由回调日志记录,只需要排除已经超时或取消的
+	isAsyncConfirmed := detail.GetTaskResp().IsRunInAsync() && isFinal
+	if isAsyncConfirmed {
+		conn = conn.Where(DbFieldTaskStatus + " = ?", statusRunning)
+	} else {
+		conn = conn.Where(DbFieldIsTimeout + " = ?", false).Where(DbFieldIsCancelled + " = ?", false)
+	}
+	res := conn.Updates(updateMap)
+	if res.Error != nil {
+		logger.MustGetRepoLogger().Error(ctx, res.Error)
+		return res.Error
+	}
+
+	if res.RowsAffected > 0 {
+		return nil
+	}
+
+	// 没有更新到数据也可能只是数据没有变化
+	var taskLogModel TaskLogModel
+	err := r.mustGetConn(ctx).
+		Select(DbFieldIsTimeout, DbFieldIsCancelled).
+		Where(DbFieldTaskId, detail.GetTaskResp().GetTaskId()).
 		Where(DbFieldRunTimes, detail.GetTaskResp().GetTaskRunTimes()).
-		Updates(updateMap).
+		Take(&taskLogModel).
 		Error
 	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return errs.NewBizErr(errs.ErrCodeTaskRunNotRunning)
+		}
 		logger.MustGetRepoLogger().Error(ctx, err)
 		return err
 	}
 
+	if taskLogModel.IsTimeout {
+		return errs.NewBizErr(errs.ErrCodeTaskRunTimeout)
+	}
+
+	if taskLogModel.IsCancelled {
+		return errs.NewBizErr(errs.ErrCodeTaskRunCancelled)
+	}
+
+	if isAsyncConfirmed {
+		return errs.NewBizErr(errs.ErrCodeTaskRunNotRunning)
+	}
+
 	return nil
 }
 
@@ -131,7 +327,7 @@ func NewTaskLogRepo(ctx context.Context, connDsn string) (task.TaskLogRepo, erro
 		},
 	}
 	if !migratedTaskLogRepoDB.Load() {
-		if err := repo.mustGetConn(ctx).AutoMigrate(&TaskLogModel{}); err != nil {
+		if err := repo.mustGetConn(ctx).AutoMigrate(&TaskLogModel{}, &TaskSubRunLogModel{}); err != nil {
 			logger.MustGetRepoLogger().Error(ctx, err)
 			return nil, err
 		}
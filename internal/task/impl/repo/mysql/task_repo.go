@@ -2,6 +2,7 @@ package mysql
 
 import (
 	"context"
+	"fmt"
 	"github.com/995933447/easytask/internal/task"
 	"github.com/995933447/easytask/internal/util/logger"
 	"github.com/995933447/easytask/pkg/errs"
@@ -10,6 +11,7 @@ import (
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 	"math"
+	"strconv"
 	"sync/atomic"
 	"time"
 )
@@ -19,6 +21,7 @@ var migratedTaskRepoDB atomic.Bool
 type TaskRepo struct {
 	srvRepo task.TaskCallbackSrvRepo
 	logRepo task.TaskLogRepo
+	workflowRepo task.WorkflowRepo
 	repoConnector
 }
 
@@ -35,6 +38,90 @@ func (r *TaskRepo) DelTaskById(ctx context.Context, id string) error {
 	return nil
 }
 
+func (r *TaskRepo) PauseTask(ctx context.Context, id string) error {
+	taskModelId, err := toTaskModelId(id)
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return err
+	}
+
+	conn := r.mustGetConn(ctx)
+
+	var taskModel TaskModel
+	if err = conn.Select(DbFieldId).Where(DbFieldId + " = ?", taskModelId).Take(&taskModel).Error; err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		if err == gorm.ErrRecordNotFound {
+			return errs.NewBizErr(errs.ErrCodeTaskNotFound)
+		}
+		return err
+	}
+
+	err = conn.Model(&TaskModel{}).
+		Where(DbFieldId + " = ?", taskModelId).
+		Update(DbFieldIsPaused, true).
+		Error
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *TaskRepo) ResumeTask(ctx context.Context, id string) error {
+	oneTask, err := r.GetTaskById(ctx, id)
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return err
+	}
+
+	if !oneTask.IsPaused() {
+		return nil
+	}
+
+	taskModelId, err := toTaskModelId(id)
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return err
+	}
+
+	// 暂停期间错过的调度计划触发不再补.触发队列中的重试、手动和工作流触发在暂停期间保留,恢复后执行
+	updates := map[string]interface{}{
+		DbFieldIsPaused: false,
+	}
+
+	// 固定延时任务上次调度计划触发的执行还没结束时保持等待,执行结束后再计算下次执行时间
+	isWaitingRunEnded := oneTask.GetSchedMode() == task.SchedModeFixedDelay && oneTask.GetPlanSchedNextAt() == task.SchedNextAtWaitingRunEnded
+	if isWaitingRunEnded {
+		if isWaitingRunEnded, err = r.isLastRunRunning(ctx, taskModelId); err != nil {
+			logger.MustGetRepoLogger().Error(ctx, err)
+			return err
+		}
+	}
+
+	if !isWaitingRunEnded {
+		schedNextAt, err := oneTask.GetSchedNextAt()
+		if err != nil {
+			logger.MustGetRepoLogger().Error(ctx, err)
+			return err
+		}
+		updates[DbFieldPlanSchedNextAt] = schedNextAt
+	}
+
+	err = r.mustGetConn(ctx).
+		Model(&TaskModel{}).
+		Where(DbFieldId + " = ?", taskModelId).
+		Where(DbFieldIsPaused + " = ?", true).
+		Updates(updates).
+		Error
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return err
+	}
+
+	return nil
+}
+
 func (r *TaskRepo) GetTaskById(ctx context.Context, id string) (*task.Task, error) {
 	taskModelId, err := toTaskModelId(id)
 	if err != nil {
@@ -63,33 +150,7 @@ func (r *TaskRepo) GetTaskById(ctx context.Context, id string) (*task.Task, erro
 		return nil, errs.NewBizErr(errs.ErrCodeTaskCallbackSrvNotFound)
 	}
 
-	srv := srvs[0]
-
-	schedMode, err := taskModel.toEntitySchedMode()
-	if err != nil {
-		logger.MustGetRepoLogger().Error(ctx, err)
-		return nil, err
-	}
-
-	var timeSpecAt int64
-	if taskModel.SchedMode == schedModeTimeSpec {
-		timeSpecAt = taskModel.PlanSchedNextAt
-	}
-
-	oneTask, err := task.NewTask(&task.NewTaskReq{
-		Id: taskModel.toEntityId(),
-		CallbackSrv: srv,
-		CallbackPath: taskModel.CallbackPath,
-		Name: taskModel.Name,
-		Arg: taskModel.Arg,
-		RunTimes: taskModel.RunTimes,
-		LastRunAt: taskModel.LastRunAt,
-		AllowMaxRunTimes: taskModel.AllowMaxRunTimes,
-		SchedMode: schedMode,
-		TimeCronExpr: taskModel.TimeCronExpr,
-		TimeIntervalSec: taskModel.TimeIntervalSec,
-		TimeSpecAt: timeSpecAt,
-	})
+	oneTask, err := taskModel.toEntity(srvs[0])
 	if err != nil {
 		logger.MustGetRepoLogger().Error(ctx, err)
 		return nil, err
@@ -117,11 +178,41 @@ func (r *TaskRepo) AddTask(ctx context.Context, oneTask *task.Task) (string, err
 		return "", err
 	}
 
+	misfirePolicy, err := toTaskModelMisfirePolicy(oneTask.GetMisfirePolicy())
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return "", err
+	}
+
+	concurrencyPolicy, err := toTaskModelConcurrencyPolicy(oneTask.GetConcurrencyPolicy())
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return "", err
+	}
+
+	dispatchMode, err := toTaskModelDispatchMode(oneTask.GetDispatchMode())
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return "", err
+	}
+
+	broadcastSuccessRule, err := toTaskModelBroadcastSuccessRule(oneTask.GetBroadcastSuccessRule())
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return "", err
+	}
+
+	routeStrategy, err := toTaskModelRouteStrategy(oneTask.GetRouteStrategy())
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return "", err
+	}
+
 	var allowMaxRunTimes int
 	switch oneTask.GetSchedMode() {
 	case task.SchedModeTimeSpec:
 		allowMaxRunTimes = 1
-	case task.SchedModeTimeCron, task.SchedModeTimeInterval:
+	case task.SchedModeTimeCron, task.SchedModeTimeInterval, task.SchedModeFixedDelay:
 		allowMaxRunTimes = math.MaxInt
 	}
 
@@ -131,13 +222,34 @@ func (r *TaskRepo) AddTask(ctx context.Context, oneTask *task.Task) (string, err
 		Arg:              oneTask.GetArg(),
 		SchedMode:        schedMode,
 		TimeCronExpr:     oneTask.GetTimeCronExpr(),
+		TimeZone:         oneTask.GetTimeZone(),
 		TimeIntervalSec:  oneTask.GetTimeIntervalSec(),
 		PlanSchedNextAt:  schedNextAt,
 		AllowMaxRunTimes: allowMaxRunTimes,
 		CallbackPath:     oneTask.GetCallbackPath(),
+		CallbackMethod:   string(oneTask.GetCallbackMethod()),
+		CallbackContentType: string(oneTask.GetCallbackContentType()),
 		CallbackSrvId:    srvId,
 		BizId: 			  oneTask.GetBizId(),
-		MaxRunTimeSec:    oneTask.GetTimeIntervalSec(),
+		MaxRunTimeSec:    oneTask.GetMaxRunTimeSec(),
+		IsMaxRunTimeSecSet: true,
+		MisfirePolicy:    misfirePolicy,
+		MisfireMaxCatchUpTimes: oneTask.GetMisfireMaxCatchUpTimes(),
+		MisfireThresholdSec: oneTask.GetMisfireThresholdSec(),
+		ConcurrencyPolicy: concurrencyPolicy,
+		DispatchMode: dispatchMode,
+		BroadcastSuccessRule: broadcastSuccessRule,
+		ShardTotal: oneTask.GetShardTotal(),
+		RouteStrategy: routeStrategy,
+		LabelSelector: oneTask.GetLabelSelector(),
+		FailoverMaxAttempts: oneTask.GetFailoverMaxAttempts(),
+	}
+	if retryPolicy := oneTask.GetRetryPolicy(); retryPolicy != nil {
+		taskModel.RetryMaxAttempts = retryPolicy.GetMaxAttempts()
+		taskModel.RetryInitialDelaySec = retryPolicy.GetInitialDelaySec()
+		taskModel.RetryMultiplier = retryPolicy.GetMultiplier()
+		taskModel.RetryMaxDelaySec = retryPolicy.GetMaxDelaySec()
+		taskModel.RetryJitter = retryPolicy.GetJitter()
 	}
 	res := conn.Unscoped().
 		Where(DbFieldName + " = ?", taskModel.Name).
@@ -152,14 +264,33 @@ func (r *TaskRepo) AddTask(ctx context.Context, oneTask *task.Task) (string, err
 		updates := map[string]interface{}{
 			DbFieldSchedMode: schedMode,
 			DbFieldTimeCronExpr: oneTask.GetTimeCronExpr(),
+			DbFieldTimeZone: oneTask.GetTimeZone(),
 			DbFieldTimeIntervalSec: oneTask.GetTimeIntervalSec(),
 			DbFieldPlanSchedNextAt: schedNextAt,
 			DbFieldCallbackSrvId: srvId,
 			DbFieldCallbackPath: oneTask.GetCallbackPath(),
+			DbFieldCallbackMethod: string(oneTask.GetCallbackMethod()),
+			DbFieldCallbackContentType: string(oneTask.GetCallbackContentType()),
 			DbFieldAllowMaxRunTimes: allowMaxRunTimes,
 			DbFieldMaxRunTimeSec: taskModel.MaxRunTimeSec,
+			DbFieldIsMaxRunTimeSecSet: true,
 			DbFieldDeletedAt: 0,
 			DbFieldArg: oneTask.GetArg(),
+			DbFieldRetryMaxAttempts: taskModel.RetryMaxAttempts,
+			DbFieldRetryInitialDelaySec: taskModel.RetryInitialDelaySec,
+			DbFieldRetryMultiplier: taskModel.RetryMultiplier,
+			DbFieldRetryMaxDelaySec: taskModel.RetryMaxDelaySec,
+			DbFieldRetryJitter: taskModel.RetryJitter,
+			DbFieldMisfirePolicy: misfirePolicy,
+			DbFieldMisfireMaxCatchUpTimes: taskModel.MisfireMaxCatchUpTimes,
+			DbFieldMisfireThresholdSec: taskModel.MisfireThresholdSec,
+			DbFieldConcurrencyPolicy: concurrencyPolicy,
+			DbFieldDispatchMode: dispatchMode,
+			DbFieldBroadcastSuccessRule: broadcastSuccessRule,
+			DbFieldShardTotal: oneTask.GetShardTotal(),
+			DbFieldRouteStrategy: routeStrategy,
+			DbFieldLabelSelector: Labels(oneTask.GetLabelSelector()),
+			DbFieldFailoverMaxAttempts: oneTask.GetFailoverMaxAttempts(),
 		}
 		if schedMode == schedModeTimeSpec && (taskModel.SchedMode != schedModeTimeSpec || taskModel.PlanSchedNextAt != schedNextAt) {
 			updates[DbFieldAllowMaxRunTimes] = gorm.Expr(DbFieldRunTimes + " + ?", allowMaxRunTimes)
@@ -195,6 +326,7 @@ func (r *TaskRepo) TimeoutTasks(ctx context.Context, size int, cursor string) ([
 		Where(DbFieldId + " > ?", cursorTaskModelId).
 		Where(DbFieldRunTimes + " < " + DbFieldAllowMaxRunTimes).
 		Where(DbFieldPlanSchedNextAt + " <= ?", time.Now().Unix()).
+		Where(DbFieldIsPaused + " = ?", false).
 		Limit(size).
 		Order(clause.OrderByColumn{Column: clause.Column{Name: DbFieldId}, Desc: false}).
 		Find(&taskModels).
@@ -208,6 +340,38 @@ func (r *TaskRepo) TimeoutTasks(ctx context.Context, size int, cursor string) ([
 		return nil, "", nil
 	}
 
+	callbackSrvMap, err := r.getCallbackSrvMap(ctx, taskModels)
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return nil, "", err
+	}
+
+	var tasks []*task.Task
+	for _, taskModel := range taskModels {
+		callbackSrvId := toTaskCallbackSrvEntityId(taskModel.CallbackSrvId)
+		callbackSrv, ok := callbackSrvMap[callbackSrvId]
+		if !ok {
+			continue
+		}
+
+		oneTask, err := taskModel.toEntity(callbackSrv)
+		if err != nil {
+			logger.MustGetRepoLogger().Error(ctx, err)
+			return nil, "", err
+		}
+
+		tasks = append(tasks, oneTask)
+	}
+
+	var nextCursor string
+	if len(tasks) > 0 {
+		nextCursor = tasks[len(tasks) - 1].GetId()
+	}
+
+	return tasks, nextCursor, nil
+}
+
+func (r *TaskRepo) getCallbackSrvMap(ctx context.Context, taskModels []*TaskModel) (map[string]*task.TaskCallbackSrv, error) {
 	callbackSrvModelIds := reflectutil.PluckUint64(taskModels, FieldCallbackSrvId)
 	var (
 		callbackSrvIds []string
@@ -225,7 +389,7 @@ func (r *TaskRepo) TimeoutTasks(ctx context.Context, size int, cursor string) ([
 	callbackSrvs, err := r.srvRepo.GetSrvsByIds(ctx, callbackSrvIds)
 	if err != nil {
 		logger.MustGetRepoLogger().Error(ctx, err)
-		return nil, "", err
+		return nil, err
 	}
 
 	callbackSrvMap := make(map[string]*task.TaskCallbackSrv)
@@ -233,75 +397,233 @@ func (r *TaskRepo) TimeoutTasks(ctx context.Context, size int, cursor string) ([
 		callbackSrvMap[srv.GetId()] = srv
 	}
 
-	var tasks []*task.Task
+	return callbackSrvMap, nil
+}
+
+// 返回没有读取到但是仍然存在的回调服务,和已经被删除的服务区分开
+func (r *TaskRepo) getUnreadableSrvModelIds(ctx context.Context, taskModels []*TaskModel, callbackSrvMap map[string]*task.TaskCallbackSrv) (map[uint64]struct{}, error) {
+	var missingSrvModelIds []uint64
 	for _, taskModel := range taskModels {
-		callbackSrvId := toTaskCallbackSrvEntityId(taskModel.CallbackSrvId)
-		callbackSrv, ok := callbackSrvMap[callbackSrvId]
+		if _, ok := callbackSrvMap[toTaskCallbackSrvEntityId(taskModel.CallbackSrvId)]; !ok {
+			missingSrvModelIds = append(missingSrvModelIds, taskModel.CallbackSrvId)
+		}
+	}
+
+	unreadableSrvModelIds := make(map[uint64]struct{})
+	if len(missingSrvModelIds) == 0 {
+		return unreadableSrvModelIds, nil
+	}
+
+	var existingSrvModelIds []uint64
+	err := r.mustGetConn(ctx).
+		Model(&TaskCallbackSrvModel{}).
+		Where(DbFieldId + " IN ?", missingSrvModelIds).
+		Pluck(DbFieldId, &existingSrvModelIds).
+		Error
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	for _, srvModelId := range existingSrvModelIds {
+		unreadableSrvModelIds[srvModelId] = struct{}{}
+	}
+
+	return unreadableSrvModelIds, nil
+}
+
+func (r *TaskRepo) TimeoutTriggeredTasks(ctx context.Context, size int) ([]*task.Task, error) {
+	conn := r.mustGetConn(ctx)
+
+	// 暂停的任务的触发保留在队列中,恢复后再执行
+	pausedTaskModelIds := r.mustGetConn(ctx).
+		Model(&TaskModel{}).
+		Select(DbFieldId).
+		Where(DbFieldIsPaused + " = ?", true)
+
+	var triggerModels []*TaskTriggerModel
+	err := conn.Where(DbFieldConsumedAt + " = 0").
+		Where(DbFieldPlanAt + " <= ?", time.Now().Unix()).
+		Where(DbFieldTaskId + " NOT IN (?)", pausedTaskModelIds).
+		Limit(size).
+		Order(clause.OrderByColumn{Column: clause.Column{Name: DbFieldId}, Desc: false}).
+		Find(&triggerModels).
+		Error
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	if len(triggerModels) == 0 {
+		return nil, nil
+	}
+
+	var taskModels []*TaskModel
+	err = conn.Where(DbFieldId + " IN ?", reflectutil.PluckUint64(triggerModels, FieldTaskId)).
+		Find(&taskModels).
+		Error
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	taskModelMap := make(map[uint64]*TaskModel)
+	for _, taskModel := range taskModels {
+		taskModelMap[taskModel.Id] = taskModel
+	}
+
+	callbackSrvMap, err := r.getCallbackSrvMap(ctx, taskModels)
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	unreadableSrvModelIds, err := r.getUnreadableSrvModelIds(ctx, taskModels, callbackSrvMap)
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	var tasks []*task.Task
+	for _, triggerModel := range triggerModels {
+		taskModel, ok := taskModelMap[triggerModel.TaskId]
 		if !ok {
+			// 任务已经被删除,触发直接作废,工作流节点按失败处理
+			if err = r.discardTrigger(ctx, triggerModel, "task not found"); err != nil {
+				logger.MustGetRepoLogger().Error(ctx, err)
+				return nil, err
+			}
 			continue
 		}
 
-		schedMode, err := taskModel.toEntitySchedMode()
-		if err != nil {
-			logger.MustGetRepoLogger().Error(ctx, err)
-			return nil, "", err
+		callbackSrv, ok := callbackSrvMap[toTaskCallbackSrvEntityId(taskModel.CallbackSrvId)]
+		if !ok {
+			// 回调服务还在但是读取失败,保留触发等服务恢复
+			if _, ok = unreadableSrvModelIds[taskModel.CallbackSrvId]; ok {
+				continue
+			}
+			// 回调服务已经被删除,不作废会一直占着每页的位置
+			if err = r.discardTrigger(ctx, triggerModel, "callback srv of task not found"); err != nil {
+				logger.MustGetRepoLogger().Error(ctx, err)
+				return nil, err
+			}
+			continue
 		}
 
-		var timeSpecAt int64
-		if taskModel.SchedMode == schedModeTimeSpec {
-			timeSpecAt = taskModel.PlanSchedNextAt
-		}
-
-		oneTask, err := task.NewTask(&task.NewTaskReq{
-			Id: taskModel.toEntityId(),
-			CallbackSrv: callbackSrv,
-			CallbackPath: taskModel.CallbackPath,
-			Name: taskModel.Name,
-			Arg: taskModel.Arg,
-			RunTimes: taskModel.RunTimes,
-			LastRunAt: taskModel.LastRunAt,
-			AllowMaxRunTimes: taskModel.AllowMaxRunTimes,
-			SchedMode: schedMode,
-			TimeSpecAt: timeSpecAt,
-			TimeIntervalSec: taskModel.TimeIntervalSec,
-			TimeCronExpr: taskModel.TimeCronExpr,
-			BizId: taskModel.BizId,
-		})
+		oneTask, err := taskModel.toTriggeredEntity(callbackSrv, triggerModel)
 		if err != nil {
 			logger.MustGetRepoLogger().Error(ctx, err)
-			return nil, "", err
+			return nil, err
 		}
 
 		tasks = append(tasks, oneTask)
 	}
 
-	var nextCursor string
-	if len(tasks) > 0 {
-		nextCursor = tasks[len(tasks) - 1].GetId()
+	return tasks, nil
+}
+
+func (r *TaskRepo) AddTaskTrigger(ctx context.Context, taskId string, trigger *task.TaskTrigger) (string, error) {
+	taskModelId, err := toTaskModelId(taskId)
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return "", err
 	}
 
-	return tasks, nextCursor, nil
+	triggerType, err := toTaskModelTriggerType(trigger.GetTriggerType())
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return "", err
+	}
+
+	triggerModel := &TaskTriggerModel{
+		TaskId: taskModelId,
+		PlanAt: trigger.GetPlanAt(),
+		TriggerType: triggerType,
+		Attempt: trigger.GetAttempt(),
+		WorkflowNode: trigger.GetWorkflowNode(),
+	}
+	triggerModel.Arg, triggerModel.HasArg = trigger.GetArg()
+	if trigger.GetWorkflowRunId() != "" {
+		if triggerModel.WorkflowRunId, err = toWorkflowRunModelId(trigger.GetWorkflowRunId()); err != nil {
+			logger.MustGetRepoLogger().Error(ctx, err)
+			return "", err
+		}
+	}
+
+	if err = r.mustGetConn(ctx).Create(triggerModel).Error; err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return "", err
+	}
+
+	return fmt.Sprintf("%d", triggerModel.Id), nil
+}
+
+func (r *TaskRepo) discardTrigger(ctx context.Context, triggerModel *TaskTriggerModel, reason string) error {
+	logger.MustGetRepoLogger().Warnf(ctx, "trigger(id:%d) of task(id:%d) discarded, reason:%s", triggerModel.Id, triggerModel.TaskId, reason)
+
+	res := r.mustGetConn(ctx).
+		Model(&TaskTriggerModel{}).
+		Where(DbFieldId + " = ?", triggerModel.Id).
+		Where(DbFieldConsumedAt + " = 0").
+		Update(DbFieldConsumedAt, time.Now().Unix())
+	if res.Error != nil {
+		logger.MustGetRepoLogger().Error(ctx, res.Error)
+		return res.Error
+	}
+
+	if res.RowsAffected == 0 || triggerModel.WorkflowRunId == 0 {
+		return nil
+	}
+
+	err := r.workflowRepo.ConfirmWorkflowNode(ctx, toWorkflowRunEntityId(triggerModel.WorkflowRunId), triggerModel.WorkflowNode, task.StatusFailed, 0)
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return err
+	}
+
+	return nil
 }
 
 func (r *TaskRepo) LockTask(ctx context.Context, oneTask *task.Task) (bool, error) {
+	if oneTask.GetTrigger() != nil {
+		return r.lockTriggeredTask(ctx, oneTask)
+	}
+
 	var now = time.Now().Unix()
 
-	schedNextAt, err := oneTask.GetSchedNextAt()
+	decision := oneTask.GetSchedDecision()
+	if decision == nil {
+		var err error
+		if decision, err = oneTask.DecideSched(time.Now(), task.DefaultMisfireToleranceSec); err != nil {
+			logger.MustGetRepoLogger().Error(ctx, err)
+			return false, err
+		}
+	}
+
+	taskModelId, err := toTaskModelId(oneTask.GetId())
 	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
 		return false, err
 	}
 
+	if oneTask.GetConcurrencyPolicy() != task.ConcurrencyPolicyAllow {
+		isLastRunRunning, err := r.isLastRunRunning(ctx, taskModelId)
+		if err != nil {
+			logger.MustGetRepoLogger().Error(ctx, err)
+			return false, err
+		}
+		// 排队的触发不锁定任务,由调度器根据决策中的排队标记延后到下一轮
+		if !oneTask.ResolveConcurrency(isLastRunRunning) {
+			return false, nil
+		}
+	}
+
 	taskModelUpdates := map[string]interface{}{
 		DbFieldLastRunAt:       now,
 		DbFieldRunTimes:        gorm.Expr(DbFieldRunTimes + " + 1"),
-		DbFieldPlanSchedNextAt: schedNextAt,
+		DbFieldPlanSchedNextAt: decision.GetSchedNextAt(),
 	}
 
-	taskModelId, err := toTaskModelId(oneTask.GetId())
-	if err != nil {
-		logger.MustGetRepoLogger().Error(ctx, err)
-		return false, err
-	}
 	conn := r.mustGetConn(ctx)
 	res := conn.
 		Model(&TaskModel{}).
@@ -329,11 +651,151 @@ func (r *TaskRepo) LockTask(ctx context.Context, oneTask *task.Task) (bool, erro
 	return true, nil
 }
 
-func (r *TaskRepo) ConfirmTask(ctx context.Context, resp *task.TaskResp) error {
-	now := time.Now().Unix()
-	conn := r.mustGetConn(ctx)
-	taskModelId, err := toTaskModelId(resp.GetTaskId())
-
+// 以调度计划触发的最近一次没有被跳过的执行日志为准,判断上一次执行是否还没结束.手动和工作流触发的执行不影响调度计划
+func (r *TaskRepo) isLastRunRunning(ctx context.Context, taskModelId uint64) (bool, error) {
+	var taskLogModel TaskLogModel
+	err := r.mustGetConn(ctx).
+		Select(DbFieldTaskStatus).
+		Where(DbFieldTaskId + " = ?", taskModelId).
+		Where(DbFieldTriggerType + " = ?", triggerTypeSched).
+		Where(DbFieldTaskStatus + " != ?", statusSkipped).
+		Order(clause.OrderByColumn{Column: clause.Column{Name: DbFieldRunTimes}, Desc: true}).
+		Take(&taskLogModel).
+		Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return false, nil
+		}
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return false, err
+	}
+	return taskLogModel.TaskStatus == statusRunning, nil
+}
+
+// 消费触发记录,不改变任务的调度计划,只占用一次执行次数
+func (r *TaskRepo) lockTriggeredTask(ctx context.Context, oneTask *task.Task) (bool, error) {
+	taskModelId, err := toTaskModelId(oneTask.GetId())
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return false, err
+	}
+
+	triggerModelId, err := strconv.ParseUint(oneTask.GetTrigger().GetId(), 10, 64)
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return false, err
+	}
+
+	// 调度计划触发的执行的重试仍按并发策略处理还没结束的调度计划触发的执行
+	if oneTask.GetTrigger().GetTriggerType() == task.TriggerTypeSched && oneTask.GetConcurrencyPolicy() != task.ConcurrencyPolicyAllow {
+		isLastRunRunning, err := r.isLastRunRunning(ctx, taskModelId)
+		if err != nil {
+			logger.MustGetRepoLogger().Error(ctx, err)
+			return false, err
+		}
+		if isLastRunRunning {
+			// 排队时保留触发记录,上次执行结束后再重试
+			if oneTask.GetConcurrencyPolicy() == task.ConcurrencyPolicyQueue {
+				return false, nil
+			}
+			triggerModel := &TaskTriggerModel{TaskId: taskModelId}
+			triggerModel.Id = triggerModelId
+			if err = r.discardTrigger(ctx, triggerModel, "last run is running and concurrency forbidden"); err != nil {
+				logger.MustGetRepoLogger().Error(ctx, err)
+				return false, err
+			}
+			return false, nil
+		}
+	}
+
+	var locked bool
+	err = r.mustGetConn(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now().Unix()
+		res := tx.Model(&TaskTriggerModel{}).
+			Where(DbFieldId + " = ?", triggerModelId).
+			Where(DbFieldConsumedAt + " = 0").
+			Update(DbFieldConsumedAt, now)
+		if res.Error != nil {
+			return res.Error
+		}
+
+		if res.RowsAffected == 0 {
+			return nil
+		}
+
+		var taskModel TaskModel
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where(DbFieldId + " = ?", taskModelId).
+			Take(&taskModel).
+			Error
+		if err != nil {
+			return err
+		}
+
+		runTimes := taskModel.RunTimes + 1
+		// 触发的执行不占用调度计划的可执行次数
+		allowMaxRunTimes := taskModel.AllowMaxRunTimes
+		if allowMaxRunTimes < math.MaxInt {
+			allowMaxRunTimes++
+		}
+		err = tx.Model(&TaskModel{}).
+			Where(DbFieldId + " = ?", taskModelId).
+			Updates(map[string]interface{}{
+				DbFieldLastRunAt: now,
+				DbFieldRunTimes: runTimes,
+				DbFieldAllowMaxRunTimes: allowMaxRunTimes,
+			}).
+			Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&TaskTriggerModel{}).
+			Where(DbFieldId + " = ?", triggerModelId).
+			Update(DbFieldRunTimes, runTimes).
+			Error
+		if err != nil {
+			return err
+		}
+
+		oneTask.SetRunTimes(runTimes)
+
+		if err = createTaskStartedLog(tx, task.NewTaskStartedLogDetail(oneTask)); err != nil {
+			return err
+		}
+
+		locked = true
+
+		return nil
+	})
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return false, err
+	}
+
+	return locked, nil
+}
+
+func (r *TaskRepo) ConfirmTask(ctx context.Context, resp *task.TaskResp) error {
+	now := time.Now().Unix()
+	conn := r.mustGetConn(ctx)
+	taskModelId, err := toTaskModelId(resp.GetTaskId())
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return err
+	}
+
+	if resp.GetSubRunIdx() > 0 {
+		return r.confirmSubRun(ctx, taskModelId, resp)
+	}
+
+	// 先确认日志,迟到或重复的确认在这里被拒绝
+	err = r.logRepo.SaveTaskConfirmedLog(ctx, task.NewTaskConfirmedLogDetail(resp))
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return err
+	}
+
 	if task.IsTaskSuccess(resp.GetTaskStatus()) {
 		err = conn.Model(&TaskModel{}).
 			Where(DbFieldId + " = ?", taskModelId).
@@ -358,15 +820,498 @@ func (r *TaskRepo) ConfirmTask(ctx context.Context, resp *task.TaskResp) error {
 		}
 	}
 
-	err = r.logRepo.SaveTaskConfirmedLog(ctx, task.NewTaskConfirmedLogDetail(resp))
+	if !task.IsTaskSuccess(resp.GetTaskStatus()) && !task.IsTaskFailed(resp.GetTaskStatus()) {
+		// 广播或分片执行的子执行可能在本次确认之前就已经全部结束
+		return r.confirmEndedSubRuns(ctx, taskModelId, resp)
+	}
+
+	var taskLogModel TaskLogModel
+	err = conn.Select(DbFieldAttempt, DbFieldTriggerType, DbFieldWorkflowRunId, DbFieldWorkflowNode).
+		Where(DbFieldTaskId + " = ?", taskModelId).
+		Where(DbFieldRunTimes + " = ?", resp.GetTaskRunTimes()).
+		Take(&taskLogModel).
+		Error
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		if err == gorm.ErrRecordNotFound {
+			return nil
+		}
+		return err
+	}
+
+	// 取消的执行不再重试
+	var retried bool
+	if task.IsTaskFailed(resp.GetTaskStatus()) && !resp.IsCancelled() {
+		retried, err = r.retryTask(ctx, taskModelId, resp.GetTaskRunTimes(), &taskLogModel)
+		if err != nil {
+			logger.MustGetRepoLogger().Error(ctx, err)
+			return err
+		}
+	}
+
+	// 安排了重试时固定延时任务等重试的执行结束后再计算下次执行时间
+	if taskLogModel.TriggerType == triggerTypeSched && !retried {
+		if err = r.schedFixedDelayTask(ctx, taskModelId, resp.GetTaskRunTimes()); err != nil {
+			logger.MustGetRepoLogger().Error(ctx, err)
+			return err
+		}
+	}
+
+	if retried {
+		return nil
+	}
+
+	// 成功或者重试耗尽后才算工作流节点结束
+	if taskLogModel.WorkflowRunId > 0 {
+		err = r.workflowRepo.ConfirmWorkflowNode(
+			ctx,
+			toWorkflowRunEntityId(taskLogModel.WorkflowRunId),
+			taskLogModel.WorkflowNode,
+			resp.GetTaskStatus(),
+			resp.GetTaskRunTimes(),
+			)
+		if err != nil {
+			logger.MustGetRepoLogger().Error(ctx, err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// 确认广播或分片执行中的一个子执行,子执行的结果能决定整次执行的结果时确认整次执行
+func (r *TaskRepo) confirmSubRun(ctx context.Context, taskModelId uint64, resp *task.TaskResp) error {
+	if !task.IsTaskSuccess(resp.GetTaskStatus()) && !task.IsTaskFailed(resp.GetTaskStatus()) {
+		return nil
+	}
+
+	conn := r.mustGetConn(ctx)
+
+	var taskLogModel TaskLogModel
+	err := conn.Select(DbFieldIsTimeout, DbFieldIsCancelled).
+		Where(DbFieldTaskId + " = ?", taskModelId).
+		Where(DbFieldRunTimes + " = ?", resp.GetTaskRunTimes()).
+		Take(&taskLogModel).
+		Error
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		if err == gorm.ErrRecordNotFound {
+			return errs.NewBizErr(errs.ErrCodeTaskRunNotRunning)
+		}
+		return err
+	}
+
+	if taskLogModel.IsTimeout {
+		return errs.NewBizErr(errs.ErrCodeTaskRunTimeout)
+	}
+
+	if taskLogModel.IsCancelled {
+		return errs.NewBizErr(errs.ErrCodeTaskRunCancelled)
+	}
+
+	res := conn.Model(&TaskSubRunLogModel{}).
+		Where(DbFieldTaskId + " = ?", taskModelId).
+		Where(DbFieldRunTimes + " = ?", resp.GetTaskRunTimes()).
+		Where(DbFieldSubRunIdx + " = ?", resp.GetSubRunIdx()).
+		Where(DbFieldTaskStatus + " = ?", statusRunning).
+		Updates(map[string]interface{}{
+			DbFieldTaskStatus: toModelStatus(resp.GetTaskStatus()),
+			DbFieldEndedAt: time.Now().Unix(),
+			DbFieldRespExtra: resp.GetExtra(),
+		})
+	if res.Error != nil {
+		logger.MustGetRepoLogger().Error(ctx, res.Error)
+		return res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		return errs.NewBizErr(errs.ErrCodeTaskRunNotRunning)
+	}
+
+	return r.confirmEndedSubRuns(ctx, taskModelId, resp)
+}
+
+// 子执行的状态已经能决定整次执行的结果时确认整次执行,整次执行已经被确认过时忽略
+func (r *TaskRepo) confirmEndedSubRuns(ctx context.Context, taskModelId uint64, resp *task.TaskResp) error {
+	conn := r.mustGetConn(ctx)
+
+	var subRunLogModels []*TaskSubRunLogModel
+	err := conn.Select(DbFieldTaskStatus).
+		Where(DbFieldTaskId + " = ?", taskModelId).
+		Where(DbFieldRunTimes + " = ?", resp.GetTaskRunTimes()).
+		Find(&subRunLogModels).
+		Error
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return err
+	}
+
+	if len(subRunLogModels) == 0 {
+		return nil
+	}
+
+	var taskModel TaskModel
+	err = conn.Select(DbFieldBroadcastSuccessRule, DbFieldShardTotal).Where(DbFieldId + " = ?", taskModelId).Take(&taskModel).Error
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		if err == gorm.ErrRecordNotFound {
+			return nil
+		}
+		return err
+	}
+
+	var subRunStatuses []task.Status
+	for _, subRunLogModel := range subRunLogModels {
+		subRunStatuses = append(subRunStatuses, toEntityStatus(subRunLogModel.TaskStatus))
+	}
+
+	var status task.Status
+	if taskModel.ShardTotal > 0 {
+		status = task.AggregateShardStatus(subRunStatuses)
+	} else {
+		rule, err := toTaskEntityBroadcastSuccessRule(taskModel.BroadcastSuccessRule)
+		if err != nil {
+			logger.MustGetRepoLogger().Error(ctx, err)
+			return err
+		}
+		status = task.AggregateSubRunStatus(rule, subRunStatuses)
+	}
+	if status == task.StatusRunning {
+		return nil
+	}
+
+	err = r.ConfirmTask(ctx, task.NewTaskResp(resp.GetTaskId(), true, status, resp.GetTaskRunTimes(), ""))
+	if err != nil {
+		if bizErr, ok := err.(*errs.BizError); ok && bizErr.Code() == errs.ErrCodeTaskRunNotRunning {
+			return nil
+		}
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *TaskRepo) DeadlineExceededRuns(ctx context.Context, size int) ([]*task.TaskResp, error) {
+	var taskLogModels []*TaskLogModel
+	err := r.mustGetConn(ctx).
+		Select(DbFieldTaskId, DbFieldRunTimes).
+		Where(DbFieldTaskStatus + " = ?", statusRunning).
+		Where(DbFieldDeadlineAt + " > 0").
+		Where(DbFieldDeadlineAt + " <= ?", time.Now().Unix()).
+		Limit(size).
+		Find(&taskLogModels).
+		Error
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	var resps []*task.TaskResp
+	for _, taskLogModel := range taskLogModels {
+		resps = append(resps, task.NewDeadlineExceededTaskResp(toTaskEntityId(taskLogModel.TaskId), taskLogModel.RunTimes))
+	}
+
+	return resps, nil
+}
+
+// 只记录执行中的任务上报的进度,已经结束,超时或取消的执行拒绝上报
+func (r *TaskRepo) ReportTaskProgress(ctx context.Context, progress *task.TaskRunProgress) error {
+	taskModelId, err := toTaskModelId(progress.GetTaskId())
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return err
+	}
+
+	updateMap := map[string]interface{}{
+		DbFieldHeartbeatAt: progress.GetReportedAt(),
+	}
+	if value, msg, ok := progress.GetProgress(); ok {
+		updateMap[DbFieldProgress] = value
+		updateMap[DbFieldProgressMsg] = msg
+	}
+
+	conn := r.mustGetConn(ctx)
+	res := conn.Model(&TaskLogModel{}).
+		Where(DbFieldTaskId + " = ?", taskModelId).
+		Where(DbFieldRunTimes + " = ?", progress.GetRunTimes()).
+		Where(DbFieldTaskStatus + " = ?", statusRunning).
+		Updates(updateMap)
+	if res.Error != nil {
+		logger.MustGetRepoLogger().Error(ctx, res.Error)
+		return res.Error
+	}
+
+	if res.RowsAffected > 0 {
+		return nil
+	}
+
+	// 没有更新到数据也可能只是同一秒内重复上报
+	var taskLogModel TaskLogModel
+	err = conn.Select(DbFieldTaskStatus, DbFieldIsTimeout, DbFieldIsCancelled).
+		Where(DbFieldTaskId + " = ?", taskModelId).
+		Where(DbFieldRunTimes + " = ?", progress.GetRunTimes()).
+		Take(&taskLogModel).
+		Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return errs.NewBizErr(errs.ErrCodeTaskRunNotFound)
+		}
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return err
+	}
+
+	if taskLogModel.IsTimeout {
+		return errs.NewBizErr(errs.ErrCodeTaskRunTimeout)
+	}
+
+	if taskLogModel.IsCancelled {
+		return errs.NewBizErr(errs.ErrCodeTaskRunCancelled)
+	}
+
+	if taskLogModel.TaskStatus != statusRunning {
+		return errs.NewBizErr(errs.ErrCodeTaskRunNotRunning)
+	}
+
+	return nil
+}
+
+func (r *TaskRepo) GetTaskRun(ctx context.Context, taskId string, runTimes int) (*task.TaskRun, error) {
+	taskModelId, err := toTaskModelId(taskId)
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	var taskLogModel TaskLogModel
+	err = r.mustGetConn(ctx).
+		Where(DbFieldTaskId + " = ?", taskModelId).
+		Where(DbFieldRunTimes + " = ?", runTimes).
+		Take(&taskLogModel).
+		Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, errs.NewBizErr(errs.ErrCodeTaskRunNotFound)
+		}
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	return task.NewTaskRun(&task.NewTaskRunReq{
+		TaskId: taskId,
+		RunTimes: taskLogModel.RunTimes,
+		Status: toEntityStatus(taskLogModel.TaskStatus),
+		StartedAt: taskLogModel.StartedAt,
+		EndedAt: taskLogModel.EndedAt,
+		IsTimeout: taskLogModel.IsTimeout,
+		IsCancelled: taskLogModel.IsCancelled,
+		Progress: taskLogModel.Progress,
+		ProgressMsg: taskLogModel.ProgressMsg,
+		HeartbeatAt: taskLogModel.HeartbeatAt,
+	}), nil
+}
+
+func (r *TaskRepo) RunningRuns(ctx context.Context) ([]*task.TaskRun, error) {
+	var taskLogModels []*TaskLogModel
+	err := r.mustGetConn(ctx).
+		Select(DbFieldTaskId, DbFieldRunTimes, DbFieldSrvId).
+		Where(DbFieldTaskStatus + " = ?", statusRunning).
+		Find(&taskLogModels).
+		Error
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	var runs []*task.TaskRun
+	for _, taskLogModel := range taskLogModels {
+		runs = append(runs, task.NewTaskRun(&task.NewTaskRunReq{
+			TaskId: toTaskEntityId(taskLogModel.TaskId),
+			RunTimes: taskLogModel.RunTimes,
+			CallbackSrvId: toTaskCallbackSrvEntityId(taskLogModel.SrvId),
+			Status: task.StatusRunning,
+		}))
+	}
+
+	return runs, nil
+}
+
+func (r *TaskRepo) HeartbeatLostRuns(ctx context.Context, size int, timeoutSec int) ([]*task.TaskResp, error) {
+	var taskLogModels []*TaskLogModel
+	err := r.mustGetConn(ctx).
+		Select(DbFieldTaskId, DbFieldRunTimes).
+		Where(DbFieldTaskStatus + " = ?", statusRunning).
+		Where(DbFieldHeartbeatAt + " > 0").
+		Where(DbFieldHeartbeatAt + " < ?", time.Now().Unix() - int64(timeoutSec)).
+		Limit(size).
+		Find(&taskLogModels).
+		Error
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	var resps []*task.TaskResp
+	for _, taskLogModel := range taskLogModels {
+		resps = append(resps, task.NewHeartbeatLostTaskResp(toTaskEntityId(taskLogModel.TaskId), taskLogModel.RunTimes))
+	}
+
+	return resps, nil
+}
+
+// 按请求快照还原一次执行回调过的节点,广播或分片执行返回还在执行中的子执行的节点
+func (r *TaskRepo) GetRunCallbackRoutes(ctx context.Context, taskId string, runTimes int) ([]*task.TaskCallbackSrvRoute, error) {
+	taskModelId, err := toTaskModelId(taskId)
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	conn := r.mustGetConn(ctx)
+
+	var subRunLogModels []*TaskSubRunLogModel
+	err = conn.Select(DbFieldTaskStatus, DbFieldReqSnapshot).
+		Where(DbFieldTaskId + " = ?", taskModelId).
+		Where(DbFieldRunTimes + " = ?", runTimes).
+		Find(&subRunLogModels).
+		Error
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	var snapshots []*TaskLogCallbackReqSnapshot
+	if len(subRunLogModels) > 0 {
+		for _, subRunLogModel := range subRunLogModels {
+			if subRunLogModel.TaskStatus == statusRunning && subRunLogModel.ReqSnapshot != nil {
+				snapshots = append(snapshots, subRunLogModel.ReqSnapshot)
+			}
+		}
+	} else {
+		var taskLogModel TaskLogModel
+		err = conn.Select(DbFieldReqSnapshot).
+			Where(DbFieldTaskId + " = ?", taskModelId).
+			Where(DbFieldRunTimes + " = ?", runTimes).
+			Take(&taskLogModel).
+			Error
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return nil, errs.NewBizErr(errs.ErrCodeTaskRunNotRunning)
+			}
+			logger.MustGetRepoLogger().Error(ctx, err)
+			return nil, err
+		}
+		if taskLogModel.ReqSnapshot != nil {
+			snapshots = append(snapshots, taskLogModel.ReqSnapshot)
+		}
+	}
+
+	var routes []*task.TaskCallbackSrvRoute
+	for _, snapshot := range snapshots {
+		routes = append(routes, task.NewTaskCallbackSrvRoute("", snapshot.SrvSchema, snapshot.Host, snapshot.Port, snapshot.TimeoutSec, false, 0, nil))
+	}
+
+	return routes, nil
+}
+
+// 固定延时任务在调度计划触发的执行结束后才从当前时间开始计算下次执行时间,
+// 只处理最近一次调度计划触发的执行的结果,避免迟到的确认提前触发正在执行中的任务.
+// 触发队列中的执行也会占用执行次数,所以不能直接比较任务的run_times.
+// 等待期间不会有新的调度计划触发的执行开始,所以先查后改不会有竞争
+func (r *TaskRepo) schedFixedDelayTask(ctx context.Context, taskModelId uint64, endedRunTimes int) error {
+	conn := r.mustGetConn(ctx)
+
+	var newerSchedRunCount int64
+	err := conn.Model(&TaskLogModel{}).
+		Where(DbFieldTaskId + " = ?", taskModelId).
+		Where(DbFieldTriggerType + " = ?", triggerTypeSched).
+		Where(DbFieldRunTimes + " > ?", endedRunTimes).
+		Count(&newerSchedRunCount).
+		Error
 	if err != nil {
 		logger.MustGetRepoLogger().Error(ctx, err)
 		return err
 	}
 
+	if newerSchedRunCount > 0 {
+		return nil
+	}
+
+	err = conn.
+		Model(&TaskModel{}).
+		Where(DbFieldId + " = ?", taskModelId).
+		Where(DbFieldSchedMode + " = ?", schedModeFixedDelay).
+		Where(DbFieldPlanSchedNextAt + " = ?", task.SchedNextAtWaitingRunEnded).
+		Update(DbFieldPlanSchedNextAt, gorm.Expr("? + " + DbFieldTimeIntervalSec, time.Now().Unix())).
+		Error
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return err
+	}
 	return nil
 }
 
+// 按任务的重试策略把失败的执行重新加入触发队列,由调度器再次触发,不改变任务的调度计划.
+// 重试沿用失败那次执行的触发方式,调度计划触发的执行的重试在锁定时按并发策略处理,并且按调度计划触发的执行计算固定延时.返回是否安排了重试
+func (r *TaskRepo) retryTask(ctx context.Context, taskModelId uint64, failedRunTimes int, failedLog *TaskLogModel) (bool, error) {
+	conn := r.mustGetConn(ctx)
+
+	var taskModel TaskModel
+	if err := conn.Where(DbFieldId + " = ?", taskModelId).Take(&taskModel).Error; err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		if err == gorm.ErrRecordNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+
+	retryPolicy := taskModel.toEntityRetryPolicy()
+	if retryPolicy == nil {
+		return false, nil
+	}
+
+	attempt := failedLog.Attempt + 1
+	if !retryPolicy.CanRetry(attempt) {
+		logger.MustGetRepoLogger().Infof(
+			ctx,
+			"task(id:%d) run(times:%d) exhausted retry attempts(max:%d)",
+			taskModelId, failedRunTimes, retryPolicy.GetMaxAttempts(),
+			)
+		return false, nil
+	}
+
+	retryAt := time.Now().Unix() + int64(retryPolicy.GetDelaySec(attempt))
+
+	// 重试沿用失败那次触发的一次性参数,调度计划触发的第一次执行没有触发记录
+	var failedTriggerModel TaskTriggerModel
+	err := conn.Select(DbFieldArg, DbFieldHasArg).
+		Where(DbFieldTaskId + " = ?", taskModelId).
+		Where(DbFieldRunTimes + " = ?", failedRunTimes).
+		Take(&failedTriggerModel).
+		Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return false, err
+	}
+
+	err = conn.Create(&TaskTriggerModel{
+		TaskId: taskModelId,
+		PlanAt: retryAt,
+		TriggerType: failedLog.TriggerType,
+		Attempt: attempt,
+		WorkflowRunId: failedLog.WorkflowRunId,
+		WorkflowNode: failedLog.WorkflowNode,
+		Arg: failedTriggerModel.Arg,
+		HasArg: failedTriggerModel.HasArg,
+	}).Error
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return false, err
+	}
+
+	return true, nil
+}
+
 func (r *TaskRepo) DelTasks(ctx context.Context, stream *optionstream.Stream) error {
 	conn :=  r.mustGetConn(ctx)
 	err := optionstream.NewStreamProcessor(stream).
@@ -392,17 +1337,18 @@ func (r *TaskRepo) DelTasks(ctx context.Context, stream *optionstream.Stream) er
 	return nil
 }
 
-func NewTaskRepo(ctx context.Context, connDsn string, srvRepo task.TaskCallbackSrvRepo, logRepo task.TaskLogRepo) (task.TaskRepo, error) {
+func NewTaskRepo(ctx context.Context, connDsn string, srvRepo task.TaskCallbackSrvRepo, logRepo task.TaskLogRepo, workflowRepo task.WorkflowRepo) (task.TaskRepo, error) {
 	repo := &TaskRepo{
 		srvRepo: srvRepo,
 		logRepo: logRepo,
+		workflowRepo: workflowRepo,
 		repoConnector: repoConnector{
 			connDsn: connDsn,
 		},
 	}
 
 	if !migratedTaskRepoDB.Load() {
-		if err := repo.mustGetConn(ctx).AutoMigrate(&TaskModel{}, &TaskLogModel{}); err != nil {
+		if err := repo.mustGetConn(ctx).AutoMigrate(&TaskModel{}, &TaskLogModel{}, &TaskTriggerModel{}, &TaskSubRunLogModel{}); err != nil {
 			logger.MustGetRepoLogger().Error(ctx, err)
 			return nil, err
 		}
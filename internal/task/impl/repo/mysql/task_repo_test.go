@@ -2,16 +2,59 @@ package mysql
 
 import (
 	"context"
+	"fmt"
+	"github.com/995933447/easytask/internal/task"
 	"github.com/995933447/easytask/internal/util/logger"
+	"math"
 	"testing"
+	"time"
 )
 
+const testConnDsn = "root:@tcp(127.0.0.1:3306)/easytask?charset=utf8mb4&parseTime=True&loc=Local"
+
+// 依赖本地mysql,连不上时跳过
+func newTestTaskRepo(t *testing.T) *TaskRepo {
+	logger.Init(&logger.Conf{
+		LogDir: "/var/log/easytask/test",
+		FileSize: 1024 * 1024 * 100,
+	})
+	ctx := context.TODO()
+	if _, err := (&repoConnector{connDsn: testConnDsn}).getConn(ctx); err != nil {
+		t.Skipf("mysql not available, err:%v", err)
+	}
+	srvRepo, err := NewTaskSrvRepo(ctx, testConnDsn, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logRepo, err := NewTaskLogRepo(ctx, testConnDsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	workflowRepo, err := NewWorkflowRepo(ctx, testConnDsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	taskRepo, err := NewTaskRepo(ctx, testConnDsn, srvRepo, logRepo, workflowRepo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return taskRepo.(*TaskRepo)
+}
+
+func createTestTaskModel(t *testing.T, repo *TaskRepo, taskModel *TaskModel) *TaskModel {
+	taskModel.Name = fmt.Sprintf("%s_%d", t.Name(), time.Now().UnixNano())
+	if err := repo.mustGetConn(context.TODO()).Create(taskModel).Error; err != nil {
+		t.Fatal(err)
+	}
+	return taskModel
+}
+
 func TestTimeoutTask(t *testing.T) {
 	logger.Init(&logger.Conf{
 		LogDir: "/var/log/easytask/test",
 		FileSize: 1024 * 1024 * 100,
 	})
-	srvRepo, err := NewTaskSrvRepo(context.TODO(), "root:@tcp(127.0.0.1:3306)/easytask?charset=utf8mb4&parseTime=True&loc=Local")
+	srvRepo, err := NewTaskSrvRepo(context.TODO(), testConnDsn, nil)
 	if err != nil {
 		t.Error(err)
 		return
@@ -29,4 +72,278 @@ func TestTimeoutTask(t *testing.T) {
 		t.Error(err)
 	}
 	//t.Log(srvs)
-}
\ No newline at end of file
+}
+
+func TestSchedFixedDelayTaskIgnoreStaleRun(t *testing.T) {
+	repo := newTestTaskRepo(t)
+	ctx := context.TODO()
+	conn := repo.mustGetConn(ctx)
+
+	taskModel := createTestTaskModel(t, repo, &TaskModel{
+		SchedMode: schedModeFixedDelay,
+		TimeIntervalSec: 60,
+		PlanSchedNextAt: task.SchedNextAtWaitingRunEnded,
+		RunTimes: 3,
+	})
+	logModels := []*TaskLogModel{
+		{TaskId: taskModel.Id, RunTimes: 1, TaskStatus: statusSuccess, TriggerType: triggerTypeSched},
+		{TaskId: taskModel.Id, RunTimes: 2, TaskStatus: statusRunning, TriggerType: triggerTypeSched},
+		{TaskId: taskModel.Id, RunTimes: 3, TaskStatus: statusRunning, TriggerType: triggerTypeWorkflow},
+	}
+	if err := conn.Create(&logModels).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	getPlanSchedNextAt := func() int64 {
+		var model TaskModel
+		if err := conn.Where(DbFieldId + " = ?", taskModel.Id).Take(&model).Error; err != nil {
+			t.Fatal(err)
+		}
+		return model.PlanSchedNextAt
+	}
+
+	// 迟到的第1次执行的确认不能提前安排正在执行的第2次之后的调度
+	if err := repo.schedFixedDelayTask(ctx, taskModel.Id, 1); err != nil {
+		t.Fatal(err)
+	}
+	if planSchedNextAt := getPlanSchedNextAt(); planSchedNextAt != task.SchedNextAtWaitingRunEnded {
+		t.Fatalf("expect still waiting run ended, got %d", planSchedNextAt)
+	}
+
+	// 工作流触发的第3次执行不影响最近一次调度计划触发的执行
+	if err := repo.schedFixedDelayTask(ctx, taskModel.Id, 2); err != nil {
+		t.Fatal(err)
+	}
+	if planSchedNextAt := getPlanSchedNextAt(); planSchedNextAt == task.SchedNextAtWaitingRunEnded {
+		t.Fatal("expect next sched planned")
+	}
+}
+
+func TestTimeoutTriggeredTasksDiscardSrvNotFound(t *testing.T) {
+	repo := newTestTaskRepo(t)
+	ctx := context.TODO()
+	conn := repo.mustGetConn(ctx)
+
+	taskModel := createTestTaskModel(t, repo, &TaskModel{
+		SchedMode: schedModeTimeSpec,
+		CallbackSrvId: uint64(time.Now().UnixNano()),
+	})
+	triggerModel := &TaskTriggerModel{
+		TaskId: taskModel.Id,
+		PlanAt: time.Now().Unix() - 1,
+		TriggerType: triggerTypeManual,
+	}
+	if err := conn.Create(triggerModel).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tasks, err := repo.TimeoutTriggeredTasks(ctx, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, oneTask := range tasks {
+		if oneTask.GetTrigger().GetId() == fmt.Sprintf("%d", triggerModel.Id) {
+			t.Fatal("expect trigger of task without callback srv not returned")
+		}
+	}
+
+	if err = conn.Where(DbFieldId + " = ?", triggerModel.Id).Take(triggerModel).Error; err != nil {
+		t.Fatal(err)
+	}
+	if triggerModel.ConsumedAt == 0 {
+		t.Fatal("expect trigger discarded")
+	}
+}
+
+func TestIsLastRunRunningIgnoreTriggeredRun(t *testing.T) {
+	repo := newTestTaskRepo(t)
+	ctx := context.TODO()
+
+	taskModel := createTestTaskModel(t, repo, &TaskModel{
+		SchedMode: schedModeTimeInterval,
+		TimeIntervalSec: 60,
+		RunTimes: 2,
+	})
+	logModels := []*TaskLogModel{
+		{TaskId: taskModel.Id, RunTimes: 1, TaskStatus: statusSuccess, TriggerType: triggerTypeSched},
+		{TaskId: taskModel.Id, RunTimes: 2, TaskStatus: statusRunning, TriggerType: triggerTypeManual},
+	}
+	if err := repo.mustGetConn(ctx).Create(&logModels).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// 手动触发的执行还在进行中,不影响调度计划的并发策略
+	isLastRunRunning, err := repo.isLastRunRunning(ctx, taskModel.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isLastRunRunning {
+		t.Error("expect manual run ignored")
+	}
+}
+
+func TestRetrySchedRunKeepPlan(t *testing.T) {
+	repo := newTestTaskRepo(t)
+	ctx := context.TODO()
+	conn := repo.mustGetConn(ctx)
+
+	planSchedNextAt := time.Now().Unix() + 60
+	taskModel := createTestTaskModel(t, repo, &TaskModel{
+		SchedMode: schedModeTimeInterval,
+		TimeIntervalSec: 60,
+		PlanSchedNextAt: planSchedNextAt,
+		RunTimes: 1,
+		RetryMaxAttempts: 3,
+		RetryInitialDelaySec: 10,
+	})
+
+	retried, err := repo.retryTask(ctx, taskModel.Id, 1, &TaskLogModel{TriggerType: triggerTypeSched})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !retried {
+		t.Fatal("expect retry scheduled")
+	}
+
+	// 重试加入触发队列,不能改动调度计划
+	var model TaskModel
+	if err = conn.Where(DbFieldId + " = ?", taskModel.Id).Take(&model).Error; err != nil {
+		t.Fatal(err)
+	}
+	if model.PlanSchedNextAt != planSchedNextAt {
+		t.Errorf("expect plan sched next at %d kept, got %d", planSchedNextAt, model.PlanSchedNextAt)
+	}
+
+	var triggerModel TaskTriggerModel
+	if err = conn.Where(DbFieldTaskId + " = ?", taskModel.Id).Take(&triggerModel).Error; err != nil {
+		t.Fatal(err)
+	}
+	if triggerModel.TriggerType != triggerTypeSched || triggerModel.Attempt != 1 {
+		t.Errorf("expect sched retry trigger attempt 1, got type %d attempt %d", triggerModel.TriggerType, triggerModel.Attempt)
+	}
+}
+
+func TestTimeoutTriggeredTasksSkipPausedTask(t *testing.T) {
+	repo := newTestTaskRepo(t)
+	ctx := context.TODO()
+	conn := repo.mustGetConn(ctx)
+
+	taskModel := createTestTaskModel(t, repo, &TaskModel{
+		SchedMode: schedModeTimeInterval,
+		TimeIntervalSec: 60,
+		CallbackSrvId: uint64(time.Now().UnixNano()),
+	})
+	if err := repo.PauseTask(ctx, taskModel.toEntityId()); err != nil {
+		t.Fatal(err)
+	}
+	triggerModel := &TaskTriggerModel{
+		TaskId: taskModel.Id,
+		PlanAt: time.Now().Unix() - 1,
+		TriggerType: triggerTypeManual,
+	}
+	if err := conn.Create(triggerModel).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tasks, err := repo.TimeoutTriggeredTasks(ctx, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, oneTask := range tasks {
+		if oneTask.GetTrigger().GetId() == fmt.Sprintf("%d", triggerModel.Id) {
+			t.Fatal("expect trigger of paused task not returned")
+		}
+	}
+
+	// 暂停期间的触发保留在队列中,恢复后执行
+	if err = conn.Where(DbFieldId + " = ?", triggerModel.Id).Take(triggerModel).Error; err != nil {
+		t.Fatal(err)
+	}
+	if triggerModel.ConsumedAt != 0 {
+		t.Fatal("expect trigger of paused task kept")
+	}
+}
+
+func TestResumeFixedDelayTaskKeepWaitingRunEnded(t *testing.T) {
+	repo := newTestTaskRepo(t)
+	ctx := context.TODO()
+	conn := repo.mustGetConn(ctx)
+
+	srvModel := &TaskCallbackSrvModel{Name: fmt.Sprintf("%s_%d", t.Name(), time.Now().UnixNano())}
+	if err := conn.Create(srvModel).Error; err != nil {
+		t.Fatal(err)
+	}
+	taskModel := createTestTaskModel(t, repo, &TaskModel{
+		SchedMode: schedModeFixedDelay,
+		TimeIntervalSec: 60,
+		PlanSchedNextAt: task.SchedNextAtWaitingRunEnded,
+		CallbackSrvId: srvModel.Id,
+		RunTimes: 1,
+		IsPaused: true,
+	})
+	logModel := &TaskLogModel{TaskId: taskModel.Id, RunTimes: 1, TaskStatus: statusRunning, TriggerType: triggerTypeSched}
+	if err := conn.Create(logModel).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.ResumeTask(ctx, taskModel.toEntityId()); err != nil {
+		t.Fatal(err)
+	}
+
+	var model TaskModel
+	if err := conn.Where(DbFieldId + " = ?", taskModel.Id).Take(&model).Error; err != nil {
+		t.Fatal(err)
+	}
+	if model.IsPaused || model.PlanSchedNextAt != task.SchedNextAtWaitingRunEnded {
+		t.Errorf("expect resumed and still waiting run ended, got paused %v next at %d", model.IsPaused, model.PlanSchedNextAt)
+	}
+}
+
+func TestLockRetryTriggerResolveConcurrency(t *testing.T) {
+	repo := newTestTaskRepo(t)
+	ctx := context.TODO()
+	conn := repo.mustGetConn(ctx)
+
+	for _, concurrencyPolicy := range []int{concurrencyPolicyForbid, concurrencyPolicyQueue} {
+		taskModel := createTestTaskModel(t, repo, &TaskModel{
+			SchedMode: schedModeTimeInterval,
+			TimeIntervalSec: 60,
+			ConcurrencyPolicy: concurrencyPolicy,
+			RunTimes: 2,
+			AllowMaxRunTimes: math.MaxInt,
+		})
+		logModel := &TaskLogModel{TaskId: taskModel.Id, RunTimes: 2, TaskStatus: statusRunning, TriggerType: triggerTypeSched}
+		if err := conn.Create(logModel).Error; err != nil {
+			t.Fatal(err)
+		}
+		triggerModel := &TaskTriggerModel{
+			TaskId: taskModel.Id,
+			PlanAt: time.Now().Unix() - 1,
+			TriggerType: triggerTypeSched,
+			Attempt: 1,
+		}
+		if err := conn.Create(triggerModel).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		oneTask, err := taskModel.toTriggeredEntity(task.NewTaskCallbackSrv("", "srv", nil, false, nil, "", "", nil, task.RouteStrategyNil, 0), triggerModel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		locked, err := repo.LockTask(ctx, oneTask)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if locked {
+			t.Errorf("concurrency policy %d: expect retry not locked while last run running", concurrencyPolicy)
+		}
+
+		// 禁止并发时丢弃本次重试,排队时保留触发等上次执行结束
+		if err = conn.Where(DbFieldId + " = ?", triggerModel.Id).Take(triggerModel).Error; err != nil {
+			t.Fatal(err)
+		}
+		if isConsumed := triggerModel.ConsumedAt > 0; isConsumed != (concurrencyPolicy == concurrencyPolicyForbid) {
+			t.Errorf("concurrency policy %d: unexpected trigger consumed %v", concurrencyPolicy, isConsumed)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package mysql
 import (
 	"context"
 	"github.com/995933447/easytask/internal/task"
+	"github.com/995933447/easytask/internal/util/crypt"
 	"github.com/995933447/easytask/internal/util/logger"
 	"github.com/995933447/easytask/pkg/errs"
 	"github.com/995933447/optionstream"
@@ -16,6 +17,8 @@ var migratedTaskSrvRepoDB atomic.Bool
 
 type TaskSrvRepo struct {
 	repoConnector
+	// 加解密服务的签名密钥和密钥header,为空时不能注册带签名密钥或者密钥header的服务
+	secretCipher *crypt.Cipher
 }
 
 func (r *TaskSrvRepo) AddSrvRoutes(ctx context.Context, srv *task.TaskCallbackSrv) error {
@@ -37,6 +40,91 @@ func (r *TaskSrvRepo) AddSrvRoutes(ctx context.Context, srv *task.TaskCallbackSr
 		}
 	}
 
+	// 限流配置以最后一次注册为准
+	if limit := srv.GetLimit(); limit != nil && (limit.GetMaxInFlight() != srvModel.MaxInFlight || limit.GetMaxPerSec() != srvModel.MaxPerSec) {
+		err := conn.Model(&TaskCallbackSrvModel{}).
+			Where(DbFieldId + " = ?", srvModel.Id).
+			Updates(map[string]interface{}{
+				DbFieldMaxInFlight: limit.GetMaxInFlight(),
+				DbFieldMaxPerSec: limit.GetMaxPerSec(),
+			}).
+			Error
+		if err != nil {
+			logger.MustGetRepoLogger().Error(ctx, err)
+			return err
+		}
+	}
+
+	srvUpdates := make(map[string]interface{})
+	if srv.GetStreamKey() != srvModel.StreamKey {
+		srvUpdates[DbFieldStreamKey] = srv.GetStreamKey()
+	}
+	if srv.GetStreamMaxLen() != srvModel.StreamMaxLen {
+		srvUpdates[DbFieldStreamMaxLen] = srv.GetStreamMaxLen()
+	}
+
+	routeStrategy, err := toTaskModelRouteStrategy(srv.GetRouteStrategy())
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return err
+	}
+	if routeStrategy != srvModel.RouteStrategy {
+		srvUpdates[DbFieldRouteStrategy] = routeStrategy
+	}
+
+	// 签名密钥只在注册时传了才更新,显式清除时才关闭签名
+	if srv.IsClearSecret() {
+		if srvModel.Secret != "" {
+			srvUpdates[DbFieldSecret] = ""
+		}
+	} else if srv.GetSecret() != "" {
+		storedSecret, err := decryptSecret(srvModel.Secret, r.secretCipher)
+		if err != nil || storedSecret != srv.GetSecret() {
+			secret, err := encryptSecret(srv.GetSecret(), r.secretCipher)
+			if err != nil {
+				logger.MustGetRepoLogger().Error(ctx, err)
+				return err
+			}
+			srvUpdates[DbFieldSecret] = secret
+		}
+	}
+
+	if len(srvUpdates) > 0 {
+		err := conn.Model(&TaskCallbackSrvModel{}).
+			Where(DbFieldId + " = ?", srvModel.Id).
+			Updates(srvUpdates).
+			Error
+		if err != nil {
+			logger.MustGetRepoLogger().Error(ctx, err)
+			return err
+		}
+	}
+
+	// header以最后一次注册为准,已存储的密钥header解密失败时直接用本次注册的覆盖,服务重新注册后即可恢复
+	storedSecretHeaders, err := decryptSecretHeaders(srvModel.SecretHeaders, r.secretCipher)
+	if err != nil {
+		logger.MustGetRepoLogger().Warnf(ctx, "decrypt secret headers of TaskCallbackSrv(id:%d) failed, overwrite them, err:%v", srvModel.Id, err)
+	}
+	headers := srv.GetHeaders()
+	if err != nil || !isSameHeaders(headers.GetHeaders(), srvModel.Headers) || !isSameHeaders(headers.GetSecretHeaders(), storedSecretHeaders) {
+		secretHeaders, err := encryptSecretHeaders(headers.GetSecretHeaders(), r.secretCipher)
+		if err != nil {
+			logger.MustGetRepoLogger().Error(ctx, err)
+			return err
+		}
+		err = conn.Model(&TaskCallbackSrvModel{}).
+			Where(DbFieldId + " = ?", srvModel.Id).
+			Updates(map[string]interface{}{
+				DbFieldHeaders: Headers(headers.GetHeaders()),
+				DbFieldSecretHeaders: secretHeaders,
+			}).
+			Error
+		if err != nil {
+			logger.MustGetRepoLogger().Error(ctx, err)
+			return err
+		}
+	}
+
 	var hasEnableHealthCheck bool
 	for _, route := range srv.GetRoutes() {
 		if route.IsEnableHeathCheck() && !hasEnableHealthCheck {
@@ -50,6 +138,8 @@ func (r *TaskSrvRepo) AddSrvRoutes(ctx context.Context, srv *task.TaskCallbackSr
 			SrvId: srvModel.Id,
 			CallbackTimeoutSec: route.GetCallbackTimeoutSec(),
 			EnableHealthCheck: route.IsEnableHeathCheck(),
+			Weight: route.GetWeight(),
+			Labels: route.GetLabels(),
 		}
 		res := conn.Unscoped().
 			Where(DbFieldSrvId + " = ?", srvModel.Id).
@@ -69,10 +159,14 @@ func (r *TaskSrvRepo) AddSrvRoutes(ctx context.Context, srv *task.TaskCallbackSr
 		updateMap := map[string]interface{}{
 			DbFieldDeletedAt:         0,
 			DbFieldEnableHealthCheck: route.IsEnableHeathCheck(),
+			DbFieldLabels: Labels(route.GetLabels()),
 		}
 		if route.GetCallbackTimeoutSec() != routeModel.CallbackTimeoutSec {
 			updateMap[DbFieldCallbackTimeoutSec] = route.GetCallbackTimeoutSec()
 		}
+		if route.GetWeight() != routeModel.Weight {
+			updateMap[DbFieldWeight] = route.GetWeight()
+		}
 		err := conn.Unscoped().Model(&TaskCallbackSrvRouteModel{}).
 			Where(DbFieldId + " = ?", routeModel.Id).
 			Updates(updateMap).
@@ -97,6 +191,18 @@ func (r *TaskSrvRepo) AddSrvRoutes(ctx context.Context, srv *task.TaskCallbackSr
 	return nil
 }
 
+func isSameHeaders(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, value := range a {
+		if bValue, ok := b[name]; !ok || bValue != value {
+			return false
+		}
+	}
+	return true
+}
+
 func(r *TaskSrvRepo) DelSrvRoutes(ctx context.Context, srv *task.TaskCallbackSrv) error {
 	var (
 		srvModel TaskCallbackSrvModel
@@ -299,17 +405,25 @@ func (r *TaskSrvRepo) GetSrvs(ctx context.Context, queryStream *optionstream.Que
 		for _, routeModel := range routeModels {
 			routes = append(routes, routeModel.toEntity())
 		}
-		srvs = append(srvs, srvModel.toEntity(routes))
+		// 单个服务的密钥解密失败(例如修改了secret_key)时只跳过该服务,不影响其他服务的任务调度
+		srv, err := srvModel.toEntity(routes, r.secretCipher)
+		if err != nil {
+			logger.MustGetRepoLogger().Errorf(ctx, "read TaskCallbackSrv(id:%d name:%s) failed, skip it, err:%v", srvModel.Id, srvModel.Name, err)
+			continue
+		}
+		srvs = append(srvs, srv)
 	}
 
 	return srvs, nil
 }
 
-func NewTaskSrvRepo(ctx context.Context, connDsn string) (*TaskSrvRepo, error) {
+// secretCipher用于加密服务的密钥header,没有配置时传nil
+func NewTaskSrvRepo(ctx context.Context, connDsn string, secretCipher *crypt.Cipher) (*TaskSrvRepo, error) {
 	repo := &TaskSrvRepo{
 		repoConnector: repoConnector{
 			connDsn: connDsn,
 		},
+		secretCipher: secretCipher,
 	}
 	if !migratedTaskSrvRepoDB.Load() {
 		if err := repo.mustGetConn(ctx).AutoMigrate(&TaskCallbackSrvModel{}, &TaskCallbackSrvRouteModel{}); err != nil {
@@ -0,0 +1,275 @@
+package mysql
+
+import (
+	"context"
+	"github.com/995933447/easytask/internal/task"
+	"github.com/995933447/easytask/internal/util/logger"
+	"github.com/995933447/easytask/pkg/errs"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"sync/atomic"
+	"time"
+)
+
+var migratedWorkflowRepoDB atomic.Bool
+
+type WorkflowRepo struct {
+	repoConnector
+}
+
+func (r *WorkflowRepo) SaveWorkflow(ctx context.Context, workflow *task.Workflow) (string, error) {
+	definition, err := toWorkflowSnapshot(workflow)
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return "", err
+	}
+
+	conn := r.mustGetConn(ctx)
+	workflowModel := &WorkflowModel{
+		Name: workflow.GetName(),
+		Definition: definition,
+	}
+	res := conn.Unscoped().
+		Where(DbFieldName + " = ?", workflowModel.Name).
+		FirstOrCreate(workflowModel)
+	if res.Error != nil {
+		logger.MustGetRepoLogger().Error(ctx, res.Error)
+		return "", res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		err = conn.Model(workflowModel).
+			Unscoped().
+			Where(DbFieldId + " = ?", workflowModel.Id).
+			Updates(map[string]interface{}{
+				DbFieldDefinition: definition,
+				DbFieldDeletedAt: 0,
+			}).
+			Error
+		if err != nil {
+			logger.MustGetRepoLogger().Error(ctx, err)
+			return "", err
+		}
+	}
+
+	return toWorkflowEntityId(workflowModel.Id), nil
+}
+
+func (r *WorkflowRepo) GetWorkflowById(ctx context.Context, id string) (*task.Workflow, error) {
+	workflowModel, err := r.getWorkflowModelById(ctx, id)
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	workflow, err := workflowModel.toEntity()
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	return workflow, nil
+}
+
+func (r *WorkflowRepo) getWorkflowModelById(ctx context.Context, id string) (*WorkflowModel, error) {
+	workflowModelId, err := toWorkflowModelId(id)
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	var workflowModel WorkflowModel
+	if err = r.mustGetConn(ctx).Where(DbFieldId + " = ?", workflowModelId).Take(&workflowModel).Error; err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		if err == gorm.ErrRecordNotFound {
+			return nil, errs.NewBizErr(errs.ErrCodeWorkflowNotFound)
+		}
+		return nil, err
+	}
+
+	return &workflowModel, nil
+}
+
+func (r *WorkflowRepo) StartWorkflow(ctx context.Context, workflowId string) (string, error) {
+	workflowModel, err := r.getWorkflowModelById(ctx, workflowId)
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return "", err
+	}
+
+	workflow, err := workflowModel.toEntity()
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return "", err
+	}
+
+	runModel := &WorkflowRunModel{
+		WorkflowId: workflowModel.Id,
+		WorkflowName: workflowModel.Name,
+		Definition: workflowModel.Definition,
+		Status: statusRunning,
+		NodeRuns: WorkflowNodeRunsSnapshot{},
+		StartedAt: time.Now().Unix(),
+	}
+	err = r.mustGetConn(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(runModel).Error; err != nil {
+			return err
+		}
+		return advanceWorkflowRun(tx, runModel, workflow)
+	})
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return "", err
+	}
+
+	return toWorkflowRunEntityId(runModel.Id), nil
+}
+
+func (r *WorkflowRepo) GetWorkflowRunById(ctx context.Context, id string) (*task.WorkflowRun, error) {
+	runModelId, err := toWorkflowRunModelId(id)
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	var runModel WorkflowRunModel
+	if err = r.mustGetConn(ctx).Where(DbFieldId + " = ?", runModelId).Take(&runModel).Error; err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		if err == gorm.ErrRecordNotFound {
+			return nil, errs.NewBizErr(errs.ErrCodeWorkflowRunNotFound)
+		}
+		return nil, err
+	}
+
+	run, err := runModel.toEntity()
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return nil, err
+	}
+
+	return run, nil
+}
+
+func (r *WorkflowRepo) ConfirmWorkflowNode(ctx context.Context, workflowRunId, nodeName string, status task.Status, taskRunTimes int) error {
+	runModelId, err := toWorkflowRunModelId(workflowRunId)
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return err
+	}
+
+	err = r.mustGetConn(ctx).Transaction(func(tx *gorm.DB) error {
+		var runModel WorkflowRunModel
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where(DbFieldId + " = ?", runModelId).
+			Take(&runModel).
+			Error
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return errs.NewBizErr(errs.ErrCodeWorkflowRunNotFound)
+			}
+			return err
+		}
+
+		// 重复确认或工作流已经结束
+		nodeRun, ok := runModel.NodeRuns[nodeName]
+		if runModel.Status != statusRunning || !ok || nodeRun.Status != statusRunning {
+			logger.MustGetRepoLogger().Warnf(ctx, "workflow run(id:%d) node(name:%s) is not running, ignore confirm", runModelId, nodeName)
+			return nil
+		}
+
+		nodeRun.Status = toModelStatus(status)
+		nodeRun.TaskRunTimes = taskRunTimes
+
+		workflow, err := runModel.Definition.toEntity(runModel.WorkflowId, runModel.WorkflowName)
+		if err != nil {
+			return err
+		}
+
+		return advanceWorkflowRun(tx, &runModel, workflow)
+	})
+	if err != nil {
+		logger.MustGetRepoLogger().Error(ctx, err)
+		return err
+	}
+
+	return nil
+}
+
+// 触发所有可以执行的节点,标记被跳过的节点,全部节点结束后结束工作流
+func advanceWorkflowRun(tx *gorm.DB, runModel *WorkflowRunModel, workflow *task.Workflow) error {
+	statuses := make(map[string]task.Status)
+	for _, node := range workflow.GetNodes() {
+		statuses[node.GetName()] = task.StatusNil
+	}
+	for name, status := range runModel.NodeRuns.toEntityStatuses() {
+		statuses[name] = status
+	}
+
+	readyNodes, skippedNodes, err := workflow.Advance(statuses)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	var triggerModels []*TaskTriggerModel
+	for _, node := range readyNodes {
+		taskModelId, err := toTaskModelId(node.GetTaskId())
+		if err != nil {
+			return err
+		}
+		runModel.NodeRuns[node.GetName()] = &WorkflowNodeRunSnapshot{Status: statusRunning}
+		statuses[node.GetName()] = task.StatusRunning
+		triggerModels = append(triggerModels, &TaskTriggerModel{
+			TaskId: taskModelId,
+			PlanAt: now,
+			TriggerType: triggerTypeWorkflow,
+			WorkflowRunId: runModel.Id,
+			WorkflowNode: node.GetName(),
+		})
+	}
+	for _, node := range skippedNodes {
+		runModel.NodeRuns[node.GetName()] = &WorkflowNodeRunSnapshot{Status: statusSkipped}
+		statuses[node.GetName()] = task.StatusSkipped
+	}
+
+	if len(triggerModels) > 0 {
+		if err = tx.Create(&triggerModels).Error; err != nil {
+			return err
+		}
+	}
+
+	runStatus := workflow.GetRunStatus(statuses)
+	runModel.Status = toModelStatus(runStatus)
+	updates := map[string]interface{}{
+		DbFieldStatus: runModel.Status,
+		DbFieldNodeRuns: runModel.NodeRuns,
+	}
+	if !task.IsTaskRunning(runStatus) {
+		runModel.EndedAt = now
+		updates[DbFieldEndedAt] = now
+	}
+
+	return tx.Model(&WorkflowRunModel{}).
+		Where(DbFieldId + " = ?", runModel.Id).
+		Updates(updates).
+		Error
+}
+
+func NewWorkflowRepo(ctx context.Context, connDsn string) (task.WorkflowRepo, error) {
+	repo := &WorkflowRepo{
+		repoConnector: repoConnector{
+			connDsn: connDsn,
+		},
+	}
+
+	if !migratedWorkflowRepoDB.Load() {
+		if err := repo.mustGetConn(ctx).AutoMigrate(&WorkflowModel{}, &WorkflowRunModel{}); err != nil {
+			logger.MustGetRepoLogger().Error(ctx, err)
+			return nil, err
+		}
+	}
+
+	return repo, nil
+}
+
+var _ task.WorkflowRepo = (*WorkflowRepo)(nil)
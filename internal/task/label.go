@@ -0,0 +1,25 @@
+package task
+
+// 选择器为空时匹配所有路由,否则路由需要包含选择器中所有的标签且值相同
+func (r *TaskCallbackSrvRoute) MatchLabelSelector(labelSelector map[string]string) bool {
+	for key, val := range labelSelector {
+		if routeVal, ok := r.labels[key]; !ok || routeVal != val {
+			return false
+		}
+	}
+	return true
+}
+
+// 回调服务中匹配任务标签选择器的路由
+func (t *Task) GetCallbackRoutes() []*TaskCallbackSrvRoute {
+	if len(t.labelSelector) == 0 {
+		return t.callbackSrv.GetRoutes()
+	}
+	var routes []*TaskCallbackSrvRoute
+	for _, route := range t.callbackSrv.GetRoutes() {
+		if route.MatchLabelSelector(t.labelSelector) {
+			routes = append(routes, route)
+		}
+	}
+	return routes
+}
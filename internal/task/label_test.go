@@ -0,0 +1,34 @@
+package task
+
+import "testing"
+
+func TestGetCallbackRoutes(t *testing.T) {
+	stable := NewTaskCallbackSrvRoute("1", "http", "127.0.0.1", 8000, 0, false, 0, map[string]string{"version": "stable", "zone": "a"})
+	canary := NewTaskCallbackSrvRoute("2", "http", "127.0.0.1", 8001, 0, false, 0, map[string]string{"version": "canary", "zone": "a"})
+	noLabel := NewTaskCallbackSrvRoute("3", "http", "127.0.0.1", 8002, 0, false, 0, nil)
+	srv := NewTaskCallbackSrv("1", "srv", []*TaskCallbackSrvRoute{stable, canary, noLabel}, false, nil, "", "", nil, RouteStrategyRandom, 0)
+
+	cases := []struct {
+		labelSelector map[string]string
+		expectIds []string
+	}{
+		{nil, []string{"1", "2", "3"}},
+		{map[string]string{"version": "canary"}, []string{"2"}},
+		{map[string]string{"zone": "a"}, []string{"1", "2"}},
+		{map[string]string{"zone": "a", "version": "stable"}, []string{"1"}},
+		{map[string]string{"zone": "b"}, nil},
+	}
+	for i, c := range cases {
+		oneTask := &Task{callbackSrv: srv, labelSelector: c.labelSelector}
+		routes := oneTask.GetCallbackRoutes()
+		if len(routes) != len(c.expectIds) {
+			t.Errorf("case %d: expect %d routes, got %d", i, len(c.expectIds), len(routes))
+			continue
+		}
+		for j, route := range routes {
+			if route.GetId() != c.expectIds[j] {
+				t.Errorf("case %d: expect route %s, got %s", i, c.expectIds[j], route.GetId())
+			}
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package task
+
+import (
+	"sync"
+	"time"
+)
+
+// 回调服务的限流配置,0代表不限制
+type CallbackSrvLimit struct {
+	maxInFlight int
+	maxPerSec int
+}
+
+// 同时进行中的回调数上限
+func (l *CallbackSrvLimit) GetMaxInFlight() int {
+	return l.maxInFlight
+}
+
+// 每秒发起的回调数上限
+func (l *CallbackSrvLimit) GetMaxPerSec() int {
+	return l.maxPerSec
+}
+
+func NewCallbackSrvLimit(maxInFlight, maxPerSec int) *CallbackSrvLimit {
+	return &CallbackSrvLimit{
+		maxInFlight: maxInFlight,
+		maxPerSec: maxPerSec,
+	}
+}
+
+type callbackSrvLimitState struct {
+	inFlight int
+	windowSec int64
+	windowCount int
+}
+
+// 按回调服务限制进行中的回调数和每秒回调数.只有主节点调度任务,所以在主节点内存中限制即对整个集群生效
+type CallbackSrvLimiter struct {
+	mu sync.Mutex
+	states map[string]*callbackSrvLimitState
+	now func() time.Time
+}
+
+func NewCallbackSrvLimiter() *CallbackSrvLimiter {
+	return &CallbackSrvLimiter{
+		states: map[string]*callbackSrvLimitState{},
+		now: time.Now,
+	}
+}
+
+// 没有超过限制时占用一个回调名额,返回false代表本次不能回调,需要延后执行
+func (l *CallbackSrvLimiter) TryAcquire(srv *TaskCallbackSrv) bool {
+	limit := srv.GetLimit()
+	if limit == nil || (limit.maxInFlight <= 0 && limit.maxPerSec <= 0) {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	state, ok := l.states[srv.GetId()]
+	if !ok {
+		state = &callbackSrvLimitState{}
+		l.states[srv.GetId()] = state
+	}
+
+	if limit.maxInFlight > 0 && state.inFlight >= limit.maxInFlight {
+		return false
+	}
+
+	if limit.maxPerSec > 0 {
+		nowSec := l.now().Unix()
+		if state.windowSec != nowSec {
+			state.windowSec = nowSec
+			state.windowCount = 0
+		}
+		if state.windowCount >= limit.maxPerSec {
+			return false
+		}
+		state.windowCount++
+	}
+
+	state.inFlight++
+
+	return true
+}
+
+// 回调结束后释放TryAcquire占用的名额,异步执行的回调在确认或超时后才释放
+func (l *CallbackSrvLimiter) Release(srv *TaskCallbackSrv) {
+	l.releaseSrv(srv.GetId())
+}
+
+func (l *CallbackSrvLimiter) releaseSrv(srvId string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.release(srvId)
+}
+
+// 成为主节点时恢复之前的主节点发起的还没结束的回调占用的进行中名额,不检查限制
+func (l *CallbackSrvLimiter) restore(srvId string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	state, ok := l.states[srvId]
+	if !ok {
+		state = &callbackSrvLimitState{}
+		l.states[srvId] = state
+	}
+	state.inFlight++
+}
+
+// 占用名额后没有发起回调(锁定任务失败或跳过本次触发),退还进行中的名额和当前这一秒的名额
+func (l *CallbackSrvLimiter) Cancel(srv *TaskCallbackSrv) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	state, ok := l.states[srv.GetId()]
+	if !ok {
+		return
+	}
+
+	l.release(srv.GetId())
+
+	limit := srv.GetLimit()
+	if limit != nil && limit.maxPerSec > 0 && state.windowSec == l.now().Unix() && state.windowCount > 0 {
+		state.windowCount--
+	}
+}
+
+func (l *CallbackSrvLimiter) release(srvId string) {
+	state, ok := l.states[srvId]
+	if !ok || state.inFlight <= 0 {
+		return
+	}
+	state.inFlight--
+}
@@ -0,0 +1,110 @@
+package task
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCallbackSrvLimiterMaxInFlight(t *testing.T) {
+	srv := NewTaskCallbackSrv("1", "srv", nil, false, NewCallbackSrvLimit(2, 0), "", "", nil, RouteStrategyRandom, 0)
+	limiter := NewCallbackSrvLimiter()
+	if !limiter.TryAcquire(srv) || !limiter.TryAcquire(srv) {
+		t.Fatal("expect two callbacks allowed")
+	}
+	if limiter.TryAcquire(srv) {
+		t.Fatal("expect third in flight callback deferred")
+	}
+	limiter.Release(srv)
+	if !limiter.TryAcquire(srv) {
+		t.Error("expect callback allowed after release")
+	}
+}
+
+func TestCallbackSrvLimiterMaxPerSec(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	srv := NewTaskCallbackSrv("1", "srv", nil, false, NewCallbackSrvLimit(0, 3), "", "", nil, RouteStrategyRandom, 0)
+	limiter := NewCallbackSrvLimiter()
+	limiter.now = func() time.Time {
+		return now
+	}
+	for i := 0; i < 3; i++ {
+		if !limiter.TryAcquire(srv) {
+			t.Fatalf("expect callback %d allowed", i)
+		}
+		limiter.Release(srv)
+	}
+	if limiter.TryAcquire(srv) {
+		t.Fatal("expect fourth callback in the same second deferred")
+	}
+	now = now.Add(time.Second)
+	if !limiter.TryAcquire(srv) {
+		t.Error("expect callback allowed in next second")
+	}
+
+	unlimited := NewTaskCallbackSrv("2", "srv2", nil, false, nil, "", "", nil, RouteStrategyRandom, 0)
+	for i := 0; i < 10; i++ {
+		if !limiter.TryAcquire(unlimited) {
+			t.Fatal("expect unlimited server never deferred")
+		}
+	}
+}
+
+func TestCallbackSrvLimiterCancel(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	srv := NewTaskCallbackSrv("1", "srv", nil, false, NewCallbackSrvLimit(1, 1), "", "", nil, RouteStrategyRandom, 0)
+	limiter := NewCallbackSrvLimiter()
+	limiter.now = func() time.Time {
+		return now
+	}
+	if !limiter.TryAcquire(srv) {
+		t.Fatal("expect callback allowed")
+	}
+	// 锁定失败或跳过时没有发起回调,进行中和这一秒的名额都要退还
+	limiter.Cancel(srv)
+	if !limiter.TryAcquire(srv) {
+		t.Error("expect callback allowed after cancel")
+	}
+}
+
+func TestSchedDeferredFireNotMisfired(t *testing.T) {
+	now := time.Now()
+	sched := NewSched(nil, nil, 0, 0)
+	oneTask := &Task{
+		id: "1",
+		schedMode: SchedModeTimeInterval,
+		timeIntervalSec: 60,
+		planSchedNextAt: now.Unix() - 1,
+		misfirePolicy: MisfirePolicySkip,
+	}
+	sched.deferFire(oneTask)
+
+	// 限流导致延后了30秒,按第一次延后时只落后1秒判断,不算错过触发
+	later := now.Add(30 * time.Second)
+	decision, err := oneTask.DecideSched(later, sched.misfireToleranceSec + sched.getFireDeferredSec(oneTask, later))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decision.IsSkipped() || decision.GetMisfireDecision() != MisfireDecisionNil {
+		t.Errorf("expect deferred fire not misfired, got decision %d", decision.GetMisfireDecision())
+	}
+
+	sched.clearDeferredFire(oneTask)
+	if sec := sched.getFireDeferredSec(oneTask, later); sec != 0 {
+		t.Errorf("expect no deferred sec after cleared, got %d", sec)
+	}
+}
+
+func TestCallbackSrvLimiterRestore(t *testing.T) {
+	srv := NewTaskCallbackSrv("1", "srv", nil, false, NewCallbackSrvLimit(2, 0), "", "", nil, RouteStrategyNil, 0)
+	limiter := NewCallbackSrvLimiter()
+	// 之前的主节点发起的两个执行还没结束
+	limiter.restore(srv.GetId())
+	limiter.restore(srv.GetId())
+	if limiter.TryAcquire(srv) {
+		t.Fatal("expect callback deferred while restored runs in flight")
+	}
+	limiter.releaseSrv(srv.GetId())
+	if !limiter.TryAcquire(srv) {
+		t.Error("expect callback allowed after restored run ended")
+	}
+}
@@ -0,0 +1,224 @@
+package task
+
+import (
+	"errors"
+	"github.com/gorhill/cronexpr"
+	"time"
+)
+
+type MisfirePolicy int
+
+const (
+	// 立即补触发一次,然后按正常节奏调度(默认)
+	MisfirePolicyFireOnce MisfirePolicy = iota
+	// 逐个补触发错过的每一次,超过上限时丢弃最早的
+	MisfirePolicyFireAll
+	// 不补触发,直接跳到下一次
+	MisfirePolicySkip
+	// 错过时间超过阈值则跳过,否则补触发一次
+	MisfirePolicySkipIfExpired
+)
+
+type MisfireDecision int
+
+const (
+	// 没有错过触发,正常执行
+	MisfireDecisionNil MisfireDecision = iota
+	MisfireDecisionFireOnce
+	MisfireDecisionCatchUp
+	MisfireDecisionSkip
+)
+
+const (
+	// 计划时间落后不超过该秒数的视为正常调度延迟,不算错过触发.没有配置时使用
+	DefaultMisfireToleranceSec = 5
+	// 统计错过次数的上限,避免高频cron长时间宕机后逐个遍历
+	maxCountMisfiredTimes = 10000
+)
+
+func (p MisfirePolicy) check() error {
+	switch p {
+	case MisfirePolicyFireOnce, MisfirePolicyFireAll, MisfirePolicySkip, MisfirePolicySkipIfExpired:
+		return nil
+	}
+	return errors.New("invalid misfire policy")
+}
+
+// 调度器在锁定任务前对本次触发做出的决策
+type SchedDecision struct {
+	schedNextAt int64
+	misfiredTimes int
+	misfireDecision MisfireDecision
+	skipReason SkipReason
+	isWaitingRunEnded bool
+	isQueued bool
+}
+
+// 锁定后写入plan_sched_next_at的时间
+func (d *SchedDecision) GetSchedNextAt() int64 {
+	// 跳过的触发不会有执行结果,固定延时任务直接按间隔安排下次执行
+	if d.isWaitingRunEnded && !d.IsSkipped() {
+		return SchedNextAtWaitingRunEnded
+	}
+	return d.schedNextAt
+}
+
+// 做决策时已经错过的触发次数(包含本次),最多统计到maxCountMisfiredTimes
+func (d *SchedDecision) GetMisfiredTimes() int {
+	return d.misfiredTimes
+}
+
+func (d *SchedDecision) GetMisfireDecision() MisfireDecision {
+	return d.misfireDecision
+}
+
+func (d *SchedDecision) GetSkipReason() SkipReason {
+	if d == nil {
+		return SkipReasonNil
+	}
+	return d.skipReason
+}
+
+// 按并发策略排队等上次执行结束,本次没有锁定任务
+func (d *SchedDecision) IsQueued() bool {
+	if d == nil {
+		return false
+	}
+	return d.isQueued
+}
+
+// 跳过的触发同样会消耗一次执行次数并记录任务日志,但不会回调
+func (d *SchedDecision) IsSkipped() bool {
+	if d == nil {
+		return false
+	}
+	return d.skipReason != SkipReasonNil
+}
+
+// 计划时间落后超过misfireToleranceSec秒才按错过触发处理
+func (t *Task) DecideSched(now time.Time, misfireToleranceSec int) (*SchedDecision, error) {
+	decision, err := t.decideSched(now, misfireToleranceSec)
+	if err != nil {
+		return nil, err
+	}
+	t.schedDecision = decision
+	return decision, nil
+}
+
+func (t *Task) decideSched(now time.Time, misfireToleranceSec int) (*SchedDecision, error) {
+	schedNextAt, err := t.getSchedNextAt(now)
+	if err != nil {
+		return nil, err
+	}
+
+	decision := &SchedDecision{
+		schedNextAt: schedNextAt,
+		isWaitingRunEnded: t.schedMode == SchedModeFixedDelay,
+	}
+
+	// 失败重试的触发时间由重试策略决定,不按错过处理
+	if t.retryAttempt > 0 || t.planSchedNextAt <= 0 || now.Unix() - t.planSchedNextAt <= int64(misfireToleranceSec) {
+		return decision, nil
+	}
+
+	var maxCatchUpTimes int
+	if t.misfirePolicy == MisfirePolicyFireAll {
+		maxCatchUpTimes = t.misfireMaxCatchUpTimes
+	}
+	catchUpNextAt, misfiredTimes, err := t.getMisfires(now, maxCatchUpTimes)
+	if err != nil {
+		return nil, err
+	}
+	decision.misfiredTimes = misfiredTimes
+
+	// 一次性任务只有一个计划时间点,跳过就永远不会执行;固定延时任务同样只错过了一次.都只补触发一次
+	if t.schedMode != SchedModeTimeInterval && t.schedMode != SchedModeTimeCron {
+		decision.misfireDecision = MisfireDecisionFireOnce
+		return decision, nil
+	}
+
+	switch t.misfirePolicy {
+	case MisfirePolicyFireAll:
+		decision.misfireDecision = MisfireDecisionCatchUp
+		// 下次计划时间设为下一个错过的时间点,调度器会马上再捞起来补触发
+		if catchUpNextAt > 0 {
+			decision.schedNextAt = catchUpNextAt
+		}
+	case MisfirePolicySkip:
+		decision.misfireDecision = MisfireDecisionSkip
+	case MisfirePolicySkipIfExpired:
+		if now.Unix() - t.planSchedNextAt > int64(t.misfireThresholdSec) {
+			decision.misfireDecision = MisfireDecisionSkip
+		} else {
+			decision.misfireDecision = MisfireDecisionFireOnce
+		}
+	default:
+		decision.misfireDecision = MisfireDecisionFireOnce
+	}
+
+	if decision.misfireDecision == MisfireDecisionSkip {
+		decision.skipReason = SkipReasonMisfire
+	}
+
+	return decision, nil
+}
+
+// 返回从planSchedNextAt到now之间错过的总次数,以及补触发时本次之后的下一个错过的时间点(没有则为0).
+// maxCatchUpTimes>0时只补最近的maxCatchUpTimes次,更早的丢弃
+func (t *Task) getMisfires(now time.Time, maxCatchUpTimes int) (int64, int, error) {
+	planAt := t.planSchedNextAt
+	switch t.schedMode {
+	case SchedModeTimeInterval:
+		if t.timeIntervalSec <= 0 {
+			return 0, 1, nil
+		}
+		interval := int64(t.timeIntervalSec)
+		total := (now.Unix() - planAt) / interval + 1
+		misfiredTimes := int(total)
+		if total > maxCountMisfiredTimes {
+			misfiredTimes = maxCountMisfiredTimes
+		}
+		var first int64
+		if maxCatchUpTimes > 0 && total > int64(maxCatchUpTimes) {
+			first = total - int64(maxCatchUpTimes)
+		}
+		if first + 1 >= total {
+			return 0, misfiredTimes, nil
+		}
+		return planAt + (first + 1) * interval, misfiredTimes, nil
+	case SchedModeTimeCron:
+		expr, err := cronexpr.Parse(t.timeCronExpr)
+		if err != nil {
+			return 0, 0, err
+		}
+		loc, err := loadLocation(t.timeZone)
+		if err != nil {
+			return 0, 0, err
+		}
+		// 不限制补触发次数时只需要前两个时间点,限制时保留最近的maxCatchUpTimes个
+		isHourFixed := isCronHourFixed(t.timeCronExpr)
+		missedAts := []int64{planAt}
+		total := 1
+		for at := time.Unix(planAt, 0); total < maxCountMisfiredTimes; total++ {
+			at = nextCronAt(expr, isHourFixed, at, loc)
+			if at.IsZero() || at.After(now) {
+				break
+			}
+			if maxCatchUpTimes <= 0 {
+				if len(missedAts) < 2 {
+					missedAts = append(missedAts, at.Unix())
+				}
+				continue
+			}
+			missedAts = append(missedAts, at.Unix())
+			if len(missedAts) > maxCatchUpTimes {
+				missedAts = missedAts[1:]
+			}
+		}
+		if len(missedAts) < 2 {
+			return 0, total, nil
+		}
+		return missedAts[1], total, nil
+	}
+	return 0, 1, nil
+}
@@ -0,0 +1,244 @@
+package task
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDecideSchedWithinTolerance(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	oneTask := &Task{
+		schedMode: SchedModeTimeInterval,
+		timeIntervalSec: 60,
+		planSchedNextAt: now.Unix() - DefaultMisfireToleranceSec,
+		misfirePolicy: MisfirePolicySkip,
+	}
+	decision, err := oneTask.DecideSched(now, DefaultMisfireToleranceSec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decision.GetMisfireDecision() != MisfireDecisionNil || decision.IsSkipped() {
+		t.Errorf("expect no misfire, got decision %d", decision.GetMisfireDecision())
+	}
+	if decision.GetSchedNextAt() != now.Unix() + 60 {
+		t.Errorf("expect next at %d, got %d", now.Unix() + 60, decision.GetSchedNextAt())
+	}
+}
+
+func TestDecideSchedFireOnce(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	oneTask := &Task{
+		schedMode: SchedModeTimeInterval,
+		timeIntervalSec: 60,
+		planSchedNextAt: now.Unix() - 300,
+	}
+	decision, err := oneTask.DecideSched(now, DefaultMisfireToleranceSec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decision.GetMisfireDecision() != MisfireDecisionFireOnce || decision.GetMisfiredTimes() != 6 {
+		t.Errorf("expect fire once after 6 misfires, got decision %d times %d", decision.GetMisfireDecision(), decision.GetMisfiredTimes())
+	}
+	if decision.GetSchedNextAt() != now.Unix() + 60 {
+		t.Errorf("expect next at %d, got %d", now.Unix() + 60, decision.GetSchedNextAt())
+	}
+}
+
+func TestDecideSchedCatchUp(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	planAt := now.Unix() - 300
+	oneTask := &Task{
+		schedMode: SchedModeTimeInterval,
+		timeIntervalSec: 60,
+		planSchedNextAt: planAt,
+		misfirePolicy: MisfirePolicyFireAll,
+	}
+	decision, err := oneTask.DecideSched(now, DefaultMisfireToleranceSec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decision.GetMisfireDecision() != MisfireDecisionCatchUp || decision.GetSchedNextAt() != planAt + 60 {
+		t.Errorf("expect catch up next at %d, got decision %d next at %d", planAt + 60, decision.GetMisfireDecision(), decision.GetSchedNextAt())
+	}
+
+	// 错过6次只补最近的2次,本次代表倒数第二次,下次是最后一次
+	oneTask.misfireMaxCatchUpTimes = 2
+	if decision, err = oneTask.DecideSched(now, DefaultMisfireToleranceSec); err != nil {
+		t.Fatal(err)
+	}
+	if decision.GetSchedNextAt() != planAt + 300 {
+		t.Errorf("expect catch up next at %d, got %d", planAt + 300, decision.GetSchedNextAt())
+	}
+
+	oneTask.misfireMaxCatchUpTimes = 1
+	if decision, err = oneTask.DecideSched(now, DefaultMisfireToleranceSec); err != nil {
+		t.Fatal(err)
+	}
+	if decision.GetSchedNextAt() != now.Unix() + 60 {
+		t.Errorf("expect resume normal schedule at %d, got %d", now.Unix() + 60, decision.GetSchedNextAt())
+	}
+}
+
+func TestDecideSchedCronCatchUp(t *testing.T) {
+	loc := mustLoadLocation(t, "Asia/Tokyo")
+	now := time.Date(2023, 6, 1, 12, 0, 30, 0, loc)
+	oneTask := &Task{
+		schedMode: SchedModeTimeCron,
+		timeCronExpr: "0 * * * *",
+		timeZone: "Asia/Tokyo",
+		planSchedNextAt: time.Date(2023, 6, 1, 8, 0, 0, 0, loc).Unix(),
+		misfirePolicy: MisfirePolicyFireAll,
+		misfireMaxCatchUpTimes: 3,
+	}
+	decision, err := oneTask.DecideSched(now, DefaultMisfireToleranceSec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decision.GetMisfiredTimes() != 5 {
+		t.Errorf("expect 5 misfires, got %d", decision.GetMisfiredTimes())
+	}
+	if expect := time.Date(2023, 6, 1, 11, 0, 0, 0, loc).Unix(); decision.GetSchedNextAt() != expect {
+		t.Errorf("expect catch up next at %d, got %d", expect, decision.GetSchedNextAt())
+	}
+}
+
+func TestDecideSchedSkip(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	oneTask := &Task{
+		schedMode: SchedModeTimeInterval,
+		timeIntervalSec: 60,
+		planSchedNextAt: now.Unix() - 120,
+		misfirePolicy: MisfirePolicySkipIfExpired,
+		misfireThresholdSec: 300,
+	}
+	decision, err := oneTask.DecideSched(now, DefaultMisfireToleranceSec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decision.IsSkipped() || decision.GetMisfireDecision() != MisfireDecisionFireOnce {
+		t.Errorf("expect fire once within threshold, got decision %d", decision.GetMisfireDecision())
+	}
+
+	oneTask.misfireThresholdSec = 60
+	if decision, err = oneTask.DecideSched(now, DefaultMisfireToleranceSec); err != nil {
+		t.Fatal(err)
+	}
+	if !decision.IsSkipped() {
+		t.Errorf("expect skip beyond threshold, got decision %d", decision.GetMisfireDecision())
+	}
+
+	oneTask.misfirePolicy = MisfirePolicySkip
+	oneTask.retryAttempt = 1
+	if decision, err = oneTask.DecideSched(now, DefaultMisfireToleranceSec); err != nil {
+		t.Fatal(err)
+	}
+	if decision.IsSkipped() {
+		t.Error("retry should not be treated as misfire")
+	}
+}
+
+func TestDecideSchedFixedDelay(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	oneTask := &Task{
+		schedMode: SchedModeFixedDelay,
+		timeIntervalSec: 60,
+		planSchedNextAt: now.Unix(),
+	}
+	decision, err := oneTask.DecideSched(now, DefaultMisfireToleranceSec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decision.GetSchedNextAt() != SchedNextAtWaitingRunEnded {
+		t.Errorf("expect waiting run ended, got %d", decision.GetSchedNextAt())
+	}
+
+	// 固定延时任务错过时不跳过,补触发一次后等执行结束
+	oneTask.planSchedNextAt = now.Unix() - 600
+	oneTask.misfirePolicy = MisfirePolicySkip
+	if decision, err = oneTask.DecideSched(now, DefaultMisfireToleranceSec); err != nil {
+		t.Fatal(err)
+	}
+	if decision.IsSkipped() || decision.GetMisfireDecision() != MisfireDecisionFireOnce {
+		t.Errorf("expect fire once, got decision %d", decision.GetMisfireDecision())
+	}
+	if decision.GetSchedNextAt() != SchedNextAtWaitingRunEnded {
+		t.Errorf("expect waiting run ended, got %d", decision.GetSchedNextAt())
+	}
+}
+
+func TestDecideSchedTimeSpecNeverSkipped(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	oneTask := &Task{
+		schedMode: SchedModeTimeSpec,
+		timeSpecAt: now.Unix() - 600,
+		planSchedNextAt: now.Unix() - 600,
+		misfirePolicy: MisfirePolicySkipIfExpired,
+		misfireThresholdSec: 60,
+	}
+	decision, err := oneTask.DecideSched(now, DefaultMisfireToleranceSec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decision.IsSkipped() || decision.GetMisfireDecision() != MisfireDecisionFireOnce {
+		t.Errorf("expect one-off task fire once, got decision %d", decision.GetMisfireDecision())
+	}
+}
+
+func TestDecideSchedMisfireTolerance(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	oneTask := &Task{
+		schedMode: SchedModeTimeInterval,
+		timeIntervalSec: 60,
+		planSchedNextAt: now.Unix() - 30,
+		misfirePolicy: MisfirePolicySkip,
+	}
+	decision, err := oneTask.DecideSched(now, DefaultMisfireToleranceSec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decision.IsSkipped() {
+		t.Error("expect skip beyond default tolerance")
+	}
+
+	if decision, err = oneTask.DecideSched(now, 30); err != nil {
+		t.Fatal(err)
+	}
+	if decision.IsSkipped() || decision.GetMisfireDecision() != MisfireDecisionNil {
+		t.Errorf("expect no misfire within configured tolerance, got decision %d", decision.GetMisfireDecision())
+	}
+}
+
+func TestResolveConcurrency(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	oneTask := &Task{
+		schedMode: SchedModeFixedDelay,
+		timeIntervalSec: 60,
+		planSchedNextAt: now.Unix(),
+		concurrencyPolicy: ConcurrencyPolicyForbid,
+	}
+	if _, err := oneTask.DecideSched(now, DefaultMisfireToleranceSec); err != nil {
+		t.Fatal(err)
+	}
+	if !oneTask.ResolveConcurrency(false) || oneTask.GetSchedDecision().IsSkipped() {
+		t.Error("expect run when last run ended")
+	}
+	if !oneTask.ResolveConcurrency(true) || oneTask.GetSchedDecision().GetSkipReason() != SkipReasonConcurrencyForbidden {
+		t.Error("expect skip when last run is running")
+	}
+	if next := oneTask.GetSchedDecision().GetSchedNextAt(); next != now.Unix() + 60 {
+		t.Errorf("expect next at %d, got %d", now.Unix() + 60, next)
+	}
+
+	oneTask.concurrencyPolicy = ConcurrencyPolicyQueue
+	if _, err := oneTask.DecideSched(now, DefaultMisfireToleranceSec); err != nil {
+		t.Fatal(err)
+	}
+	if oneTask.ResolveConcurrency(true) || !oneTask.GetSchedDecision().IsQueued() || oneTask.GetSchedDecision().IsSkipped() {
+		t.Error("expect queue when last run is running")
+	}
+
+	oneTask.concurrencyPolicy = ConcurrencyPolicyAllow
+	if !oneTask.ResolveConcurrency(true) || oneTask.GetSchedDecision().IsSkipped() {
+		t.Error("expect run when concurrency allowed")
+	}
+}
@@ -0,0 +1,163 @@
+package task
+
+import (
+	"errors"
+	"unicode/utf8"
+)
+
+const (
+	MaxTaskRunProgress = 100
+	// 进度说明最多保留的字符数
+	MaxTaskRunProgressMsgLen = 255
+)
+
+// 异步执行期间任务服务上报的进度,每次上报同时作为这次执行的心跳
+type TaskRunProgress struct {
+	taskId string
+	runTimes int
+	progress int
+	msg string
+	hasProgress bool
+	reportedAt int64
+}
+
+func (p *TaskRunProgress) GetTaskId() string {
+	return p.taskId
+}
+
+func (p *TaskRunProgress) GetRunTimes() int {
+	return p.runTimes
+}
+
+// 只上报心跳时返回false,不更新进度和说明
+func (p *TaskRunProgress) GetProgress() (int, string, bool) {
+	return p.progress, p.msg, p.hasProgress
+}
+
+func (p *TaskRunProgress) GetReportedAt() int64 {
+	return p.reportedAt
+}
+
+type NewTaskRunProgressReq struct {
+	TaskId string
+	RunTimes int
+	Progress int
+	Msg string
+	HasProgress bool
+	ReportedAt int64
+}
+
+func NewTaskRunProgress(req *NewTaskRunProgressReq) (*TaskRunProgress, error) {
+	if req.TaskId == "" || req.RunTimes <= 0 {
+		return nil, errors.New("task id and run times are required")
+	}
+
+	if req.HasProgress {
+		if req.Progress < 0 || req.Progress > MaxTaskRunProgress {
+			return nil, errors.New("progress must be between 0 and 100")
+		}
+		if utf8.RuneCountInString(req.Msg) > MaxTaskRunProgressMsgLen {
+			return nil, errors.New("progress message is too long")
+		}
+	}
+
+	return &TaskRunProgress{
+		taskId: req.TaskId,
+		runTimes: req.RunTimes,
+		progress: req.Progress,
+		msg: req.Msg,
+		hasProgress: req.HasProgress,
+		reportedAt: req.ReportedAt,
+	}, nil
+}
+
+// 一次执行的状态和最近上报的进度
+type TaskRun struct {
+	taskId string
+	runTimes int
+	callbackSrvId string
+	status Status
+	startedAt int64
+	endedAt int64
+	isTimeout bool
+	isCancelled bool
+	progress int
+	progressMsg string
+	heartbeatAt int64
+}
+
+func (r *TaskRun) GetTaskId() string {
+	return r.taskId
+}
+
+func (r *TaskRun) GetRunTimes() int {
+	return r.runTimes
+}
+
+// 执行时回调的服务id
+func (r *TaskRun) GetCallbackSrvId() string {
+	return r.callbackSrvId
+}
+
+func (r *TaskRun) GetStatus() Status {
+	return r.status
+}
+
+func (r *TaskRun) GetStartedAt() int64 {
+	return r.startedAt
+}
+
+func (r *TaskRun) GetEndedAt() int64 {
+	return r.endedAt
+}
+
+func (r *TaskRun) IsTimeout() bool {
+	return r.isTimeout
+}
+
+func (r *TaskRun) IsCancelled() bool {
+	return r.isCancelled
+}
+
+func (r *TaskRun) GetProgress() int {
+	return r.progress
+}
+
+func (r *TaskRun) GetProgressMsg() string {
+	return r.progressMsg
+}
+
+// 最近一次上报进度的时间,0代表没有上报过
+func (r *TaskRun) GetHeartbeatAt() int64 {
+	return r.heartbeatAt
+}
+
+type NewTaskRunReq struct {
+	TaskId string
+	RunTimes int
+	CallbackSrvId string
+	Status Status
+	StartedAt int64
+	EndedAt int64
+	IsTimeout bool
+	IsCancelled bool
+	Progress int
+	ProgressMsg string
+	HeartbeatAt int64
+}
+
+func NewTaskRun(req *NewTaskRunReq) *TaskRun {
+	return &TaskRun{
+		taskId: req.TaskId,
+		runTimes: req.RunTimes,
+		callbackSrvId: req.CallbackSrvId,
+		status: req.Status,
+		startedAt: req.StartedAt,
+		endedAt: req.EndedAt,
+		isTimeout: req.IsTimeout,
+		isCancelled: req.IsCancelled,
+		progress: req.Progress,
+		progressMsg: req.ProgressMsg,
+		heartbeatAt: req.HeartbeatAt,
+	}
+}
@@ -0,0 +1,66 @@
+package task
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewTaskRunProgress(t *testing.T) {
+	progress, err := NewTaskRunProgress(&NewTaskRunProgressReq{
+		TaskId: "1",
+		RunTimes: 2,
+		Progress: 40,
+		Msg: "40/100 rows",
+		HasProgress: true,
+		ReportedAt: 1700000000,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, msg, ok := progress.GetProgress(); !ok || value != 40 || msg != "40/100 rows" {
+		t.Errorf("unexpected progress %d %s %v", value, msg, ok)
+	}
+
+	// 只上报心跳时不校验进度
+	progress, err = NewTaskRunProgress(&NewTaskRunProgressReq{
+		TaskId: "1",
+		RunTimes: 2,
+		Progress: -1,
+		ReportedAt: 1700000000,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := progress.GetProgress(); ok {
+		t.Error("expect heart beat only report has no progress")
+	}
+
+	invalidReqs := []*NewTaskRunProgressReq{
+		{RunTimes: 1, HasProgress: true},
+		{TaskId: "1", HasProgress: true},
+		{TaskId: "1", RunTimes: 1, Progress: 101, HasProgress: true},
+		{TaskId: "1", RunTimes: 1, Progress: -1, HasProgress: true},
+		{TaskId: "1", RunTimes: 1, Msg: strings.Repeat("进", MaxTaskRunProgressMsgLen + 1), HasProgress: true},
+	}
+	for i, req := range invalidReqs {
+		if _, err = NewTaskRunProgress(req); err == nil {
+			t.Errorf("expect invalid progress req %d rejected", i)
+		}
+	}
+
+	if _, err = NewTaskRunProgress(&NewTaskRunProgressReq{
+		TaskId: "1",
+		RunTimes: 1,
+		Msg: strings.Repeat("进", MaxTaskRunProgressMsgLen),
+		HasProgress: true,
+	}); err != nil {
+		t.Errorf("expect message with max length accepted, got %v", err)
+	}
+}
+
+func TestNewHeartbeatLostTaskResp(t *testing.T) {
+	resp := NewHeartbeatLostTaskResp("1", 3)
+	if !resp.IsRunInAsync() || !resp.IsTimeout() || resp.IsCancelled() || resp.GetTaskStatus() != StatusFailed || resp.GetTaskRunTimes() != 3 {
+		t.Errorf("expect heartbeat lost run failed as timed out, got %+v", resp)
+	}
+}
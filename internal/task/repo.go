@@ -7,11 +7,29 @@ import (
 
 type TaskRepo interface {
 	TimeoutTasks(ctx context.Context, size int, cursor string) (tasks []*Task, nextCursor string, err error)
+	// 触发队列中到期的任务
+	TimeoutTriggeredTasks(ctx context.Context, size int) ([]*Task, error)
+	// 把任务的一次额外执行加入触发队列,不影响调度计划
+	AddTaskTrigger(ctx context.Context, taskId string, trigger *TaskTrigger) (string, error)
 	LockTask(context.Context, *Task) (bool, error)
 	ConfirmTask(context.Context, *TaskResp) error
+	// 超过执行期限还没有结果的执行
+	DeadlineExceededRuns(ctx context.Context, size int) ([]*TaskResp, error)
+	// 一次执行回调过的节点,用于通知节点取消执行
+	GetRunCallbackRoutes(ctx context.Context, taskId string, runTimes int) ([]*TaskCallbackSrvRoute, error)
+	// 记录执行中的任务上报的进度和心跳
+	ReportTaskProgress(context.Context, *TaskRunProgress) error
+	GetTaskRun(ctx context.Context, taskId string, runTimes int) (*TaskRun, error)
+	// 所有执行中的执行,只包含任务id,第几次执行和回调服务id
+	RunningRuns(ctx context.Context) ([]*TaskRun, error)
+	// 上报过心跳但超过timeoutSec没有再上报的执行
+	HeartbeatLostRuns(ctx context.Context, size int, timeoutSec int) ([]*TaskResp, error)
 	AddTask(context.Context, *Task) (string, error)
 	GetTaskById(context.Context, string) (*Task, error)
 	DelTaskById(context.Context, string) error
+	PauseTask(context.Context, string) error
+	// 恢复暂停的任务,按调度模式从当前时间重新计算下次执行时间
+	ResumeTask(context.Context, string) error
 	DelTasks(context.Context, *optionstream.Stream) error
 }
 
@@ -23,9 +41,21 @@ type TaskCallbackSrvRepo interface {
 	GetSrvs(context.Context, *optionstream.QueryStream) ([]*TaskCallbackSrv, error)
 }
 
+type WorkflowRepo interface {
+	// 按名称新增或更新工作流定义
+	SaveWorkflow(context.Context, *Workflow) (string, error)
+	GetWorkflowById(context.Context, string) (*Workflow, error)
+	StartWorkflow(ctx context.Context, workflowId string) (string, error)
+	GetWorkflowRunById(context.Context, string) (*WorkflowRun, error)
+	// 节点对应的任务执行结束(重试耗尽)后推进工作流
+	ConfirmWorkflowNode(ctx context.Context, workflowRunId, nodeName string, status Status, taskRunTimes int) error
+}
+
 type TaskLogRepo interface {
 	SaveTaskStartedLog(context.Context, *TaskStartedLogDetail) error
 	SaveTaskCallbackLog(context.Context, *TaskCallbackLogDetail) error
 	SaveTaskConfirmedLog(context.Context, *TaskConfirmedLogDetail) error
+	// 广播执行开始回调前为每个子执行写一条执行中的记录
+	SaveTaskSubRunsStartedLog(context.Context, *TaskSubRunsStartedLogDetail) error
 	DelLogs(context.Context, *optionstream.Stream) error
 }
\ No newline at end of file
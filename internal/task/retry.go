@@ -0,0 +1,93 @@
+package task
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var (
+	jitterRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	jitterRandMu sync.Mutex
+)
+
+type RetryPolicy struct {
+	maxAttempts int
+	initialDelaySec int
+	multiplier float64
+	maxDelaySec int
+	jitter float64
+}
+
+func (p *RetryPolicy) GetMaxAttempts() int {
+	return p.maxAttempts
+}
+
+func (p *RetryPolicy) GetInitialDelaySec() int {
+	return p.initialDelaySec
+}
+
+func (p *RetryPolicy) GetMultiplier() float64 {
+	return p.multiplier
+}
+
+func (p *RetryPolicy) GetMaxDelaySec() int {
+	return p.maxDelaySec
+}
+
+func (p *RetryPolicy) GetJitter() float64 {
+	return p.jitter
+}
+
+// attempt从1开始,代表第几次重试
+func (p *RetryPolicy) CanRetry(attempt int) bool {
+	if p == nil {
+		return false
+	}
+	return attempt > 0 && attempt <= p.maxAttempts
+}
+
+// 第attempt次重试距离上次失败的延迟秒数: initialDelaySec * multiplier^(attempt-1),
+// 受maxDelaySec限制后再加上±jitter比例的随机抖动
+func (p *RetryPolicy) GetDelaySec(attempt int) int {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	multiplier := p.multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	delay := float64(p.initialDelaySec) * math.Pow(multiplier, float64(attempt - 1))
+	if p.maxDelaySec > 0 && delay > float64(p.maxDelaySec) {
+		delay = float64(p.maxDelaySec)
+	}
+	if delay > math.MaxInt32 {
+		delay = math.MaxInt32
+	}
+
+	if p.jitter > 0 {
+		jitterRandMu.Lock()
+		factor := jitterRand.Float64() * 2 - 1
+		jitterRandMu.Unlock()
+		delay += delay * p.jitter * factor
+	}
+
+	if delay < 0 {
+		return 0
+	}
+
+	return int(math.Round(delay))
+}
+
+func NewRetryPolicy(maxAttempts, initialDelaySec int, multiplier float64, maxDelaySec int, jitter float64) *RetryPolicy {
+	return &RetryPolicy{
+		maxAttempts: maxAttempts,
+		initialDelaySec: initialDelaySec,
+		multiplier: multiplier,
+		maxDelaySec: maxDelaySec,
+		jitter: jitter,
+	}
+}
@@ -0,0 +1,44 @@
+package task
+
+import "testing"
+
+func TestRetryPolicyGetDelaySec(t *testing.T) {
+	policy := NewRetryPolicy(5, 2, 3, 30, 0)
+	expects := []int{2, 6, 18, 30, 30}
+	for i, expect := range expects {
+		if delay := policy.GetDelaySec(i + 1); delay != expect {
+			t.Errorf("attempt %d expect delay %d, got %d", i + 1, expect, delay)
+		}
+	}
+
+	fixedPolicy := NewRetryPolicy(3, 10, 0, 0, 0)
+	for attempt := 1; attempt <= 3; attempt++ {
+		if delay := fixedPolicy.GetDelaySec(attempt); delay != 10 {
+			t.Errorf("attempt %d expect fixed delay 10, got %d", attempt, delay)
+		}
+	}
+}
+
+func TestRetryPolicyJitter(t *testing.T) {
+	policy := NewRetryPolicy(1, 100, 1, 0, 0.2)
+	for i := 0; i < 1000; i++ {
+		if delay := policy.GetDelaySec(1); delay < 80 || delay > 120 {
+			t.Fatalf("delay %d out of jitter range [80, 120]", delay)
+		}
+	}
+}
+
+func TestRetryPolicyCanRetry(t *testing.T) {
+	var nilPolicy *RetryPolicy
+	if nilPolicy.CanRetry(1) {
+		t.Error("nil policy should not retry")
+	}
+
+	policy := NewRetryPolicy(2, 1, 2, 0, 0)
+	if !policy.CanRetry(1) || !policy.CanRetry(2) {
+		t.Error("attempt 1 and 2 should be allowed")
+	}
+	if policy.CanRetry(3) {
+		t.Error("attempt 3 should exceed max attempts")
+	}
+}
@@ -0,0 +1,256 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	routeRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	routeRandMu sync.Mutex
+)
+
+func randIntn(n int) int {
+	routeRandMu.Lock()
+	defer routeRandMu.Unlock()
+	return routeRand.Intn(n)
+}
+
+type RouteStrategy int
+
+const (
+	// 没有指定,任务没有指定时使用回调服务注册时指定的策略,都没有指定时随机
+	RouteStrategyNil RouteStrategy = iota
+	// 随机
+	RouteStrategyRandom
+	// 轮询
+	RouteStrategyRoundRobin
+	// 按路由权重随机
+	RouteStrategyWeightedRandom
+	// 按biz_id一致性哈希,相同的biz_id总是回调到同一个节点,biz_id为空时按任务id
+	RouteStrategyConsistentHash
+	// 最久没有被选中的节点
+	RouteStrategyLeastRecentlyUsed
+	// 正在执行的回调最少的节点
+	RouteStrategyLeastInFlight
+)
+
+func (s RouteStrategy) check() error {
+	switch s {
+	case RouteStrategyNil, RouteStrategyRandom, RouteStrategyRoundRobin, RouteStrategyWeightedRandom,
+		RouteStrategyConsistentHash, RouteStrategyLeastRecentlyUsed, RouteStrategyLeastInFlight:
+		return nil
+	}
+	return errors.New("invalid route strategy")
+}
+
+// 任务指定的路由策略优先,任务没有指定时使用回调服务注册时指定的策略,都没有指定时随机
+func (t *Task) ResolveRouteStrategy() RouteStrategy {
+	if t.routeStrategy != RouteStrategyNil {
+		return t.routeStrategy
+	}
+	if t.callbackSrv != nil && t.callbackSrv.GetRouteStrategy() != RouteStrategyNil {
+		return t.callbackSrv.GetRouteStrategy()
+	}
+	return RouteStrategyRandom
+}
+
+// 从回调服务的路由中选择本次回调的节点,回调结束后需要调用ReleaseRoute
+type RouteSelector interface {
+	SelectRoute(oneTask *Task, routes []*TaskCallbackSrvRoute) *TaskCallbackSrvRoute
+	ReleaseRoute(route *TaskCallbackSrvRoute)
+}
+
+// 一致性哈希每个权重对应的虚拟节点数
+const consistentHashVirtualNodes = 100
+
+// 按任务或回调服务配置的路由策略选择节点,轮询/最近最少使用/最少执行中的统计只保存在当前调度节点的内存中
+type StrategyRouteSelector struct {
+	mu sync.Mutex
+	roundRobinCursors map[string]uint64
+	lastUsedSeqs map[string]uint64
+	usedSeq uint64
+	inFlights map[string]int
+	consistentHashRings map[string]*consistentHashRing
+}
+
+var _ RouteSelector = (*StrategyRouteSelector)(nil)
+
+func NewStrategyRouteSelector() *StrategyRouteSelector {
+	return &StrategyRouteSelector{
+		roundRobinCursors: map[string]uint64{},
+		lastUsedSeqs: map[string]uint64{},
+		inFlights: map[string]int{},
+		consistentHashRings: map[string]*consistentHashRing{},
+	}
+}
+
+func (s *StrategyRouteSelector) SelectRoute(oneTask *Task, routes []*TaskCallbackSrvRoute) *TaskCallbackSrvRoute {
+	if len(routes) == 0 {
+		return nil
+	}
+
+	var route *TaskCallbackSrvRoute
+	switch oneTask.ResolveRouteStrategy() {
+	case RouteStrategyRoundRobin:
+		route = s.selectRoundRobin(oneTask.GetCallbackSrv().GetId(), routes)
+	case RouteStrategyWeightedRandom:
+		route = selectWeightedRandom(routes)
+	case RouteStrategyConsistentHash:
+		key := oneTask.GetBizId()
+		if key == "" {
+			key = oneTask.GetId()
+		}
+		route = s.getConsistentHashRing(oneTask.GetCallbackSrv().GetId(), routes).selectRoute(key, routes)
+	case RouteStrategyLeastRecentlyUsed:
+		route = s.selectLeastRecentlyUsed(routes)
+	case RouteStrategyLeastInFlight:
+		route = s.selectLeastInFlight(routes)
+	default:
+		route = routes[randIntn(len(routes))]
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.usedSeq++
+	s.lastUsedSeqs[route.GetId()] = s.usedSeq
+	s.inFlights[route.GetId()]++
+
+	return route
+}
+
+func (s *StrategyRouteSelector) ReleaseRoute(route *TaskCallbackSrvRoute) {
+	if route == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.inFlights[route.GetId()] <= 1 {
+		delete(s.inFlights, route.GetId())
+		return
+	}
+	s.inFlights[route.GetId()]--
+}
+
+func (s *StrategyRouteSelector) selectRoundRobin(srvId string, routes []*TaskCallbackSrvRoute) *TaskCallbackSrvRoute {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cursor := s.roundRobinCursors[srvId]
+	s.roundRobinCursors[srvId] = cursor + 1
+	return routes[cursor % uint64(len(routes))]
+}
+
+func (s *StrategyRouteSelector) selectLeastRecentlyUsed(routes []*TaskCallbackSrvRoute) *TaskCallbackSrvRoute {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	selected := routes[0]
+	for _, route := range routes[1:] {
+		if s.lastUsedSeqs[route.GetId()] < s.lastUsedSeqs[selected.GetId()] {
+			selected = route
+		}
+	}
+	return selected
+}
+
+func (s *StrategyRouteSelector) selectLeastInFlight(routes []*TaskCallbackSrvRoute) *TaskCallbackSrvRoute {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var candidates []*TaskCallbackSrvRoute
+	for _, route := range routes {
+		if len(candidates) == 0 || s.inFlights[route.GetId()] < s.inFlights[candidates[0].GetId()] {
+			candidates = []*TaskCallbackSrvRoute{route}
+			continue
+		}
+		if s.inFlights[route.GetId()] == s.inFlights[candidates[0].GetId()] {
+			candidates = append(candidates, route)
+		}
+	}
+	return candidates[randIntn(len(candidates))]
+}
+
+func selectWeightedRandom(routes []*TaskCallbackSrvRoute) *TaskCallbackSrvRoute {
+	var totalWeight int
+	for _, route := range routes {
+		totalWeight += route.GetWeight()
+	}
+	n := randIntn(totalWeight)
+	for _, route := range routes {
+		if n < route.GetWeight() {
+			return route
+		}
+		n -= route.GetWeight()
+	}
+	return routes[len(routes) - 1]
+}
+
+type consistentHashVirtualNode struct {
+	hash uint32
+	routeId string
+}
+
+// 每个节点按权重在哈希环上放置虚拟节点,节点上下线时只影响相邻区间的key
+type consistentHashRing struct {
+	// 构建哈希环的节点和权重,变化时重新构建
+	routesKey string
+	nodes []consistentHashVirtualNode
+}
+
+func newConsistentHashRing(routesKey string, routes []*TaskCallbackSrvRoute) *consistentHashRing {
+	ring := &consistentHashRing{routesKey: routesKey}
+	for _, route := range routes {
+		addr := fmt.Sprintf("%s://%s:%d", route.GetSchema(), route.GetHost(), route.GetPort())
+		for i := 0; i < route.GetWeight() * consistentHashVirtualNodes; i++ {
+			ring.nodes = append(ring.nodes, consistentHashVirtualNode{
+				hash: crc32.ChecksumIEEE([]byte(fmt.Sprintf("%s#%d", addr, i))),
+				routeId: route.GetId(),
+			})
+		}
+	}
+	sort.Slice(ring.nodes, func(i, j int) bool {
+		return ring.nodes[i].hash < ring.nodes[j].hash
+	})
+	return ring
+}
+
+// 哈希环只保存节点id,从本次的路由中取出节点,节点信息以最新注册的为准
+func (r *consistentHashRing) selectRoute(key string, routes []*TaskCallbackSrvRoute) *TaskCallbackSrvRoute {
+	hash := crc32.ChecksumIEEE([]byte(key))
+	idx := sort.Search(len(r.nodes), func(i int) bool {
+		return r.nodes[i].hash >= hash
+	})
+	if idx == len(r.nodes) {
+		idx = 0
+	}
+	for _, route := range routes {
+		if route.GetId() == r.nodes[idx].routeId {
+			return route
+		}
+	}
+	return routes[0]
+}
+
+// 哈希环按回调服务缓存,节点或权重变化时重新构建
+func (s *StrategyRouteSelector) getConsistentHashRing(srvId string, routes []*TaskCallbackSrvRoute) *consistentHashRing {
+	routeKeys := make([]string, 0, len(routes))
+	for _, route := range routes {
+		routeKeys = append(routeKeys, fmt.Sprintf("%s#%d", route.GetId(), route.GetWeight()))
+	}
+	sort.Strings(routeKeys)
+	routesKey := strings.Join(routeKeys, ",")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ring, ok := s.consistentHashRings[srvId]
+	if !ok || ring.routesKey != routesKey {
+		ring = newConsistentHashRing(routesKey, routes)
+		s.consistentHashRings[srvId] = ring
+	}
+	return ring
+}
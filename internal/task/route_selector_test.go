@@ -0,0 +1,144 @@
+package task
+
+import (
+	"fmt"
+	"testing"
+)
+
+func newTestRoutes(n int) []*TaskCallbackSrvRoute {
+	var routes []*TaskCallbackSrvRoute
+	for i := 0; i < n; i++ {
+		routes = append(routes, NewTaskCallbackSrvRoute(fmt.Sprintf("%d", i + 1), "http", "127.0.0.1", 8000 + i, 0, false, 0, nil))
+	}
+	return routes
+}
+
+func TestSelectRouteRoundRobin(t *testing.T) {
+	routes := newTestRoutes(3)
+	oneTask := &Task{
+		callbackSrv: NewTaskCallbackSrv("1", "srv", routes, false, nil, "", "", nil, RouteStrategyRandom, 0),
+		routeStrategy: RouteStrategyRoundRobin,
+	}
+	selector := NewStrategyRouteSelector()
+	for i := 0; i < 6; i++ {
+		if route := selector.SelectRoute(oneTask, routes); route != routes[i % 3] {
+			t.Errorf("select %d: expect route %s, got %s", i, routes[i % 3].GetId(), route.GetId())
+		}
+	}
+}
+
+func TestSelectRouteConsistentHash(t *testing.T) {
+	routes := newTestRoutes(5)
+	srv := NewTaskCallbackSrv("1", "srv", routes, false, nil, "", "", nil, RouteStrategyNil, 0)
+	selector := NewStrategyRouteSelector()
+	for i := 0; i < 20; i++ {
+		oneTask := &Task{
+			id: "1",
+			bizId: fmt.Sprintf("order_%d", i),
+			callbackSrv: srv,
+			routeStrategy: RouteStrategyConsistentHash,
+		}
+		route := selector.SelectRoute(oneTask, routes)
+		// 节点顺序变化不影响结果
+		reversed := []*TaskCallbackSrvRoute{routes[4], routes[3], routes[2], routes[1], routes[0]}
+		if again := selector.SelectRoute(oneTask, reversed); again != route {
+			t.Errorf("biz id %s: expect route %s, got %s", oneTask.bizId, route.GetId(), again.GetId())
+		}
+	}
+}
+
+func TestSelectRouteConsistentHashRebuildRing(t *testing.T) {
+	routes := newTestRoutes(2)
+	oneTask := &Task{
+		id: "1",
+		callbackSrv: NewTaskCallbackSrv("1", "srv", routes, false, nil, "", "", nil, RouteStrategyNil, 0),
+		routeStrategy: RouteStrategyConsistentHash,
+	}
+	selector := NewStrategyRouteSelector()
+	selector.SelectRoute(oneTask, routes)
+	ring := selector.consistentHashRings["1"]
+	selector.SelectRoute(oneTask, routes)
+	if selector.consistentHashRings["1"] != ring {
+		t.Error("expect ring cached when routes not changed")
+	}
+
+	// 权重变化后重新构建哈希环
+	weighted := []*TaskCallbackSrvRoute{routes[0], NewTaskCallbackSrvRoute("2", "http", "127.0.0.1", 8001, 0, false, 3, nil)}
+	if route := selector.SelectRoute(oneTask, weighted); route != weighted[0] && route != weighted[1] {
+		t.Error("expect route selected from current routes")
+	}
+	if selector.consistentHashRings["1"] == ring {
+		t.Error("expect ring rebuilt when route weight changed")
+	}
+}
+
+func TestSelectRouteLeastInFlight(t *testing.T) {
+	routes := newTestRoutes(2)
+	oneTask := &Task{routeStrategy: RouteStrategyLeastInFlight}
+	selector := NewStrategyRouteSelector()
+	first := selector.SelectRoute(oneTask, routes)
+	second := selector.SelectRoute(oneTask, routes)
+	if first == second {
+		t.Fatalf("expect different routes when one route is in flight, got %s twice", first.GetId())
+	}
+	selector.ReleaseRoute(first)
+	if route := selector.SelectRoute(oneTask, routes); route != first {
+		t.Errorf("expect released route %s, got %s", first.GetId(), route.GetId())
+	}
+}
+
+func TestSelectRouteLeastRecentlyUsed(t *testing.T) {
+	routes := newTestRoutes(3)
+	oneTask := &Task{routeStrategy: RouteStrategyLeastRecentlyUsed}
+	selector := NewStrategyRouteSelector()
+	seen := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		route := selector.SelectRoute(oneTask, routes)
+		selector.ReleaseRoute(route)
+		seen[route.GetId()] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("expect every route selected once, got %d routes", len(seen))
+	}
+}
+
+func TestSelectRouteWeightedRandom(t *testing.T) {
+	routes := []*TaskCallbackSrvRoute{
+		NewTaskCallbackSrvRoute("1", "http", "127.0.0.1", 8000, 0, false, 1, nil),
+		NewTaskCallbackSrvRoute("2", "http", "127.0.0.1", 8001, 0, false, 9, nil),
+	}
+	oneTask := &Task{routeStrategy: RouteStrategyWeightedRandom}
+	selector := NewStrategyRouteSelector()
+	hits := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		route := selector.SelectRoute(oneTask, routes)
+		selector.ReleaseRoute(route)
+		hits[route.GetId()]++
+	}
+	if hits["2"] < 800 {
+		t.Errorf("expect route 2 selected about 900 times, got %d", hits["2"])
+	}
+}
+
+func TestResolveRouteStrategy(t *testing.T) {
+	srv := NewTaskCallbackSrv("1", "srv", nil, false, nil, "", "", nil, RouteStrategyLeastInFlight, 0)
+	oneTask := &Task{callbackSrv: srv}
+	if strategy := oneTask.ResolveRouteStrategy(); strategy != RouteStrategyLeastInFlight {
+		t.Errorf("expect server route strategy when task not specified, got %d", strategy)
+	}
+
+	oneTask.routeStrategy = RouteStrategyRoundRobin
+	if strategy := oneTask.ResolveRouteStrategy(); strategy != RouteStrategyRoundRobin {
+		t.Errorf("expect task route strategy takes precedence, got %d", strategy)
+	}
+
+	oneTask.routeStrategy = RouteStrategyRandom
+	if strategy := oneTask.ResolveRouteStrategy(); strategy != RouteStrategyRandom {
+		t.Errorf("expect task random route strategy takes precedence, got %d", strategy)
+	}
+
+	oneTask = &Task{callbackSrv: NewTaskCallbackSrv("1", "srv", nil, false, nil, "", "", nil, RouteStrategyNil, 0)}
+	if strategy := oneTask.ResolveRouteStrategy(); strategy != RouteStrategyRandom {
+		t.Errorf("expect random route strategy when neither specified, got %d", strategy)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/995933447/easytask/pkg/contxt"
 	"github.com/995933447/simpletrace"
 	simpletracectx "github.com/995933447/simpletrace/context"
+	"sync"
 	"time"
 )
 
@@ -17,14 +18,96 @@ type Sched struct {
 	taskRespCh    chan *TaskResp
 	elect         autoelect.AutoElection
 	exitSignCh    chan struct{}
+	exitReaperSignCh chan struct{}
+	// 上报过心跳的执行超过多少秒没有再上报时判定为超时失败,0代表不检查
+	heartbeatTimeoutSec int
+	// 计划时间落后超过多少秒算错过触发
+	misfireToleranceSec int
+	deferredFiresMu sync.Mutex
+	// 因回调服务限流或按并发策略排队被延后的调度触发,key为任务id
+	deferredFires map[string]*deferredFire
+}
+
+type deferredFire struct {
+	planSchedNextAt int64
+	firstDeferredAt int64
+}
+
+// 记录本次调度触发被延后,延后的触发按第一次被延后时的落后时间判断是否错过,不会因为延后本身变成错过触发
+func (s *Sched) deferFire(task *Task) {
+	if task.trigger != nil {
+		return
+	}
+
+	s.deferredFiresMu.Lock()
+	defer s.deferredFiresMu.Unlock()
+
+	if fire, ok := s.deferredFires[task.id]; ok && fire.planSchedNextAt == task.planSchedNextAt {
+		return
+	}
+	s.deferredFires[task.id] = &deferredFire{
+		planSchedNextAt: task.planSchedNextAt,
+		firstDeferredAt: time.Now().Unix(),
+	}
+}
+
+// 本次调度触发已经被延后的秒数
+func (s *Sched) getFireDeferredSec(task *Task, now time.Time) int {
+	s.deferredFiresMu.Lock()
+	defer s.deferredFiresMu.Unlock()
+
+	fire, ok := s.deferredFires[task.id]
+	if !ok || fire.planSchedNextAt != task.planSchedNextAt || now.Unix() <= fire.firstDeferredAt {
+		return 0
+	}
+	return int(now.Unix() - fire.firstDeferredAt)
+}
+
+func (s *Sched) clearDeferredFire(task *Task) {
+	s.deferredFiresMu.Lock()
+	defer s.deferredFiresMu.Unlock()
+
+	delete(s.deferredFires, task.id)
 }
 
 func (s *Sched) lockTaskForRun(ctx context.Context, task *Task) (bool, error) {
+	// 触发队列中的任务不影响调度计划,不需要做调度决策
+	if task.trigger == nil {
+		now := time.Now()
+		decision, err := task.DecideSched(now, s.misfireToleranceSec + s.getFireDeferredSec(task, now))
+		if err != nil {
+			logger.MustGetTaskLogger().Error(ctx, err)
+			return false, err
+		}
+
+		if decision.GetMisfireDecision() != MisfireDecisionNil {
+			logger.MustGetTaskLogger().Infof(
+				ctx,
+				"task(id:%s) misfired %d times since %d, decision:%d",
+				task.id, decision.GetMisfiredTimes(), task.planSchedNextAt, decision.GetMisfireDecision(),
+			)
+		}
+	}
+
 	locked, err := s.taskRepo.LockTask(ctx, task)
 	if err != nil {
 		logger.MustGetTaskLogger().Error(ctx, err)
 		return false, err
 	}
+
+	if locked && task.trigger == nil {
+		s.clearDeferredFire(task)
+	}
+
+	if !locked && task.schedDecision.IsQueued() {
+		logger.MustGetTaskLogger().Debugf(ctx, "task(id:%s) last run is still running, queue this fire", task.id)
+		s.deferFire(task)
+	}
+
+	if locked && task.schedDecision.GetSkipReason() == SkipReasonConcurrencyForbidden {
+		logger.MustGetTaskLogger().Infof(ctx, "task(id:%s) last run is still running, skip this fire", task.id)
+	}
+
 	return locked, nil
 }
 
@@ -33,11 +116,13 @@ func (s *Sched) nextTask() *Task {
 }
 
 func (s *Sched) run(ctx context.Context) {
+	go s.reapDeadlineExceededRuns(ctx)
 	s.schedule(ctx)
 }
 
 func (s *Sched) stop() {
 	s.exitSignCh <- struct{}{}
+	s.exitReaperSignCh <- struct{}{}
 }
 
 func (s *Sched) schedule(ctx context.Context) {
@@ -73,13 +158,23 @@ func (s *Sched) schedule(ctx context.Context) {
 			continue
 		}
 
+		triggeredTasks, err := s.taskRepo.TimeoutTriggeredTasks(ctx, size)
+		if err != nil {
+			logger.MustGetSysLogger().Error(ctx, err)
+			continue
+		}
+
+		for _, oneTask := range triggeredTasks {
+			s.taskCh <- oneTask
+		}
+
 		tasks, nextCursor, err := s.taskRepo.TimeoutTasks(ctx, size, cursor)
 		if err != nil {
 			logger.MustGetSysLogger().Error(ctx, err)
 			continue
 		}
 
-		logger.MustGetSysLogger().Debugf(ctx, "scheduler tasks(len:%d)", len(tasks))
+		logger.MustGetSysLogger().Debugf(ctx, "scheduler tasks(len:%d), triggered tasks(len:%d)", len(tasks), len(triggeredTasks))
 
 		if len(tasks) == 0 {
 			logger.MustGetSysLogger().Debugf(ctx, "no more tasks, sleep 1 s, last cursor is %s", cursor)
@@ -96,6 +191,79 @@ func (s *Sched) schedule(ctx context.Context) {
 	}
 }
 
+// 主节点把超过执行期限或心跳中断还没有结果的执行判定为失败,按正常确认流程处理重试和工作流
+func (s *Sched) reapDeadlineExceededRuns(ctx context.Context) {
+	var (
+		traceModule = "task_reaper"
+		origCtxTraceId string
+	)
+	if traceCtx, ok := ctx.(*simpletracectx.Context); ok {
+		origCtxTraceId = traceCtx.GetTraceId()
+	}
+
+	size := 1000
+	for {
+		var isExitingReaper bool
+		select {
+		case _ = <- s.exitReaperSignCh:
+			isExitingReaper = true
+		default:
+		}
+
+		if isExitingReaper {
+			break
+		}
+
+		ctx = contxt.NewWithTrace(traceModule, context.TODO(), traceModule + "_" + origCtxTraceId + "." + simpletrace.NewTraceId(), "")
+		if !s.elect.IsMaster() {
+			time.Sleep(time.Second)
+			continue
+		}
+
+		resps, err := s.taskRepo.DeadlineExceededRuns(ctx, size)
+		if err != nil {
+			logger.MustGetSysLogger().Error(ctx, err)
+			time.Sleep(time.Second)
+			continue
+		}
+
+		for _, resp := range resps {
+			logger.MustGetSysLogger().Warnf(ctx, "task(id:%s) run(times:%d) deadline exceeded, mark it failed", resp.GetTaskId(), resp.GetTaskRunTimes())
+			// 和迟到的确认并发时只有一方生效,另一方会被拒绝
+			if err = s.submitTaskResp(ctx, resp); err != nil {
+				logger.MustGetSysLogger().Error(ctx, err)
+			}
+		}
+
+		heartbeatLostNum := s.reapHeartbeatLostRuns(ctx, size)
+
+		if len(resps) < size && heartbeatLostNum < size {
+			time.Sleep(time.Second)
+		}
+	}
+}
+
+// 返回本次判定失败的执行数
+func (s *Sched) reapHeartbeatLostRuns(ctx context.Context, size int) int {
+	if s.heartbeatTimeoutSec <= 0 {
+		return 0
+	}
+
+	resps, err := s.taskRepo.HeartbeatLostRuns(ctx, size, s.heartbeatTimeoutSec)
+	if err != nil {
+		logger.MustGetSysLogger().Error(ctx, err)
+		return 0
+	}
+
+	for _, resp := range resps {
+		logger.MustGetSysLogger().Warnf(ctx, "task(id:%s) run(times:%d) heartbeat lost, mark it failed", resp.GetTaskId(), resp.GetTaskRunTimes())
+		if err = s.submitTaskResp(ctx, resp); err != nil {
+			logger.MustGetSysLogger().Error(ctx, err)
+		}
+	}
+
+	return len(resps)
+}
 
 func (s *Sched) submitTaskResp(ctx context.Context, resp *TaskResp) error {
 	if err := s.taskRepo.ConfirmTask(ctx, resp); err != nil {
@@ -105,12 +273,20 @@ func (s *Sched) submitTaskResp(ctx context.Context, resp *TaskResp) error {
 	return nil
 }
 
-func NewSched(taskRepo TaskRepo, elect autoelect.AutoElection) *Sched {
+// heartbeatTimeoutSec为0时不检查执行的心跳,misfireToleranceSec为0时使用DefaultMisfireToleranceSec
+func NewSched(taskRepo TaskRepo, elect autoelect.AutoElection, heartbeatTimeoutSec, misfireToleranceSec int) *Sched {
+	if misfireToleranceSec <= 0 {
+		misfireToleranceSec = DefaultMisfireToleranceSec
+	}
 	return &Sched{
 		taskCh: make(chan *Task),
 		taskRepo: taskRepo,
 		taskRespCh: make(chan *TaskResp),
 		elect: elect,
 		exitSignCh: make(chan struct{}),
+		exitReaperSignCh: make(chan struct{}),
+		heartbeatTimeoutSec: heartbeatTimeoutSec,
+		misfireToleranceSec: misfireToleranceSec,
+		deferredFires: map[string]*deferredFire{},
 	}
 }
\ No newline at end of file
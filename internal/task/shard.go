@@ -0,0 +1,35 @@
+package task
+
+// 分片执行时每个分片记为一个子执行,第i个分片(从0开始)对应第i+1个子执行
+
+// 把分片依次轮流分配到回调节点上,返回的第i个路由执行第i个分片
+func GetShardRoutes(routes []*TaskCallbackSrvRoute, shardTotal int) []*TaskCallbackSrvRoute {
+	if len(routes) == 0 {
+		return nil
+	}
+	var shardRoutes []*TaskCallbackSrvRoute
+	for i := 0; i < shardTotal; i++ {
+		shardRoutes = append(shardRoutes, routes[i % len(routes)])
+	}
+	return shardRoutes
+}
+
+// 所有分片都结束后才算执行结束,全部成功才算成功,还有分片没结束时返回StatusRunning
+func AggregateShardStatus(shardStatuses []Status) Status {
+	if len(shardStatuses) == 0 {
+		return StatusFailed
+	}
+
+	status := StatusSuccess
+	for _, shardStatus := range shardStatuses {
+		if IsTaskSuccess(shardStatus) {
+			continue
+		}
+		if !IsTaskFailed(shardStatus) {
+			return StatusRunning
+		}
+		status = StatusFailed
+	}
+
+	return status
+}
@@ -0,0 +1,41 @@
+package task
+
+import "testing"
+
+func TestGetShardRoutes(t *testing.T) {
+	routeA := &TaskCallbackSrvRoute{id: "a"}
+	routeB := &TaskCallbackSrvRoute{id: "b"}
+	routes := GetShardRoutes([]*TaskCallbackSrvRoute{routeA, routeB}, 5)
+	if len(routes) != 5 {
+		t.Fatalf("expect 5 shard routes, got %d", len(routes))
+	}
+	for i, route := range routes {
+		expect := routeA
+		if i % 2 == 1 {
+			expect = routeB
+		}
+		if route != expect {
+			t.Errorf("shard %d: expect route %s, got %s", i, expect.GetId(), route.GetId())
+		}
+	}
+	if routes := GetShardRoutes(nil, 3); routes != nil {
+		t.Errorf("expect no shard route without callback route, got %d", len(routes))
+	}
+}
+
+func TestAggregateShardStatus(t *testing.T) {
+	cases := []struct {
+		shardStatuses []Status
+		expect Status
+	}{
+		{nil, StatusFailed},
+		{[]Status{StatusSuccess, StatusSuccess}, StatusSuccess},
+		{[]Status{StatusFailed, StatusRunning}, StatusRunning},
+		{[]Status{StatusFailed, StatusSuccess}, StatusFailed},
+	}
+	for i, c := range cases {
+		if status := AggregateShardStatus(c.shardStatuses); status != c.expect {
+			t.Errorf("case %d: expect status %d, got %d", i, c.expect, status)
+		}
+	}
+}
@@ -7,7 +7,7 @@ import (
 	"github.com/995933447/easytask/internal/util/logger"
 	"github.com/go-playground/validator"
 	"github.com/gorhill/cronexpr"
-	"math/rand"
+	"math"
 	"time"
 )
 
@@ -19,6 +19,7 @@ const (
 	StatusRunning
 	StatusSuccess
 	StatusFailed
+	StatusSkipped
 )
 
 type SchedMode int
@@ -28,8 +29,16 @@ const (
 	SchedModeTimeCron
 	SchedModeTimeSpec
 	SchedModeTimeInterval
+	// 固定延时,上次执行结束(同步回调返回结果或异步确认)后才按间隔计算下次执行时间
+	SchedModeFixedDelay
 )
 
+// 固定延时任务执行期间的计划执行时间,执行结束前不会被调度
+const SchedNextAtWaitingRunEnded int64 = math.MaxInt64
+
+// 注册回调服务时没有指定redis stream长度上限时使用
+const DefaultStreamMaxLen int64 = 100000
+
 type (
 	TaskResp struct {
 		taskId string
@@ -37,6 +46,9 @@ type (
 		taskStatus Status
 		taskRunTimes int
 		extra string
+		isTimeout bool
+		isCancelled bool
+		subRunIdx int
 	}
 
 	InternalErrTaskRespDetail struct {
@@ -65,6 +77,21 @@ func (r *TaskResp) GetTaskRunTimes() int {
 	return r.taskRunTimes
 }
 
+// 是否是超过执行期限被判定失败
+func (r *TaskResp) IsTimeout() bool {
+	return r.isTimeout
+}
+
+// 是否是被手动取消判定失败
+func (r *TaskResp) IsCancelled() bool {
+	return r.isCancelled
+}
+
+// 广播执行中确认的是第几个子执行,0代表确认整次执行
+func (r *TaskResp) GetSubRunIdx() int {
+	return r.subRunIdx
+}
+
 func NewTaskResp(taskId string, isRunInAsync bool, taskStatus Status, taskRunTimes int, extra string) *TaskResp {
 	return &TaskResp{
 		taskId: taskId,
@@ -75,7 +102,46 @@ func NewTaskResp(taskId string, isRunInAsync bool, taskStatus Status, taskRunTim
 	}
 }
 
-func newInternalErrTaskResp(taskId string, err error, occurredAt int64) (*TaskResp, error) {
+// 异步确认广播执行中的一个子执行
+func NewSubRunTaskResp(taskId string, subRunIdx int, taskStatus Status, taskRunTimes int, extra string) *TaskResp {
+	return &TaskResp{
+		taskId: taskId,
+		isRunInAsync: true,
+		taskStatus: taskStatus,
+		taskRunTimes: taskRunTimes,
+		extra: extra,
+		subRunIdx: subRunIdx,
+	}
+}
+
+// 超过执行期限还没有结果的执行按失败处理,和异步确认一样只能确认执行中的任务
+func NewDeadlineExceededTaskResp(taskId string, taskRunTimes int) *TaskResp {
+	return &TaskResp{
+		taskId: taskId,
+		isRunInAsync: true,
+		taskStatus: StatusFailed,
+		taskRunTimes: taskRunTimes,
+		isTimeout: true,
+	}
+}
+
+// 上报过进度的执行心跳中断时和超过执行期限一样按超时失败处理
+func NewHeartbeatLostTaskResp(taskId string, taskRunTimes int) *TaskResp {
+	return NewDeadlineExceededTaskResp(taskId, taskRunTimes)
+}
+
+// 手动取消的执行按失败处理,不再重试,和异步确认一样只能取消执行中的任务
+func NewCancelledTaskResp(taskId string, taskRunTimes int) *TaskResp {
+	return &TaskResp{
+		taskId: taskId,
+		isRunInAsync: true,
+		taskStatus: StatusFailed,
+		taskRunTimes: taskRunTimes,
+		isCancelled: true,
+	}
+}
+
+func newInternalErrTaskResp(taskId string, taskRunTimes int, err error, occurredAt int64) (*TaskResp, error) {
 	detail := InternalErrTaskRespDetail{
 		Err: err,
 		OccurredAt: occurredAt,
@@ -89,6 +155,7 @@ func newInternalErrTaskResp(taskId string, err error, occurredAt int64) (*TaskRe
 	return &TaskResp{
 		taskId: taskId,
 		taskStatus: StatusFailed,
+		taskRunTimes: taskRunTimes,
 		extra: string(extra),
 	}, nil
 }
@@ -100,6 +167,8 @@ type TaskCallbackSrvRoute struct {
 	port int
 	callbackTimeoutSec int
 	isEnableHealthCheck bool
+	weight int
+	labels map[string]string
 }
 
 func (r *TaskCallbackSrvRoute) IsEnableHeathCheck() bool {
@@ -126,7 +195,19 @@ func (r *TaskCallbackSrvRoute) GetCallbackTimeoutSec() int {
 	return r.callbackTimeoutSec
 }
 
-func NewTaskCallbackSrvRoute(id, schema, host string, port, callbackTimeoutSec int, isEnableHealthCheck bool) *TaskCallbackSrvRoute {
+// 路由权重,未设置时为1
+func (r *TaskCallbackSrvRoute) GetWeight() int {
+	if r.weight <= 0 {
+		return 1
+	}
+	return r.weight
+}
+
+func (r *TaskCallbackSrvRoute) GetLabels() map[string]string {
+	return r.labels
+}
+
+func NewTaskCallbackSrvRoute(id, schema, host string, port, callbackTimeoutSec int, isEnableHealthCheck bool, weight int, labels map[string]string) *TaskCallbackSrvRoute {
 	return &TaskCallbackSrvRoute{
 		id: id,
 		scheme: schema,
@@ -134,6 +215,8 @@ func NewTaskCallbackSrvRoute(id, schema, host string, port, callbackTimeoutSec i
 		port: port,
 		callbackTimeoutSec: callbackTimeoutSec,
 		isEnableHealthCheck: isEnableHealthCheck,
+		weight: weight,
+		labels: labels,
 	}
 }
 
@@ -142,6 +225,13 @@ type TaskCallbackSrv struct {
 	name   string
 	routes []*TaskCallbackSrvRoute
 	hasEnableHealthCheck bool
+	limit *CallbackSrvLimit
+	streamKey string
+	secret string
+	isClearSecret bool
+	headers *CallbackSrvHeaders
+	routeStrategy RouteStrategy
+	streamMaxLen int64
 }
 
 func (s *TaskCallbackSrv) HasEnableHealthCheckRoute() bool {
@@ -168,20 +258,70 @@ func (s *TaskCallbackSrv) GetId() string {
 	return s.id
 }
 
+// 为空代表注册时没有指定,不限制
+func (s *TaskCallbackSrv) GetLimit() *CallbackSrvLimit {
+	return s.limit
+}
+
+// 注册服务时指定的路由策略,任务没有指定路由策略时使用
+func (s *TaskCallbackSrv) GetRouteStrategy() RouteStrategy {
+	return s.routeStrategy
+}
+
+// 投递到redis stream时使用的stream key,注册时没有指定则为easytask:task_callback:{服务名称}
+func (s *TaskCallbackSrv) GetStreamKey() string {
+	if s.streamKey == "" {
+		return "easytask:task_callback:" + s.name
+	}
+	return s.streamKey
+}
+
+// redis stream的大致长度上限,投递时按该长度近似裁剪旧消息,注册时没有指定则为DefaultStreamMaxLen
+func (s *TaskCallbackSrv) GetStreamMaxLen() int64 {
+	if s.streamMaxLen <= 0 {
+		return DefaultStreamMaxLen
+	}
+	return s.streamMaxLen
+}
+
+// 回调请求签名的密钥,为空代表不签名
+func (s *TaskCallbackSrv) GetSecret() string {
+	return s.secret
+}
+
+// 注册时secret为空不会清除已经设置的签名密钥,需要显式指定清除
+func (s *TaskCallbackSrv) IsClearSecret() bool {
+	return s.isClearSecret
+}
+
+func (s *TaskCallbackSrv) SetClearSecret(isClearSecret bool) {
+	s.isClearSecret = isClearSecret
+}
+
+// 回调http节点时带上的静态header,为空代表注册时没有指定
+func (s *TaskCallbackSrv) GetHeaders() *CallbackSrvHeaders {
+	return s.headers
+}
+
 func (s *TaskCallbackSrv) GetRandomRoute() *TaskCallbackSrvRoute {
 	if len(s.routes) == 0 {
 		return nil
 	}
-	rand.Seed(time.Now().UnixNano())
-	return s.routes[rand.Intn(len(s.routes))]
+	return s.routes[randIntn(len(s.routes))]
 }
 
-func NewTaskCallbackSrv(id, name string, routes []*TaskCallbackSrvRoute, hasEnableHealthCheck bool) *TaskCallbackSrv {
+func NewTaskCallbackSrv(id, name string, routes []*TaskCallbackSrvRoute, hasEnableHealthCheck bool, limit *CallbackSrvLimit, streamKey, secret string, headers *CallbackSrvHeaders, routeStrategy RouteStrategy, streamMaxLen int64) *TaskCallbackSrv {
 	return &TaskCallbackSrv{
 		id: id,
 		name: name,
 		routes: routes,
 		hasEnableHealthCheck: hasEnableHealthCheck,
+		limit: limit,
+		streamKey: streamKey,
+		secret: secret,
+		headers: headers,
+		routeStrategy: routeStrategy,
+		streamMaxLen: streamMaxLen,
 	}
 }
 
@@ -189,6 +329,8 @@ type Task struct {
 	id string
 	callbackSrv *TaskCallbackSrv
 	callbackPath string
+	callbackMethod CallbackMethod
+	callbackContentType CallbackContentType
 	name string
 	arg string
 	runTimes int
@@ -200,19 +342,43 @@ type Task struct {
 	timeIntervalSec int
 	timeSpecAt int64
 	bizId string
+	retryPolicy *RetryPolicy
+	retryAttempt int
+	timeZone string
+	planSchedNextAt int64
+	misfirePolicy MisfirePolicy
+	misfireMaxCatchUpTimes int
+	misfireThresholdSec int
+	schedDecision *SchedDecision
+	trigger *TaskTrigger
+	concurrencyPolicy ConcurrencyPolicy
+	isPaused bool
+	dispatchMode DispatchMode
+	broadcastSuccessRule BroadcastSuccessRule
+	shardTotal int
+	routeStrategy RouteStrategy
+	labelSelector map[string]string
+	failoverMaxAttempts int
 }
 
 func (t *Task) GetSchedNextAt() (int64, error) {
-	now := time.Now()
+	return t.getSchedNextAt(time.Now())
+}
+
+func (t *Task) getSchedNextAt(now time.Time) (int64, error) {
 	switch t.GetSchedMode() {
-	case SchedModeTimeInterval:
+	case SchedModeTimeInterval, SchedModeFixedDelay:
 		return now.Unix() + int64(t.GetTimeIntervalSec()), nil
 	case SchedModeTimeCron:
 		expr, err := cronexpr.Parse(t.GetTimeCronExpr())
 		if err != nil {
 			return 0, err
 		}
-		return expr.Next(now).Unix(), nil
+		loc, err := loadLocation(t.timeZone)
+		if err != nil {
+			return 0, err
+		}
+		return nextCronAt(expr, isCronHourFixed(t.GetTimeCronExpr()), now, loc).Unix(), nil
 	case SchedModeTimeSpec:
 		return t.timeSpecAt, nil
 	}
@@ -223,6 +389,15 @@ func (t *Task) GetBizId() string {
 	return t.bizId
 }
 
+func (t *Task) GetRetryPolicy() *RetryPolicy {
+	return t.retryPolicy
+}
+
+// 当前这次执行是第几次重试,0代表正常调度
+func (t *Task) GetRetryAttempt() int {
+	return t.retryAttempt
+}
+
 func (t *Task) GetTimeIntervalSec() int {
 	return t.timeIntervalSec
 }
@@ -235,10 +410,73 @@ func (t *Task) GetTimeCronExpr() string {
 	return t.timeCronExpr
 }
 
+// cron表达式使用的IANA时区,为空代表服务器本地时区
+func (t *Task) GetTimeZone() string {
+	return t.timeZone
+}
+
+// 上次调度时计划的执行时间
+func (t *Task) GetPlanSchedNextAt() int64 {
+	return t.planSchedNextAt
+}
+
+func (t *Task) GetMisfirePolicy() MisfirePolicy {
+	return t.misfirePolicy
+}
+
+// 补触发错过任务的最大次数,0代表不限制
+func (t *Task) GetMisfireMaxCatchUpTimes() int {
+	return t.misfireMaxCatchUpTimes
+}
+
+func (t *Task) GetMisfireThresholdSec() int {
+	return t.misfireThresholdSec
+}
+
+func (t *Task) GetDispatchMode() DispatchMode {
+	return t.dispatchMode
+}
+
+func (t *Task) GetBroadcastSuccessRule() BroadcastSuccessRule {
+	return t.broadcastSuccessRule
+}
+
+func (t *Task) GetRouteStrategy() RouteStrategy {
+	return t.routeStrategy
+}
+
+func (t *Task) GetLabelSelector() map[string]string {
+	return t.labelSelector
+}
+
+// 大于0代表每次执行拆分成该数量的分片
+func (t *Task) GetShardTotal() int {
+	return t.shardTotal
+}
+
+func (t *Task) IsPaused() bool {
+	return t.isPaused
+}
+
+func (t *Task) GetConcurrencyPolicy() ConcurrencyPolicy {
+	return t.concurrencyPolicy
+}
+
+// 从触发队列中捞出的任务不为nil
+func (t *Task) GetTrigger() *TaskTrigger {
+	return t.trigger
+}
+
+// 本次调度的决策,调用DecideSched之前为nil
+func (t *Task) GetSchedDecision() *SchedDecision {
+	return t.schedDecision
+}
+
 func (t *Task) GetTimeSpecAt() int64 {
 	return t.timeSpecAt
 }
 
+// 单次执行的最长时间,超过后按失败处理,0代表不限制
 func (t *Task) GetMaxRunTimeSec() int {
 	return t.maxRunTimeSec
 }
@@ -267,6 +505,22 @@ func (t *Task) GetCallbackPath() string {
 	return t.callbackPath
 }
 
+// 回调http节点的请求方法,未指定时为POST
+func (t *Task) GetCallbackMethod() CallbackMethod {
+	if t.callbackMethod == CallbackMethodNil {
+		return CallbackMethodPost
+	}
+	return t.callbackMethod
+}
+
+// 回调http节点的请求体格式,未指定时为json
+func (t *Task) GetCallbackContentType() CallbackContentType {
+	if t.callbackContentType == CallbackContentTypeNil {
+		return CallbackContentTypeJson
+	}
+	return t.callbackContentType
+}
+
 func (t *Task) GetArg() string {
 	return t.arg
 }
@@ -279,6 +533,10 @@ func (t *Task) IncrRunTimes() {
 	t.runTimes++
 }
 
+func (t *Task) SetRunTimes(runTimes int) {
+	t.runTimes = runTimes
+}
+
 func (t *Task) run(ctx context.Context, callbackExec TaskCallbackSrvExec) (*TaskResp, error) {
 	callbackResp, err := callbackExec.CallbackSrv(ctx, t, nil)
 	if err != nil {
@@ -302,6 +560,8 @@ type NewTaskReq struct {
 	Id string
 	CallbackSrv *TaskCallbackSrv `validate:"required"`
 	CallbackPath string `json:"callback_path"`
+	CallbackMethod CallbackMethod
+	CallbackContentType CallbackContentType
 	Name string `validate:"required"`
 	Arg string
 	RunTimes int
@@ -313,10 +573,50 @@ type NewTaskReq struct {
 	TimeIntervalSec int
 	TimeSpecAt int64
 	BizId string
+	RetryPolicy *RetryPolicy
+	RetryAttempt int
+	TimeZone string
+	PlanSchedNextAt int64
+	MisfirePolicy MisfirePolicy
+	MisfireMaxCatchUpTimes int
+	MisfireThresholdSec int
+	Trigger *TaskTrigger
+	ConcurrencyPolicy ConcurrencyPolicy
+	IsPaused bool
+	DispatchMode DispatchMode
+	BroadcastSuccessRule BroadcastSuccessRule
+	ShardTotal int `validate:"gte=0"`
+	RouteStrategy RouteStrategy
+	LabelSelector map[string]string
+	FailoverMaxAttempts int `validate:"gte=0"`
 }
 
 func (r *NewTaskReq) Check() error {
-	return validator.New().Struct(r)
+	if err := validator.New().Struct(r); err != nil {
+		return err
+	}
+	if err := r.MisfirePolicy.check(); err != nil {
+		return err
+	}
+	if err := r.ConcurrencyPolicy.check(); err != nil {
+		return err
+	}
+	if err := r.DispatchMode.check(); err != nil {
+		return err
+	}
+	if err := r.RouteStrategy.check(); err != nil {
+		return err
+	}
+	if err := r.CallbackMethod.check(); err != nil {
+		return err
+	}
+	if err := r.CallbackContentType.check(); err != nil {
+		return err
+	}
+	if r.ShardTotal > 0 && r.DispatchMode == DispatchModeBroadcast {
+		return errors.New("sharding can not be used with broadcast dispatch mode")
+	}
+	return r.BroadcastSuccessRule.check()
 }
 
 func NewTask(req *NewTaskReq) (*Task, error) {
@@ -331,6 +631,8 @@ func NewTask(req *NewTaskReq) (*Task, error) {
 		runTimes: req.RunTimes,
 		callbackSrv: req.CallbackSrv,
 		callbackPath: req.CallbackPath,
+		callbackMethod: req.CallbackMethod,
+		callbackContentType: req.CallbackContentType,
 		allowMaxRunTimes: req.AllowMaxRunTimes,
 		lastRunAt: req.LastRunAt,
 		maxRunTimeSec: req.MaxRunTimeSec,
@@ -339,6 +641,22 @@ func NewTask(req *NewTaskReq) (*Task, error) {
 		timeIntervalSec: req.TimeIntervalSec,
 		timeSpecAt: req.TimeSpecAt,
 		bizId: req.BizId,
+		retryPolicy: req.RetryPolicy,
+		retryAttempt: req.RetryAttempt,
+		timeZone: req.TimeZone,
+		planSchedNextAt: req.PlanSchedNextAt,
+		misfirePolicy: req.MisfirePolicy,
+		misfireMaxCatchUpTimes: req.MisfireMaxCatchUpTimes,
+		misfireThresholdSec: req.MisfireThresholdSec,
+		trigger: req.Trigger,
+		concurrencyPolicy: req.ConcurrencyPolicy,
+		isPaused: req.IsPaused,
+		dispatchMode: req.DispatchMode,
+		broadcastSuccessRule: req.BroadcastSuccessRule,
+		shardTotal: req.ShardTotal,
+		routeStrategy: req.RouteStrategy,
+		labelSelector: req.LabelSelector,
+		failoverMaxAttempts: req.FailoverMaxAttempts,
 	}, nil
 }
 
@@ -358,6 +676,10 @@ func IsTaskFailed(status Status) bool {
 	return status == StatusFailed
 }
 
+func IsTaskSkipped(status Status) bool {
+	return status == StatusSkipped
+}
+
 type TaskLogType int
 
 const (
@@ -365,6 +687,7 @@ const (
 	TaskLogTypeStarted
 	TaskLogTypeCallback
 	TaskLogTypeConfirmed
+	TaskLogTypeSubRunsStarted
 )
 
 type TaskStartedLogDetail struct {
@@ -384,12 +707,18 @@ func NewTaskStartedLogDetail(task *Task) *TaskStartedLogDetail {
 type NewTaskCallbackLogDetailReq struct {
 	TaskId string `validate:"required"`
 	CallbackPath string
+	// 只有回调http节点时有请求方法,请求体格式和header,header中的密钥已经隐藏
+	CallbackMethod CallbackMethod
+	CallbackContentType CallbackContentType
+	CallbackHeaders map[string]string
 	RunTimes int
 	Route *TaskCallbackSrvRoute `validate:"required"`
 	RespRaw string
 	IsRunInAsync bool
 	TaskStatus Status
 	Err error
+	SubRunIdx int
+	Attempts []*TaskCallbackAttempt
 }
 
 func (r *NewTaskCallbackLogDetailReq) check() error {
@@ -403,30 +732,63 @@ func NewTaskCallbackLogDetail(req *NewTaskCallbackLogDetailReq) (*TaskCallbackLo
 	return &TaskCallbackLogDetail{
 		taskId: req.TaskId,
 		callbackPath: req.CallbackPath,
+		callbackMethod: req.CallbackMethod,
+		callbackContentType: req.CallbackContentType,
+		callbackHeaders: req.CallbackHeaders,
 		runTimes: req.RunTimes,
 		route: req.Route,
 		respRaw: req.RespRaw,
 		isRunInAsync: req.IsRunInAsync,
 		taskStatus: req.TaskStatus,
 		err: req.Err,
+		subRunIdx: req.SubRunIdx,
+		attempts: req.Attempts,
 	}, nil
 }
 
 type TaskCallbackLogDetail struct {
 	taskId string
 	callbackPath string
+	callbackMethod CallbackMethod
+	callbackContentType CallbackContentType
+	callbackHeaders map[string]string
 	runTimes int
 	route *TaskCallbackSrvRoute
 	respRaw string
 	isRunInAsync bool
 	taskStatus Status
 	err error
+	subRunIdx int
+	attempts []*TaskCallbackAttempt
+}
+
+// 广播或分片执行的第几个子执行,0代表不是子执行
+func (d *TaskCallbackLogDetail) GetSubRunIdx() int {
+	return d.subRunIdx
+}
+
+// 本次执行到目前为止所有的回调尝试,最后一个是本次回调
+func (d *TaskCallbackLogDetail) GetAttempts() []*TaskCallbackAttempt {
+	return d.attempts
 }
 
 func (d *TaskCallbackLogDetail) GetCallbackPath() string {
 	return d.callbackPath
 }
 
+func (d *TaskCallbackLogDetail) GetCallbackMethod() CallbackMethod {
+	return d.callbackMethod
+}
+
+func (d *TaskCallbackLogDetail) GetCallbackContentType() CallbackContentType {
+	return d.callbackContentType
+}
+
+// 回调请求带上的静态header,密钥header的值为******
+func (d *TaskCallbackLogDetail) GetCallbackHeaders() map[string]string {
+	return d.callbackHeaders
+}
+
 func (d *TaskCallbackLogDetail) IsRunInAsync() bool {
 	return d.isRunInAsync
 }
@@ -474,6 +836,7 @@ type TaskLog struct {
 	taskStartedDetail *TaskStartedLogDetail
 	taskCallbackDetail *TaskCallbackLogDetail
 	taskConfirmedDetail *TaskConfirmedLogDetail
+	taskSubRunsStartedDetail *TaskSubRunsStartedLogDetail
 }
 
 func (l *TaskLog) GetType() TaskLogType {
@@ -492,6 +855,10 @@ func (l *TaskLog) GetTaskConfirmedDetail() *TaskConfirmedLogDetail {
 	return l.taskConfirmedDetail
 }
 
+func (l *TaskLog) GetTaskSubRunsStartedDetail() *TaskSubRunsStartedLogDetail {
+	return l.taskSubRunsStartedDetail
+}
+
 func MustNewTaskLog(logType TaskLogType, detail any) *TaskLog {
 	taskLog := &TaskLog{logType: logType}
 	switch logType {
@@ -501,6 +868,8 @@ func MustNewTaskLog(logType TaskLogType, detail any) *TaskLog {
 		taskLog.taskCallbackDetail = detail.(*TaskCallbackLogDetail)
 	case TaskLogTypeConfirmed:
 		taskLog.taskConfirmedDetail = detail.(*TaskConfirmedLogDetail)
+	case TaskLogTypeSubRunsStarted:
+		taskLog.taskSubRunsStartedDetail = detail.(*TaskSubRunsStartedLogDetail)
 	default:
 		panic(any("unknown log type"))
 	}
@@ -529,6 +898,11 @@ func (l *TaskLogger) Log(ctx context.Context, log *TaskLog) error {
 			l.runtimeLogger.Error(ctx, err)
 			return err
 		}
+	case TaskLogTypeSubRunsStarted:
+		if err := l.repo.SaveTaskSubRunsStartedLog(ctx, log.taskSubRunsStartedDetail); err != nil {
+			l.runtimeLogger.Error(ctx, err)
+			return err
+		}
 	default:
 		err := errors.New("not support logging task behavior")
 		l.runtimeLogger.Error(ctx, err)
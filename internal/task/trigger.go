@@ -0,0 +1,78 @@
+package task
+
+type TriggerType int
+
+const (
+	// 按调度计划触发.触发队列中的该类型触发是调度计划触发的执行失败后的重试
+	TriggerTypeSched TriggerType = iota
+	// 由工作流上游节点触发
+	TriggerTypeWorkflow
+	// 调用接口手动触发
+	TriggerTypeManual
+)
+
+// 不走调度计划的一次性触发,由调度器从触发队列中捞出执行
+type TaskTrigger struct {
+	id string
+	triggerType TriggerType
+	planAt int64
+	attempt int
+	workflowRunId string
+	workflowNode string
+	arg string
+	hasArg bool
+}
+
+func (t *TaskTrigger) GetId() string {
+	return t.id
+}
+
+func (t *TaskTrigger) GetTriggerType() TriggerType {
+	return t.triggerType
+}
+
+func (t *TaskTrigger) GetPlanAt() int64 {
+	return t.planAt
+}
+
+// 第几次重试,0代表首次触发
+func (t *TaskTrigger) GetAttempt() int {
+	return t.attempt
+}
+
+func (t *TaskTrigger) GetWorkflowRunId() string {
+	return t.workflowRunId
+}
+
+func (t *TaskTrigger) GetWorkflowNode() string {
+	return t.workflowNode
+}
+
+// 本次执行覆盖任务参数的一次性参数,没有覆盖时返回false
+func (t *TaskTrigger) GetArg() (string, bool) {
+	return t.arg, t.hasArg
+}
+
+type NewTaskTriggerReq struct {
+	Id string
+	TriggerType TriggerType
+	PlanAt int64
+	Attempt int
+	WorkflowRunId string
+	WorkflowNode string
+	Arg string
+	HasArg bool
+}
+
+func NewTaskTrigger(req *NewTaskTriggerReq) *TaskTrigger {
+	return &TaskTrigger{
+		id: req.Id,
+		triggerType: req.TriggerType,
+		planAt: req.PlanAt,
+		attempt: req.Attempt,
+		workflowRunId: req.WorkflowRunId,
+		workflowNode: req.WorkflowNode,
+		arg: req.Arg,
+		hasArg: req.HasArg,
+	}
+}
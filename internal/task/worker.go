@@ -19,10 +19,19 @@ type WorkerEngine struct {
 	workerPoolSize      uint
 	sched               *Sched
 	callbackTaskSrvExec TaskCallbackSrvExec
+	callbackSrvLimiter  *CallbackSrvLimiter
+	// 回调服务已经接收,还在异步执行中的执行,确认或超时前一直占用回调服务的限流名额和节点的执行中计数.value为回调服务id
+	asyncRunsMu         sync.Mutex
+	asyncRuns           map[asyncCallbackRunKey]string
 	isPaused            atomic.Bool
 	exitWorkerWait      sync.WaitGroup
 }
 
+type asyncCallbackRunKey struct {
+	taskId string
+	runTimes int
+}
+
 func NewWorkerEngine(workerPoolSize uint, sched *Sched, callbackTaskSrvExec TaskCallbackSrvExec) *WorkerEngine {
 	if workerPoolSize <= 0 {
 		workerPoolSize = DefaultWorkerPoolSize
@@ -32,11 +41,14 @@ func NewWorkerEngine(workerPoolSize uint, sched *Sched, callbackTaskSrvExec Task
 		workerPoolSize: workerPoolSize,
 		sched: sched,
 		callbackTaskSrvExec: callbackTaskSrvExec,
+		callbackSrvLimiter: NewCallbackSrvLimiter(),
+		asyncRuns: map[asyncCallbackRunKey]string{},
 	}
 }
 
 func (e *WorkerEngine) Run(ctx context.Context) {
 	e.createWorkerPool(ctx)
+	go e.releaseEndedAsyncCallbacks(ctx)
 	e.sched.run(ctx)
 }
 
@@ -102,6 +114,17 @@ func (e *WorkerEngine) runWorker(ctx context.Context, workerId uint) {
 			return
 		}
 
+		// 超过回调服务的限流时不锁定任务,任务保持到期状态,下一轮调度再执行
+		if !e.callbackSrvLimiter.TryAcquire(task.callbackSrv) {
+			logger.MustGetSysLogger().Infof(
+				ctx,
+				"task(id:%s) callback server(name:%s) reached limit, defer to next round",
+				task.id, task.callbackSrv.name,
+			)
+			e.sched.deferFire(task)
+			continue
+		}
+
 		logger.MustGetSysLogger().Infof(ctx, "worker(id:%d) run task(id:%s name:%s)", workerId, task.id, task.name)
 
 		now := time.Now()
@@ -109,18 +132,34 @@ func (e *WorkerEngine) runWorker(ctx context.Context, workerId uint) {
 		locked, err := e.sched.lockTaskForRun(ctx, task)
 		if err != nil {
 			logger.MustGetSysLogger().Error(ctx, err)
+			e.callbackSrvLimiter.Cancel(task.callbackSrv)
 			continue
 		}
 
 		if !locked {
-			logger.MustGetSysLogger().Warnf(ctx, "worker(id:%d) lock task(id:%s) failed", workerId, task.id)
+			// 排队的触发在lockTaskForRun中已经记录,等下一轮调度
+			if !task.schedDecision.IsQueued() {
+				logger.MustGetSysLogger().Warnf(ctx, "worker(id:%d) lock task(id:%s) failed", workerId, task.id)
+			}
+			e.callbackSrvLimiter.Cancel(task.callbackSrv)
+			continue
+		}
+
+		if task.schedDecision.IsSkipped() {
+			logger.MustGetSysLogger().Infof(ctx, "worker(id:%d) skip misfired task(id:%s name:%s)", workerId, task.id, task.name)
+			e.callbackSrvLimiter.Cancel(task.callbackSrv)
 			continue
 		}
 
 		taskResp, err := task.run(ctx, e.callbackTaskSrvExec)
+		if err == nil && taskResp.IsRunInAsync() && taskResp.GetTaskStatus() == StatusRunning {
+			e.holdAsyncRun(task)
+		} else {
+			e.callbackSrvLimiter.Release(task.callbackSrv)
+		}
 		if err != nil {
 			logger.MustGetSysLogger().Error(ctx, err)
-			taskResp, err = newInternalErrTaskResp(task.id, err, now.Unix())
+			taskResp, err = newInternalErrTaskResp(task.id, task.runTimes, err, now.Unix())
 			if err != nil {
 				logger.MustGetSysLogger().Error(ctx, err)
 				continue
@@ -139,3 +178,96 @@ func (e *WorkerEngine) runWorker(ctx context.Context, workerId uint) {
 		)
 	}
 }
+
+func (e *WorkerEngine) holdAsyncRun(task *Task) {
+	e.asyncRunsMu.Lock()
+	defer e.asyncRunsMu.Unlock()
+	e.asyncRuns[asyncCallbackRunKey{taskId: task.id, runTimes: task.runTimes}] = task.callbackSrv.GetId()
+}
+
+func (e *WorkerEngine) getAsyncRunKeys() []asyncCallbackRunKey {
+	e.asyncRunsMu.Lock()
+	defer e.asyncRunsMu.Unlock()
+	var keys []asyncCallbackRunKey
+	for key := range e.asyncRuns {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (e *WorkerEngine) releaseAsyncRun(key asyncCallbackRunKey) {
+	e.asyncRunsMu.Lock()
+	srvId, ok := e.asyncRuns[key]
+	delete(e.asyncRuns, key)
+	e.asyncRunsMu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	e.callbackSrvLimiter.releaseSrv(srvId)
+	e.callbackTaskSrvExec.ReleaseRun(key.taskId, key.runTimes)
+}
+
+// 成为主节点时把之前的主节点发起的还没结束的执行当作异步执行占用名额,结束后再释放
+func (e *WorkerEngine) restoreAsyncRuns(runningSrvIds map[asyncCallbackRunKey]string) {
+	e.asyncRunsMu.Lock()
+	defer e.asyncRunsMu.Unlock()
+	for key, srvId := range runningSrvIds {
+		if _, ok := e.asyncRuns[key]; ok {
+			continue
+		}
+		e.asyncRuns[key] = srvId
+		e.callbackSrvLimiter.restore(srvId)
+	}
+}
+
+// 异步执行确认或超时后才释放回调服务的进行中名额和节点的执行中计数.确认可能由任意节点写入,
+// 所以每秒批量查询一次执行中的执行,不在其中的已经结束.成为主节点时按执行中的执行恢复之前的主节点占用的名额
+func (e *WorkerEngine) releaseEndedAsyncCallbacks(ctx context.Context) {
+	var (
+		traceModule = "task_limiter"
+		origCtxTraceId string
+	)
+	if traceCtx, ok := ctx.(*simpletracectx.Context); ok {
+		origCtxTraceId = traceCtx.GetTraceId()
+	}
+
+	var isRestored bool
+	for !e.isPaused.Load() {
+		time.Sleep(time.Second)
+
+		// 只有主节点发起回调,不是主节点时等重新成为主节点后再核对
+		if !e.sched.elect.IsMaster() {
+			isRestored = false
+			continue
+		}
+
+		ctx = contxt.NewWithTrace(traceModule, context.TODO(), traceModule + "_" + origCtxTraceId + "." + simpletrace.NewTraceId(), "")
+
+		// 先取出已占用的执行再查询,避免查询期间新占用的执行被当作已经结束
+		heldKeys := e.getAsyncRunKeys()
+		runs, err := e.sched.taskRepo.RunningRuns(ctx)
+		if err != nil {
+			logger.MustGetSysLogger().Error(ctx, err)
+			continue
+		}
+
+		runningSrvIds := make(map[asyncCallbackRunKey]string, len(runs))
+		for _, run := range runs {
+			runningSrvIds[asyncCallbackRunKey{taskId: run.GetTaskId(), runTimes: run.GetRunTimes()}] = run.GetCallbackSrvId()
+		}
+
+		// 任务或执行记录已经删除时不会再有确认,同样释放
+		for _, key := range heldKeys {
+			if _, ok := runningSrvIds[key]; !ok {
+				e.releaseAsyncRun(key)
+			}
+		}
+
+		if !isRestored {
+			e.restoreAsyncRuns(runningSrvIds)
+			isRestored = true
+		}
+	}
+}
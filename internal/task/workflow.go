@@ -0,0 +1,365 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-playground/validator"
+)
+
+type WorkflowEdgeCondition int
+
+const (
+	WorkflowEdgeConditionNil WorkflowEdgeCondition = iota
+	// 上游成功才触发下游
+	WorkflowEdgeConditionOnSuccess
+	// 上游失败(重试耗尽)才触发下游
+	WorkflowEdgeConditionOnFailure
+	// 上游结束(成功,失败或被跳过)就触发下游
+	WorkflowEdgeConditionAlways
+)
+
+type WorkflowNode struct {
+	name string
+	taskId string
+}
+
+func (n *WorkflowNode) GetName() string {
+	return n.name
+}
+
+func (n *WorkflowNode) GetTaskId() string {
+	return n.taskId
+}
+
+func NewWorkflowNode(name, taskId string) *WorkflowNode {
+	return &WorkflowNode{
+		name: name,
+		taskId: taskId,
+	}
+}
+
+type WorkflowEdge struct {
+	from string
+	to string
+	condition WorkflowEdgeCondition
+}
+
+func (e *WorkflowEdge) GetFrom() string {
+	return e.from
+}
+
+func (e *WorkflowEdge) GetTo() string {
+	return e.to
+}
+
+func (e *WorkflowEdge) GetCondition() WorkflowEdgeCondition {
+	return e.condition
+}
+
+// 上游节点的状态是否满足边的触发条件
+func (e *WorkflowEdge) isSatisfied(fromStatus Status) bool {
+	switch e.condition {
+	case WorkflowEdgeConditionOnSuccess:
+		return IsTaskSuccess(fromStatus)
+	case WorkflowEdgeConditionOnFailure:
+		return IsTaskFailed(fromStatus)
+	case WorkflowEdgeConditionAlways:
+		return isWorkflowNodeEnded(fromStatus)
+	}
+	return false
+}
+
+func NewWorkflowEdge(from, to string, condition WorkflowEdgeCondition) *WorkflowEdge {
+	return &WorkflowEdge{
+		from: from,
+		to: to,
+		condition: condition,
+	}
+}
+
+type Workflow struct {
+	id string
+	name string
+	nodes []*WorkflowNode
+	edges []*WorkflowEdge
+}
+
+func (w *Workflow) GetId() string {
+	return w.id
+}
+
+func (w *Workflow) GetName() string {
+	return w.name
+}
+
+func (w *Workflow) GetNodes() []*WorkflowNode {
+	return w.nodes
+}
+
+func (w *Workflow) GetEdges() []*WorkflowEdge {
+	return w.edges
+}
+
+func (w *Workflow) GetNode(name string) *WorkflowNode {
+	for _, node := range w.nodes {
+		if node.name == name {
+			return node
+		}
+	}
+	return nil
+}
+
+// 检查节点名唯一,边引用的节点存在并且没有环,返回拓扑序
+func (w *Workflow) sortNodes() ([]*WorkflowNode, error) {
+	nodeMap := make(map[string]*WorkflowNode)
+	for _, node := range w.nodes {
+		if node.name == "" {
+			return nil, errors.New("workflow node name is empty")
+		}
+		if _, ok := nodeMap[node.name]; ok {
+			return nil, fmt.Errorf("workflow node(name:%s) is duplicated", node.name)
+		}
+		nodeMap[node.name] = node
+	}
+
+	inDegrees := make(map[string]int)
+	downstreams := make(map[string][]string)
+	for _, edge := range w.edges {
+		if _, ok := nodeMap[edge.from]; !ok {
+			return nil, fmt.Errorf("workflow edge from unknown node(name:%s)", edge.from)
+		}
+		if _, ok := nodeMap[edge.to]; !ok {
+			return nil, fmt.Errorf("workflow edge to unknown node(name:%s)", edge.to)
+		}
+		switch edge.condition {
+		case WorkflowEdgeConditionOnSuccess, WorkflowEdgeConditionOnFailure, WorkflowEdgeConditionAlways:
+		default:
+			return nil, fmt.Errorf("workflow edge(from:%s, to:%s) condition is invalid", edge.from, edge.to)
+		}
+		inDegrees[edge.to]++
+		downstreams[edge.from] = append(downstreams[edge.from], edge.to)
+	}
+
+	var queue, sorted []*WorkflowNode
+	for _, node := range w.nodes {
+		if inDegrees[node.name] == 0 {
+			queue = append(queue, node)
+		}
+	}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		sorted = append(sorted, node)
+		for _, to := range downstreams[node.name] {
+			if inDegrees[to]--; inDegrees[to] == 0 {
+				queue = append(queue, nodeMap[to])
+			}
+		}
+	}
+
+	if len(sorted) != len(w.nodes) {
+		return nil, errors.New("workflow has cycle")
+	}
+
+	return sorted, nil
+}
+
+// 根据当前各节点状态推进工作流:上游都结束后,所有入边条件都满足的节点可以触发,否则跳过.
+// 跳过会继续向下游传递,返回本次可以触发的节点和被跳过的节点
+func (w *Workflow) Advance(nodeStatuses map[string]Status) (readyNodes []*WorkflowNode, skippedNodes []*WorkflowNode, err error) {
+	sorted, err := w.sortNodes()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	upstreamEdges := make(map[string][]*WorkflowEdge)
+	for _, edge := range w.edges {
+		upstreamEdges[edge.to] = append(upstreamEdges[edge.to], edge)
+	}
+
+	statuses := make(map[string]Status, len(nodeStatuses))
+	for name, status := range nodeStatuses {
+		statuses[name] = status
+	}
+
+	for _, node := range sorted {
+		if statuses[node.name] != StatusNil {
+			continue
+		}
+
+		var (
+			isAllUpstreamEnded = true
+			isAllSatisfied = true
+		)
+		for _, edge := range upstreamEdges[node.name] {
+			fromStatus := statuses[edge.from]
+			if !isWorkflowNodeEnded(fromStatus) {
+				isAllUpstreamEnded = false
+				break
+			}
+			if !edge.isSatisfied(fromStatus) {
+				isAllSatisfied = false
+			}
+		}
+
+		if !isAllUpstreamEnded {
+			continue
+		}
+
+		if isAllSatisfied {
+			statuses[node.name] = StatusRunning
+			readyNodes = append(readyNodes, node)
+		} else {
+			statuses[node.name] = StatusSkipped
+			skippedNodes = append(skippedNodes, node)
+		}
+	}
+
+	return readyNodes, skippedNodes, nil
+}
+
+type NewWorkflowReq struct {
+	Id string
+	Name string `validate:"required"`
+	Nodes []*WorkflowNode `validate:"required"`
+	Edges []*WorkflowEdge
+}
+
+func NewWorkflow(req *NewWorkflowReq) (*Workflow, error) {
+	if err := validator.New().Struct(req); err != nil {
+		return nil, err
+	}
+	workflow := &Workflow{
+		id: req.Id,
+		name: req.Name,
+		nodes: req.Nodes,
+		edges: req.Edges,
+	}
+	if _, err := workflow.sortNodes(); err != nil {
+		return nil, err
+	}
+	return workflow, nil
+}
+
+func isWorkflowNodeEnded(status Status) bool {
+	return IsTaskSuccess(status) || IsTaskFailed(status) || IsTaskSkipped(status)
+}
+
+type WorkflowNodeRun struct {
+	nodeName string
+	taskId string
+	status Status
+	taskRunTimes int
+}
+
+func (r *WorkflowNodeRun) GetNodeName() string {
+	return r.nodeName
+}
+
+func (r *WorkflowNodeRun) GetTaskId() string {
+	return r.taskId
+}
+
+// StatusNil代表还没触发
+func (r *WorkflowNodeRun) GetStatus() Status {
+	return r.status
+}
+
+// 节点结束时对应任务的执行次数,未结束为0
+func (r *WorkflowNodeRun) GetTaskRunTimes() int {
+	return r.taskRunTimes
+}
+
+func NewWorkflowNodeRun(nodeName, taskId string, status Status, taskRunTimes int) *WorkflowNodeRun {
+	return &WorkflowNodeRun{
+		nodeName: nodeName,
+		taskId: taskId,
+		status: status,
+		taskRunTimes: taskRunTimes,
+	}
+}
+
+type WorkflowRun struct {
+	id string
+	workflow *Workflow
+	status Status
+	nodeRuns []*WorkflowNodeRun
+	startedAt int64
+	endedAt int64
+}
+
+func (r *WorkflowRun) GetId() string {
+	return r.id
+}
+
+// 启动时的工作流定义快照
+func (r *WorkflowRun) GetWorkflow() *Workflow {
+	return r.workflow
+}
+
+func (r *WorkflowRun) GetStatus() Status {
+	return r.status
+}
+
+func (r *WorkflowRun) GetNodeRuns() []*WorkflowNodeRun {
+	return r.nodeRuns
+}
+
+func (r *WorkflowRun) GetStartedAt() int64 {
+	return r.startedAt
+}
+
+func (r *WorkflowRun) GetEndedAt() int64 {
+	return r.endedAt
+}
+
+type NewWorkflowRunReq struct {
+	Id string
+	Workflow *Workflow `validate:"required"`
+	Status Status
+	NodeRuns []*WorkflowNodeRun
+	StartedAt int64
+	EndedAt int64
+}
+
+func NewWorkflowRun(req *NewWorkflowRunReq) (*WorkflowRun, error) {
+	if err := validator.New().Struct(req); err != nil {
+		return nil, err
+	}
+	return &WorkflowRun{
+		id: req.Id,
+		workflow: req.Workflow,
+		status: req.Status,
+		nodeRuns: req.NodeRuns,
+		startedAt: req.StartedAt,
+		endedAt: req.EndedAt,
+	}, nil
+}
+
+// 所有节点结束后,有节点失败并且失败没有被处理则工作流失败,否则成功.
+// 失败节点的OnFailure下游被触发执行(没有被跳过)算作失败已经被处理
+func (w *Workflow) GetRunStatus(nodeStatuses map[string]Status) Status {
+	for _, nodeStatus := range nodeStatuses {
+		if !isWorkflowNodeEnded(nodeStatus) {
+			return StatusRunning
+		}
+	}
+
+	handledNodes := make(map[string]bool)
+	for _, edge := range w.edges {
+		if edge.condition != WorkflowEdgeConditionOnFailure {
+			continue
+		}
+		if toStatus := nodeStatuses[edge.to]; toStatus != StatusNil && !IsTaskSkipped(toStatus) {
+			handledNodes[edge.from] = true
+		}
+	}
+
+	for name, nodeStatus := range nodeStatuses {
+		if IsTaskFailed(nodeStatus) && !handledNodes[name] {
+			return StatusFailed
+		}
+	}
+
+	return StatusSuccess
+}
@@ -0,0 +1,161 @@
+package task
+
+import (
+	"testing"
+)
+
+func newTestWorkflow(t *testing.T, edges ...*WorkflowEdge) *Workflow {
+	workflow, err := NewWorkflow(&NewWorkflowReq{
+		Name: "test",
+		Nodes: []*WorkflowNode{
+			NewWorkflowNode("a", "1"),
+			NewWorkflowNode("b", "2"),
+			NewWorkflowNode("c", "3"),
+			NewWorkflowNode("d", "4"),
+		},
+		Edges: edges,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return workflow
+}
+
+func getNodeNames(nodes []*WorkflowNode) []string {
+	var names []string
+	for _, node := range nodes {
+		names = append(names, node.GetName())
+	}
+	return names
+}
+
+func TestNewWorkflowCheck(t *testing.T) {
+	nodes := []*WorkflowNode{NewWorkflowNode("a", "1"), NewWorkflowNode("b", "2")}
+	cases := map[string][]*WorkflowEdge{
+		"cycle": {
+			NewWorkflowEdge("a", "b", WorkflowEdgeConditionOnSuccess),
+			NewWorkflowEdge("b", "a", WorkflowEdgeConditionOnSuccess),
+		},
+		"self loop": {NewWorkflowEdge("a", "a", WorkflowEdgeConditionAlways)},
+		"unknown node": {NewWorkflowEdge("a", "x", WorkflowEdgeConditionOnSuccess)},
+		"invalid condition": {NewWorkflowEdge("a", "b", WorkflowEdgeConditionNil)},
+	}
+	for name, edges := range cases {
+		if _, err := NewWorkflow(&NewWorkflowReq{Name: "test", Nodes: nodes, Edges: edges}); err == nil {
+			t.Errorf("%s: expect error", name)
+		}
+	}
+
+	_, err := NewWorkflow(&NewWorkflowReq{
+		Name: "test",
+		Nodes: []*WorkflowNode{NewWorkflowNode("a", "1"), NewWorkflowNode("a", "2")},
+	})
+	if err == nil {
+		t.Error("duplicated node name: expect error")
+	}
+}
+
+func TestWorkflowAdvance(t *testing.T) {
+	// a -成功-> b, a -失败-> c, b,c -结束-> d
+	workflow := newTestWorkflow(
+		t,
+		NewWorkflowEdge("a", "b", WorkflowEdgeConditionOnSuccess),
+		NewWorkflowEdge("a", "c", WorkflowEdgeConditionOnFailure),
+		NewWorkflowEdge("b", "d", WorkflowEdgeConditionAlways),
+		NewWorkflowEdge("c", "d", WorkflowEdgeConditionAlways),
+	)
+
+	statuses := map[string]Status{}
+	readyNodes, skippedNodes, err := workflow.Advance(statuses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := getNodeNames(readyNodes); len(names) != 1 || names[0] != "a" || len(skippedNodes) != 0 {
+		t.Fatalf("expect only a ready, got ready %v skipped %v", names, getNodeNames(skippedNodes))
+	}
+
+	// 上游还在执行,下游不会被触发
+	statuses["a"] = StatusRunning
+	if readyNodes, skippedNodes, err = workflow.Advance(statuses); err != nil {
+		t.Fatal(err)
+	}
+	if len(readyNodes) != 0 || len(skippedNodes) != 0 {
+		t.Fatalf("expect nothing, got ready %v skipped %v", getNodeNames(readyNodes), getNodeNames(skippedNodes))
+	}
+
+	statuses["a"] = StatusSuccess
+	if readyNodes, skippedNodes, err = workflow.Advance(statuses); err != nil {
+		t.Fatal(err)
+	}
+	if names := getNodeNames(readyNodes); len(names) != 1 || names[0] != "b" {
+		t.Fatalf("expect b ready, got %v", names)
+	}
+	if names := getNodeNames(skippedNodes); len(names) != 1 || names[0] != "c" {
+		t.Fatalf("expect c skipped, got %v", names)
+	}
+
+	statuses["b"], statuses["c"] = StatusFailed, StatusSkipped
+	if readyNodes, skippedNodes, err = workflow.Advance(statuses); err != nil {
+		t.Fatal(err)
+	}
+	if names := getNodeNames(readyNodes); len(names) != 1 || names[0] != "d" || len(skippedNodes) != 0 {
+		t.Fatalf("expect d ready, got ready %v skipped %v", names, getNodeNames(skippedNodes))
+	}
+}
+
+func TestWorkflowAdvanceSkipPropagation(t *testing.T) {
+	workflow := newTestWorkflow(
+		t,
+		NewWorkflowEdge("a", "b", WorkflowEdgeConditionOnSuccess),
+		NewWorkflowEdge("b", "c", WorkflowEdgeConditionOnSuccess),
+		NewWorkflowEdge("c", "d", WorkflowEdgeConditionAlways),
+	)
+
+	readyNodes, skippedNodes, err := workflow.Advance(map[string]Status{"a": StatusFailed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := getNodeNames(skippedNodes); len(names) != 2 || names[0] != "b" || names[1] != "c" {
+		t.Fatalf("expect b,c skipped, got %v", names)
+	}
+	if names := getNodeNames(readyNodes); len(names) != 1 || names[0] != "d" {
+		t.Fatalf("expect d ready, got %v", names)
+	}
+}
+
+func TestWorkflowGetRunStatus(t *testing.T) {
+	workflow := newTestWorkflow(t, NewWorkflowEdge("a", "b", WorkflowEdgeConditionOnSuccess))
+	if status := workflow.GetRunStatus(map[string]Status{"a": StatusSuccess, "b": StatusRunning}); status != StatusRunning {
+		t.Errorf("expect running, got %d", status)
+	}
+	if status := workflow.GetRunStatus(map[string]Status{"a": StatusSuccess, "b": StatusNil}); status != StatusRunning {
+		t.Errorf("expect running, got %d", status)
+	}
+	if status := workflow.GetRunStatus(map[string]Status{"a": StatusFailed, "b": StatusSkipped}); status != StatusFailed {
+		t.Errorf("expect failed, got %d", status)
+	}
+	if status := workflow.GetRunStatus(map[string]Status{"a": StatusSuccess, "b": StatusSkipped}); status != StatusSuccess {
+		t.Errorf("expect success, got %d", status)
+	}
+}
+
+func TestWorkflowGetRunStatusHandledFailure(t *testing.T) {
+	workflow := newTestWorkflow(
+		t,
+		NewWorkflowEdge("a", "b", WorkflowEdgeConditionOnSuccess),
+		NewWorkflowEdge("a", "c", WorkflowEdgeConditionOnFailure),
+		NewWorkflowEdge("b", "d", WorkflowEdgeConditionOnFailure),
+	)
+
+	// a失败由c处理
+	statuses := map[string]Status{"a": StatusFailed, "b": StatusSkipped, "c": StatusSuccess, "d": StatusSkipped}
+	if status := workflow.GetRunStatus(statuses); status != StatusSuccess {
+		t.Errorf("expect success, got %d", status)
+	}
+
+	// 处理分支本身失败
+	statuses["c"] = StatusFailed
+	if status := workflow.GetRunStatus(statuses); status != StatusFailed {
+		t.Errorf("expect failed, got %d", status)
+	}
+}
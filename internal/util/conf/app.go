@@ -32,6 +32,53 @@ type ApiSrvConf struct {
 	*HttpApiSrvConf `json:"http"`
 }
 
+// 回调节点熔断配置,不配置时使用默认值
+type CircuitBreakerConf struct {
+	WindowSize int `json:"window_size"`
+	MinCalls int `json:"min_calls"`
+	FailureRateThreshold float64 `json:"failure_rate_threshold"`
+	CoolDownSec int `json:"cool_down_sec"`
+}
+
+// 允许执行的本地命令,key为命令名称(任务的回调路径),value为命令行.
+// 命令行按空白拆分参数,不经过shell,包含引号、转义或shell操作符时启动失败
+type LocalCmdConf struct {
+	Cmds map[string]string `json:"cmds"`
+	// 标准输出和标准错误各自最多记录到任务日志的字节数,默认64KB
+	OutputLimitBytes int `json:"output_limit_bytes"`
+}
+
+// 回调http节点的连接池配置,不配置或者为0时使用默认值
+type CallbackHttpConf struct {
+	MaxIdleConns int `json:"max_idle_conns"`
+	MaxIdleConnsPerHost int `json:"max_idle_conns_per_host"`
+	IdleConnTimeoutSec int `json:"idle_conn_timeout_sec"`
+	DialTimeoutSec int `json:"dial_timeout_sec"`
+	TlsHandshakeTimeoutSec int `json:"tls_handshake_timeout_sec"`
+	ResponseHeaderTimeoutSec int `json:"response_header_timeout_sec"`
+	MaxRespBodyBytes int64 `json:"max_resp_body_bytes"`
+}
+
+// 回调https节点的tls配置,文件都是pem格式
+type TlsConf struct {
+	// 为空时使用系统根证书
+	CaFile string `json:"ca_file"`
+	// 都不为空时开启双向认证
+	CertFile string `json:"cert_file"`
+	KeyFile string `json:"key_file"`
+	// 不为空时用于校验节点证书,而不是节点host
+	ServerName string `json:"server_name"`
+	// 1.0,1.1,1.2或1.3
+	MinVersion string `json:"min_version"`
+}
+
+type CallbackTlsConf struct {
+	// 所有服务默认的配置
+	Default *TlsConf `json:"default"`
+	// 按服务名称单独指定的配置,不会和默认配置合并
+	Srvs map[string]*TlsConf `json:"srvs"`
+}
+
 type AppConf struct {
 	ClusterName               string `json:"cluster_name"`
 	TaskWorkerPoolSize        uint `json:"task_worker_pool_size"`
@@ -42,5 +89,15 @@ type AppConf struct {
 	HealthCheckWorkerPoolSize uint `json:"health_check_worker_pool_size"`
 	LoggerConf                *logger.Conf `json:"log"`
 	*ApiSrvConf               `json:"api_server"`
+	CircuitBreakerConf        *CircuitBreakerConf `json:"circuit_breaker"`
+	LocalCmdConf              *LocalCmdConf `json:"local_cmd"`
+	CallbackTlsConf           *CallbackTlsConf `json:"callback_tls"`
+	CallbackHttpConf          *CallbackHttpConf `json:"callback_http"`
+	// 上报过进度的异步执行超过多少秒没有再上报时判定为超时失败,0代表不检查
+	TaskHeartbeatTimeoutSec   int `json:"task_heartbeat_timeout_sec"`
+	// 调度计划时间落后超过多少秒算错过触发,按任务的misfire_policy处理,0代表使用默认值5
+	MisfireToleranceSec       int `json:"misfire_tolerance_sec"`
+	// 加密存储服务签名密钥和密钥header的key,不配置时不能注册带secret或secret_headers的服务.修改后已加密的数据无法解密
+	SecretKey                 string `json:"secret_key"`
 }
 
@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"fmt"
 	"github.com/995933447/autoelect"
 	electfactory "github.com/995933447/autoelect/factory"
 	"github.com/995933447/confloader"
@@ -12,6 +14,7 @@ import (
 	"github.com/995933447/easytask/internal/task/impl/callback"
 	"github.com/995933447/easytask/internal/task/impl/repo/mysql"
 	"github.com/995933447/easytask/internal/util/conf"
+	"github.com/995933447/easytask/internal/util/crypt"
 	"github.com/995933447/easytask/internal/util/logger"
 	"github.com/995933447/easytask/internal/util/runtime"
 	"github.com/995933447/easytask/pkg/contxt"
@@ -19,10 +22,12 @@ import (
 	"github.com/995933447/redisgroup"
 	"github.com/995933447/std-go/scan"
 	"github.com/etcd-io/etcd/client"
+	"github.com/go-redis/redis/v8"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
+	_ "time/tzdata"
 )
 
 func main() {
@@ -52,14 +57,21 @@ func main() {
 		}
 	}()
 
-	taskRepo, taskCallbackSrvRepo, taskLogRepo, err := NewRepos(ctx, cfg)
+	taskRepo, taskCallbackSrvRepo, taskLogRepo, workflowRepo, err := NewRepos(ctx, cfg)
 	if err != nil {
 		panic(any(err))
 	}
 
-	reg := runRegistry(ctx, cfg, taskLogRepo, taskCallbackSrvRepo, elect)
+	circuitBreaker := newRouteCircuitBreaker(cfg)
 
-	workerEngine := runTaskWorker(ctx, cfg, taskLogRepo, taskRepo, elect)
+	callbackExec, err := newCallbackExec(cfg, taskLogRepo, circuitBreaker)
+	if err != nil {
+		panic(any(err))
+	}
+
+	reg := runRegistry(ctx, cfg, taskCallbackSrvRepo, elect, callbackExec, circuitBreaker)
+
+	workerEngine := runTaskWorker(ctx, cfg, taskRepo, elect, callbackExec)
 
 	sysSignCh := make(chan os.Signal)
 	stopApiSrvSignCh := make(chan struct{})
@@ -83,7 +95,7 @@ func main() {
 	}()
 	signal.Notify(sysSignCh, syscall.SIGINT, syscall.SIGTERM)
 
-	if err = runHttpApiServer(ctx, cfg, taskRepo, reg, stopApiSrvSignCh, stoppedApiSrvSignCh); err != nil {
+	if err = runHttpApiServer(ctx, cfg, taskRepo, workflowRepo, reg, circuitBreaker, callbackExec, stopApiSrvSignCh, stoppedApiSrvSignCh); err != nil {
 		panic(any(err))
 	}
 }
@@ -158,52 +170,163 @@ func startElect(ctx context.Context, cfg *conf.AppConf) (autoelect.AutoElection,
 	return elect, doElectErrCh, nil
 }
 
-func runRegistry(ctx context.Context, cfg *conf.AppConf, taskLogRepo task.TaskLogRepo, taskCallbackSrvRepo task.TaskCallbackSrvRepo, elect autoelect.AutoElection) *registry.Registry {
+func newRouteCircuitBreaker(cfg *conf.AppConf) *task.RouteCircuitBreaker {
+	if cfg.CircuitBreakerConf == nil {
+		return task.NewRouteCircuitBreaker(0, 0, 0, 0)
+	}
+	return task.NewRouteCircuitBreaker(
+		cfg.CircuitBreakerConf.WindowSize,
+		cfg.CircuitBreakerConf.MinCalls,
+		cfg.CircuitBreakerConf.FailureRateThreshold,
+		cfg.CircuitBreakerConf.CoolDownSec,
+		)
+}
+
+// 按回调节点的协议选择回调方式
+func newCallbackExec(cfg *conf.AppConf, taskLogRepo task.TaskLogRepo, circuitBreaker *task.RouteCircuitBreaker) (*callback.Exec, error) {
+	// redis节点的任务投递到配置文件中的redis节点,和选主共用同一组redis
+	var redisClients []*redis.Client
+	if cfg.RedisConf != nil {
+		for _, nodeConf := range cfg.RedisConf.Nodes {
+			redisClients = append(redisClients, redis.NewClient(&redis.Options{
+				Addr: fmt.Sprintf("%s:%d", nodeConf.Host, nodeConf.Port),
+				Password: nodeConf.Password,
+			}))
+		}
+	}
+
+	// 没有配置时不允许执行任何本地命令
+	var (
+		localCmds map[string]string
+		localCmdOutputLimitBytes int
+	)
+	if cfg.LocalCmdConf != nil {
+		localCmds = cfg.LocalCmdConf.Cmds
+		localCmdOutputLimitBytes = cfg.LocalCmdConf.OutputLimitBytes
+	}
+	localCmdRouteExec, err := callback.NewLocalCmdRouteExec(localCmds, localCmdOutputLimitBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	httpRouteExec, err := newHttpRouteExec(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return callback.NewExec(
+		task.NewTaskLogger(taskLogRepo, logger.MustGetCallbackLogger()),
+		circuitBreaker,
+		map[string]callback.RouteExec{
+			callback.RouteSchemaHttp: httpRouteExec,
+			callback.RouteSchemaHttps: httpRouteExec,
+			callback.RouteSchemaGrpc: callback.NewGrpcRouteExec(),
+			callback.RouteSchemaRedis: callback.NewRedisStreamRouteExec(redisClients),
+			callback.RouteSchemaLocalCmd: localCmdRouteExec,
+		},
+		), nil
+}
+
+func newHttpRouteExec(cfg *conf.AppConf) (*callback.HttpRouteExec, error) {
+	var transportOpts *callback.HttpTransportOpts
+	if httpConf := cfg.CallbackHttpConf; httpConf != nil {
+		transportOpts = &callback.HttpTransportOpts{
+			MaxIdleConns: httpConf.MaxIdleConns,
+			MaxIdleConnsPerHost: httpConf.MaxIdleConnsPerHost,
+			IdleConnTimeout: time.Duration(httpConf.IdleConnTimeoutSec) * time.Second,
+			DialTimeout: time.Duration(httpConf.DialTimeoutSec) * time.Second,
+			TlsHandshakeTimeout: time.Duration(httpConf.TlsHandshakeTimeoutSec) * time.Second,
+			ResponseHeaderTimeout: time.Duration(httpConf.ResponseHeaderTimeoutSec) * time.Second,
+			MaxRespBodyBytes: httpConf.MaxRespBodyBytes,
+		}
+	}
+
+	if cfg.CallbackTlsConf == nil {
+		return callback.NewHttpRouteExec(transportOpts, nil, nil), nil
+	}
+
+	newTlsConfig := func(tlsConf *conf.TlsConf) (*tls.Config, error) {
+		return callback.NewTlsConfig(tlsConf.CaFile, tlsConf.CertFile, tlsConf.KeyFile, tlsConf.ServerName, tlsConf.MinVersion)
+	}
+
+	var (
+		defaultTlsConfig *tls.Config
+		srvTlsConfigs = map[string]*tls.Config{}
+		err error
+	)
+	if cfg.CallbackTlsConf.Default != nil {
+		if defaultTlsConfig, err = newTlsConfig(cfg.CallbackTlsConf.Default); err != nil {
+			return nil, err
+		}
+	}
+	for srvName, tlsConf := range cfg.CallbackTlsConf.Srvs {
+		if srvTlsConfigs[srvName], err = newTlsConfig(tlsConf); err != nil {
+			return nil, err
+		}
+	}
+
+	return callback.NewHttpRouteExec(transportOpts, defaultTlsConfig, srvTlsConfigs), nil
+}
+
+func runRegistry(ctx context.Context, cfg *conf.AppConf, taskCallbackSrvRepo task.TaskCallbackSrvRepo, elect autoelect.AutoElection, callbackExec task.TaskCallbackSrvExec, circuitBreaker *task.RouteCircuitBreaker) *registry.Registry {
 	reg := registry.NewRegistry(
 		cfg.HealthCheckWorkerPoolSize,
 		taskCallbackSrvRepo,
-		callback.NewHttpExec(task.NewTaskLogger(taskLogRepo, logger.MustGetCallbackLogger())),
+		callbackExec,
 		elect,
+		circuitBreaker,
 		)
 	go reg.Run(contxt.ChildOf(ctx))
 	return reg
 }
 
-func NewRepos(ctx context.Context, cfg *conf.AppConf) (task.TaskRepo, task.TaskCallbackSrvRepo, task.TaskLogRepo, error) {
+func NewRepos(ctx context.Context, cfg *conf.AppConf) (task.TaskRepo, task.TaskCallbackSrvRepo, task.TaskLogRepo, task.WorkflowRepo, error) {
 	var (
 		taskRepo            task.TaskRepo
 		taskCallbackSrvRepo task.TaskCallbackSrvRepo
 		taskLogRepo         task.TaskLogRepo
+		workflowRepo        task.WorkflowRepo
 		err                 error
 	)
 	if taskLogRepo, err = mysql.NewTaskLogRepo(ctx, cfg.MysqlConf.ConnDsn); err != nil {
 		logger.MustGetSysLogger().Error(ctx, err)
-		return nil, nil, nil, err
+		return nil, nil, nil, nil, err
+	}
+	var secretCipher *crypt.Cipher
+	if cfg.SecretKey != "" {
+		if secretCipher, err = crypt.NewCipher(cfg.SecretKey); err != nil {
+			logger.MustGetSysLogger().Error(ctx, err)
+			return nil, nil, nil, nil, err
+		}
+	}
+	if taskCallbackSrvRepo, err = mysql.NewTaskSrvRepo(ctx, cfg.MysqlConf.ConnDsn, secretCipher); err != nil {
+		logger.MustGetSysLogger().Error(ctx, err)
+		return nil, nil, nil, nil, err
 	}
-	if taskCallbackSrvRepo, err = mysql.NewTaskSrvRepo(ctx, cfg.MysqlConf.ConnDsn); err != nil {
+	if workflowRepo, err = mysql.NewWorkflowRepo(ctx, cfg.MysqlConf.ConnDsn); err != nil {
 		logger.MustGetSysLogger().Error(ctx, err)
-		return nil, nil, nil, err
+		return nil, nil, nil, nil, err
 	}
-	if taskRepo, err = mysql.NewTaskRepo(ctx, cfg.MysqlConf.ConnDsn, taskCallbackSrvRepo, taskLogRepo); err != nil {
+	if taskRepo, err = mysql.NewTaskRepo(ctx, cfg.MysqlConf.ConnDsn, taskCallbackSrvRepo, taskLogRepo, workflowRepo); err != nil {
 		logger.MustGetSysLogger().Error(ctx, err)
-		return nil, nil, nil, err
+		return nil, nil, nil, nil, err
 	}
-	return taskRepo, taskCallbackSrvRepo, taskLogRepo, nil
+	return taskRepo, taskCallbackSrvRepo, taskLogRepo, workflowRepo, nil
 }
 
-func runTaskWorker(ctx context.Context, cfg *conf.AppConf, taskLogRepo task.TaskLogRepo, taskRepo task.TaskRepo, elect autoelect.AutoElection) *task.WorkerEngine {
+func runTaskWorker(ctx context.Context, cfg *conf.AppConf, taskRepo task.TaskRepo, elect autoelect.AutoElection, callbackExec task.TaskCallbackSrvExec) *task.WorkerEngine {
 	engine := task.NewWorkerEngine(
 		cfg.TaskWorkerPoolSize,
-		task.NewSched(taskRepo, elect),
-		callback.NewHttpExec(task.NewTaskLogger(taskLogRepo, logger.MustGetCallbackLogger())),
+		task.NewSched(taskRepo, elect, cfg.TaskHeartbeatTimeoutSec, cfg.MisfireToleranceSec),
+		callbackExec,
 		)
 	go engine.Run(contxt.ChildOf(ctx))
 	return engine
 }
 
-func runHttpApiServer(ctx context.Context, cfg *conf.AppConf, taskRepo task.TaskRepo, reg *registry.Registry, stopSignCh, stoppedSignCh chan struct{}) error {
+func runHttpApiServer(ctx context.Context, cfg *conf.AppConf, taskRepo task.TaskRepo, workflowRepo task.WorkflowRepo, reg *registry.Registry, circuitBreaker *task.RouteCircuitBreaker, callbackExec task.TaskCallbackSrvExec, stopSignCh, stoppedSignCh chan struct{}) error {
 	router := apiserver.NewHttpRouter(cfg.ApiSrvConf.Host, cfg.ApiSrvConf.Port, cfg.PprofPort)
-	if err := router.RegisterBatch(ctx, getHttpApiRoutes(taskRepo, reg)); err != nil {
+	if err := router.RegisterBatch(ctx, getHttpApiRoutes(taskRepo, workflowRepo, reg, circuitBreaker, callbackExec)); err != nil {
 		logger.MustGetSysLogger().Error(ctx, err)
 		return err
 	}
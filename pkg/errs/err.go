@@ -12,6 +12,12 @@ const (
 	ErrCodeTaskNotFound = 10002
 	ErrCodeTaskCallbackSrvNotFound = 10003
 	ErrCodeServerStopped = 10004
+	ErrCodeWorkflowNotFound = 10005
+	ErrCodeWorkflowRunNotFound = 10006
+	ErrCodeTaskRunTimeout = 10007
+	ErrCodeTaskRunNotRunning = 10008
+	ErrCodeTaskRunCancelled = 10009
+	ErrCodeTaskRunNotFound = 10010
 )
 
 var errMap = map[ErrCode]string{
@@ -21,6 +27,12 @@ var errMap = map[ErrCode]string{
 	ErrCodeTaskNotFound: "task not found",
 	ErrCodeTaskCallbackSrvNotFound: "task callback server not found",
 	ErrCodeServerStopped: "task server is stopped",
+	ErrCodeWorkflowNotFound: "workflow not found",
+	ErrCodeWorkflowRunNotFound: "workflow run not found",
+	ErrCodeTaskRunTimeout: "task run has timed out",
+	ErrCodeTaskRunNotRunning: "task run is not running",
+	ErrCodeTaskRunCancelled: "task run has been cancelled",
+	ErrCodeTaskRunNotFound: "task run not found",
 }
 
 func GetErrMsg(code ErrCode) string {
@@ -0,0 +1,161 @@
+package rpc
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/995933447/easytask/pkg/rpc/proto/httpproto"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 默认允许请求时间戳与本地时间相差5分钟
+const defaultCallbackReplayWindow = 5 * time.Minute
+
+var (
+	ErrCallbackSignMissing = errors.New("callback request signature headers missing")
+	ErrCallbackSignExpired = errors.New("callback request timestamp out of replay window")
+	ErrCallbackSignInvalid = errors.New("callback request signature invalid")
+	ErrCallbackNonceReplayed = errors.New("callback request nonce replayed")
+)
+
+// 计算回调请求的签名,hex(HMAC-SHA256(secret, method + "\n" + path + "\n" + timestamp + "\n" + nonce + "\n" + body)).
+// 签名覆盖请求方法和路径,签过名的请求体不能被重放到其他接口
+func SignCallbackReq(secret, method, path, timestamp, nonce string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(method + "\n" + path + "\n" + timestamp + "\n" + nonce + "\n"))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func NewCallbackSignNonce() (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(nonce), nil
+}
+
+// 签名使用的请求路径,不包含query string,为空时按"/"处理,和服务端收到的路径一致
+func getCallbackSignPath(u *url.URL) string {
+	if path := u.EscapedPath(); path != "" {
+		return path
+	}
+	return "/"
+}
+
+// 给回调请求加上时间戳,随机串和签名header
+func SignCallbackHttpReq(req *http.Request, secret string, body []byte) error {
+	nonce, err := NewCallbackSignNonce()
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set(httpproto.HeaderSignTimestamp, timestamp)
+	req.Header.Set(httpproto.HeaderSignNonce, nonce)
+	req.Header.Set(httpproto.HeaderSignature, SignCallbackReq(secret, req.Method, getCallbackSignPath(req.URL), timestamp, nonce, body))
+	return nil
+}
+
+// 回调服务校验easytask请求签名,时间戳超出窗口或者窗口内重复的随机串都会被拒绝.
+// 随机串只记录在当前进程内存中,多个进程各自防重放
+type CallbackVerifier struct {
+	secret string
+	replayWindow time.Duration
+	mu sync.Mutex
+	// 随机串到过期时间
+	nonces map[string]time.Time
+	purgedAt time.Time
+	now func() time.Time
+}
+
+// replayWindow小于等于0时使用默认值5分钟
+func NewCallbackVerifier(secret string, replayWindow time.Duration) *CallbackVerifier {
+	if replayWindow <= 0 {
+		replayWindow = defaultCallbackReplayWindow
+	}
+	return &CallbackVerifier{
+		secret: secret,
+		replayWindow: replayWindow,
+		nonces: map[string]time.Time{},
+		now: time.Now,
+	}
+}
+
+// path为请求的原始路径(不包含query string),即req.URL.EscapedPath(),为空时传"/"
+func (v *CallbackVerifier) Verify(method, path string, header http.Header, body []byte) error {
+	var (
+		timestamp = header.Get(httpproto.HeaderSignTimestamp)
+		nonce = header.Get(httpproto.HeaderSignNonce)
+		signature = header.Get(httpproto.HeaderSignature)
+	)
+	if timestamp == "" || nonce == "" || signature == "" {
+		return ErrCallbackSignMissing
+	}
+
+	signedAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrCallbackSignInvalid
+	}
+
+	now := v.now()
+	if math.Abs(float64(now.Unix() - signedAt)) > v.replayWindow.Seconds() {
+		return ErrCallbackSignExpired
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(SignCallbackReq(v.secret, method, path, timestamp, nonce, body))) {
+		return ErrCallbackSignInvalid
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	// 过期的随机串每个窗口清理一次
+	if now.Sub(v.purgedAt) > v.replayWindow {
+		for n, expireAt := range v.nonces {
+			if now.After(expireAt) {
+				delete(v.nonces, n)
+			}
+		}
+		v.purgedAt = now
+	}
+
+	if expireAt, ok := v.nonces[nonce]; ok && !now.After(expireAt) {
+		return ErrCallbackNonceReplayed
+	}
+	// 时间戳在窗口内的请求才会通过校验,随机串记录到时间戳超出窗口为止
+	v.nonces[nonce] = time.Unix(signedAt, 0).Add(v.replayWindow)
+
+	return nil
+}
+
+// 读取并校验请求体,校验后请求体可以再次读取.GET请求校验的是query string,返回的也是query string
+func (v *CallbackVerifier) VerifyHttpReq(req *http.Request) ([]byte, error) {
+	if req.Method == http.MethodGet {
+		query := []byte(req.URL.RawQuery)
+		if err := v.Verify(req.Method, getCallbackSignPath(req.URL), req.Header, query); err != nil {
+			return nil, err
+		}
+		return query, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	if err = v.Verify(req.Method, getCallbackSignPath(req.URL), req.Header, body); err != nil {
+		return nil, err
+	}
+
+	return body, nil
+}
@@ -0,0 +1,65 @@
+package rpc
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCallbackVerifier(t *testing.T) {
+	body := []byte(`{"cmd":0,"task_id":"1"}`)
+	newReq := func(secret string) *http.Request {
+		req, err := http.NewRequest(http.MethodPost, "http://127.0.0.1/", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = SignCallbackHttpReq(req, secret, body); err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+
+	verifier := NewCallbackVerifier("secret", time.Minute)
+
+	req := newReq("secret")
+	readBody, err := verifier.VerifyHttpReq(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(readBody, body) {
+		t.Errorf("unexpected body %s", readBody)
+	}
+
+	if err = verifier.Verify(req.Method, req.URL.EscapedPath(), req.Header, body); err != ErrCallbackNonceReplayed {
+		t.Errorf("expect replayed nonce rejected, got %v", err)
+	}
+
+	if err = verifier.Verify(http.MethodPost, "/", newReq("secret").Header, []byte(`{"cmd":0,"task_id":"2"}`)); err != ErrCallbackSignInvalid {
+		t.Errorf("expect tampered body rejected, got %v", err)
+	}
+
+	// 签过名的请求体不能重放到其他接口或者换成其他请求方法
+	if err = verifier.Verify(http.MethodPost, "/other", newReq("secret").Header, body); err != ErrCallbackSignInvalid {
+		t.Errorf("expect other path rejected, got %v", err)
+	}
+	if err = verifier.Verify(http.MethodPut, "/", newReq("secret").Header, body); err != ErrCallbackSignInvalid {
+		t.Errorf("expect other method rejected, got %v", err)
+	}
+
+	if err = verifier.Verify(http.MethodPost, "/", newReq("other").Header, body); err != ErrCallbackSignInvalid {
+		t.Errorf("expect wrong secret rejected, got %v", err)
+	}
+
+	if err = verifier.Verify(http.MethodPost, "/", http.Header{}, body); err != ErrCallbackSignMissing {
+		t.Errorf("expect missing headers rejected, got %v", err)
+	}
+
+	expiredReq := newReq("secret")
+	verifier.now = func() time.Time {
+		return time.Now().Add(2 * time.Minute)
+	}
+	if err = verifier.Verify(http.MethodPost, "/", expiredReq.Header, body); err != ErrCallbackSignExpired {
+		t.Errorf("expect expired timestamp rejected, got %v", err)
+	}
+}
@@ -51,6 +51,33 @@ func (c *HttpCli) StopTask(ctx context.Context, req *httpproto.StopTaskReq, opts
 	return &resp, nil
 }
 
+func (c *HttpCli) PauseTask(ctx context.Context, req *httpproto.PauseTaskReq, opts ...HttpReqOpt) (*httpproto.PauseTaskResp, error) {
+	var resp httpproto.PauseTaskResp
+	err := c.post(contxt.New("api", ctx), httpproto.PauseTaskCmdPath, req, &resp, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *HttpCli) ResumeTask(ctx context.Context, req *httpproto.ResumeTaskReq, opts ...HttpReqOpt) (*httpproto.ResumeTaskResp, error) {
+	var resp httpproto.ResumeTaskResp
+	err := c.post(contxt.New("api", ctx), httpproto.ResumeTaskCmdPath, req, &resp, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *HttpCli) TriggerTask(ctx context.Context, req *httpproto.TriggerTaskReq, opts ...HttpReqOpt) (*httpproto.TriggerTaskResp, error) {
+	var resp httpproto.TriggerTaskResp
+	err := c.post(contxt.New("api", ctx), httpproto.TriggerTaskCmdPath, req, &resp, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
 func (c *HttpCli) ConfirmTask(ctx context.Context, req *httpproto.ConfirmTaskReq, opts ...HttpReqOpt) (*httpproto.ConfirmTaskResp, error) {
 	var resp httpproto.ConfirmTaskResp
 	err := c.post(contxt.New("api", ctx), httpproto.ConfirmTaskCmdPath, req, &resp, opts...)
@@ -60,6 +87,33 @@ func (c *HttpCli) ConfirmTask(ctx context.Context, req *httpproto.ConfirmTaskReq
 	return &resp, nil
 }
 
+func (c *HttpCli) CancelRun(ctx context.Context, req *httpproto.CancelRunReq, opts ...HttpReqOpt) (*httpproto.CancelRunResp, error) {
+	var resp httpproto.CancelRunResp
+	err := c.post(contxt.New("api", ctx), httpproto.CancelRunCmdPath, req, &resp, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *HttpCli) ReportTaskProgress(ctx context.Context, req *httpproto.ReportTaskProgressReq, opts ...HttpReqOpt) (*httpproto.ReportTaskProgressResp, error) {
+	var resp httpproto.ReportTaskProgressResp
+	err := c.post(contxt.New("api", ctx), httpproto.ReportTaskProgressCmdPath, req, &resp, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *HttpCli) GetTaskRun(ctx context.Context, req *httpproto.GetTaskRunReq, opts ...HttpReqOpt) (*httpproto.GetTaskRunResp, error) {
+	var resp httpproto.GetTaskRunResp
+	err := c.post(contxt.New("api", ctx), httpproto.GetTaskRunCmdPath, req, &resp, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
 func (c *HttpCli) RegisterTaskCallbackSrv(ctx context.Context, req *httpproto.RegisterTaskCallbackSrvReq, opts ...HttpReqOpt) (*httpproto.RegisterTaskCallbackSrvResp, error) {
 	var resp httpproto.RegisterTaskCallbackSrvResp
 	err := c.post(contxt.New("api", ctx), httpproto.RegisterTaskCallbackSrvCmdPath, req, &resp, opts...)
@@ -78,6 +132,42 @@ func (c *HttpCli) UnregisterTaskCallbackSrv(ctx context.Context, req *httpproto.
 	return &resp, nil
 }
 
+func (c *HttpCli) GetRouteCircuitBreakers(ctx context.Context, req *httpproto.GetRouteCircuitBreakersReq, opts ...HttpReqOpt) (*httpproto.GetRouteCircuitBreakersResp, error) {
+	var resp httpproto.GetRouteCircuitBreakersResp
+	err := c.post(contxt.New("api", ctx), httpproto.GetRouteCircuitBreakersCmdPath, req, &resp, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *HttpCli) AddWorkflow(ctx context.Context, req *httpproto.AddWorkflowReq, opts ...HttpReqOpt) (*httpproto.AddWorkflowResp, error) {
+	var resp httpproto.AddWorkflowResp
+	err := c.post(contxt.New("api", ctx), httpproto.AddWorkflowCmdPath, req, &resp, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *HttpCli) StartWorkflow(ctx context.Context, req *httpproto.StartWorkflowReq, opts ...HttpReqOpt) (*httpproto.StartWorkflowResp, error) {
+	var resp httpproto.StartWorkflowResp
+	err := c.post(contxt.New("api", ctx), httpproto.StartWorkflowCmdPath, req, &resp, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *HttpCli) GetWorkflowRun(ctx context.Context, req *httpproto.GetWorkflowRunReq, opts ...HttpReqOpt) (*httpproto.GetWorkflowRunResp, error) {
+	var resp httpproto.GetWorkflowRunResp
+	err := c.post(contxt.New("api", ctx), httpproto.GetWorkflowRunCmdPath, req, &resp, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
 func (c *HttpCli) post(ctx context.Context, path string, req, resp any, opts ...HttpReqOpt) error {
 	httpReqBody, err := json.Marshal(req)
 	if err != nil {
@@ -0,0 +1,3 @@
+// 回调节点协议为grpc时的回调协议,修改callback.proto后重新生成callback.pb.go
+//go:generate protoc --go_out=plugins=grpc,paths=source_relative:. callback.proto
+package grpcproto
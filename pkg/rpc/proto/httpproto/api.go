@@ -16,12 +16,37 @@ type AddTaskReq struct {
 	Name string `json:"name" validate:"required"`
 	SrvName string 	`json:"srv_name" validate:"required"`
 	CallbackPath string `json:"callback_path"`
+	// 回调http节点的请求方法,GET,POST或PUT,默认POST.GET时回调参数按query string传递
+	CallbackMethod string `json:"callback_method"`
+	// POST和PUT时请求体的格式,application/json或application/x-www-form-urlencoded,默认application/json
+	CallbackContentType string `json:"callback_content_type"`
 	SchedMode proto.SchedMode `json:"sched_mode" validate:"required"`
 	TimeCron string `json:"time_cron"`
+	TimeZone string `json:"time_zone"`
 	TimeIntervalSec int `json:"time_interval_sec"`
 	TimeSpecAt int64 `json:"time_spec_at"`
 	Arg string `json:"arg"`
 	BizId string `json:"biz_id"`
+	RetryPolicy *RetryPolicy `json:"retry_policy"`
+	MisfirePolicy proto.MisfirePolicy `json:"misfire_policy"`
+	MisfireMaxCatchUpTimes int `json:"misfire_max_catch_up_times" validate:"gte=0"`
+	MisfireThresholdSec int `json:"misfire_threshold_sec" validate:"gte=0"`
+	ConcurrencyPolicy proto.ConcurrencyPolicy `json:"concurrency_policy"`
+	MaxRunTimeSec int `json:"max_run_time_sec" validate:"gte=0"`
+	DispatchMode proto.DispatchMode `json:"dispatch_mode"`
+	BroadcastSuccessRule proto.BroadcastSuccessRule `json:"broadcast_success_rule"`
+	ShardTotal int `json:"shard_total" validate:"gte=0"`
+	RouteStrategy proto.RouteStrategy `json:"route_strategy"`
+	LabelSelector map[string]string `json:"label_selector"`
+	FailoverMaxAttempts int `json:"failover_max_attempts" validate:"gte=0"`
+}
+
+type RetryPolicy struct {
+	MaxAttempts int `json:"max_attempts" validate:"gte=0"`
+	InitialDelaySec int `json:"initial_delay_sec" validate:"gte=0"`
+	Multiplier float64 `json:"multiplier" validate:"gte=0"`
+	MaxDelaySec int `json:"max_delay_sec" validate:"gte=0"`
+	Jitter float64 `json:"jitter" validate:"gte=0,lte=1"`
 }
 
 type AddTaskResp struct {
@@ -35,16 +60,78 @@ type StopTaskReq struct {
 type StopTaskResp struct {
 }
 
+type PauseTaskReq struct {
+	TaskId string `json:"task_id" validate:"required"`
+}
+
+type PauseTaskResp struct {
+}
+
+type ResumeTaskReq struct {
+	TaskId string `json:"task_id" validate:"required"`
+}
+
+type ResumeTaskResp struct {
+}
+
+type TriggerTaskReq struct {
+	TaskId string `json:"task_id" validate:"required"`
+	// 不传则使用任务注册时的参数
+	Arg *string `json:"arg"`
+}
+
+type TriggerTaskResp struct {
+}
+
 type ConfirmTaskReq struct {
 	TaskId string `json:"task_id" validate:"required"`
 	IsSuccess bool `json:"is_success"`
 	Extra string `json:"extra"`
 	TaskRunTimes int `json:"task_run_times" validate:"required"`
+	// 确认广播或分片执行的子执行时传回调请求中的sub_run_idx
+	SubRunIdx int `json:"sub_run_idx" validate:"gte=0"`
 }
 
 type ConfirmTaskResp struct {
 }
 
+type CancelRunReq struct {
+	TaskId string `json:"task_id" validate:"required"`
+	TaskRunTimes int `json:"task_run_times" validate:"required"`
+}
+
+type CancelRunResp struct {
+}
+
+type ReportTaskProgressReq struct {
+	TaskId string `json:"task_id" validate:"required"`
+	TaskRunTimes int `json:"task_run_times" validate:"required"`
+	// 执行进度百分比,不传时只上报心跳,不更新进度和说明
+	Progress *int `json:"progress" validate:"omitempty,gte=0,lte=100"`
+	Message string `json:"message"`
+}
+
+type ReportTaskProgressResp struct {
+}
+
+type GetTaskRunReq struct {
+	TaskId string `json:"task_id" validate:"required"`
+	TaskRunTimes int `json:"task_run_times" validate:"required"`
+}
+
+type GetTaskRunResp struct {
+	TaskId string `json:"task_id"`
+	TaskRunTimes int `json:"task_run_times"`
+	Status proto.RunStatus `json:"status"`
+	StartedAt int64 `json:"started_at"`
+	EndedAt int64 `json:"ended_at"`
+	IsTimeout bool `json:"is_timeout"`
+	IsCancelled bool `json:"is_cancelled"`
+	Progress int `json:"progress"`
+	ProgressMessage string `json:"progress_message"`
+	HeartbeatAt int64 `json:"heartbeat_at"`
+}
+
 type RegisterTaskCallbackSrvReq struct {
 	Name string `json:"name" validate:"required"`
 	Schema string `json:"schema" validate:"required"`
@@ -52,6 +139,24 @@ type RegisterTaskCallbackSrvReq struct {
 	Port int `json:"port" validate:"required"`
 	CallbackTimeoutSec int `json:"callback_timeout_sec"`
 	IsEnableHealthCheck bool `json:"is_enable_health_check"`
+	Weight int `json:"weight" validate:"gte=0,lte=100"`
+	Labels map[string]string `json:"labels"`
+	MaxInFlight int `json:"max_in_flight" validate:"gte=0"`
+	MaxPerSec int `json:"max_per_sec" validate:"gte=0"`
+	// 节点协议为redis时投递任务的stream key,为空时为easytask:task_callback:{服务名称}
+	StreamKey string `json:"stream_key"`
+	// 节点协议为redis时stream的大致长度上限,投递时近似裁剪旧消息,为0时为100000
+	StreamMaxLen int64 `json:"stream_max_len" validate:"gte=0"`
+	// 回调请求签名的密钥,为空时保持之前注册的密钥
+	Secret string `json:"secret"`
+	// 清除之前注册的密钥,不再签名,不能和secret同时传
+	ClearSecret bool `json:"clear_secret"`
+	// 回调和心跳http节点时带上的header
+	Headers map[string]string `json:"headers"`
+	// 同headers,用于token,api key等密钥,加密存储并且在回调日志中隐藏
+	SecretHeaders map[string]string `json:"secret_headers"`
+	// 任务没有指定路由策略时使用的路由策略
+	RouteStrategy proto.RouteStrategy `json:"route_strategy"`
 }
 
 type RegisterTaskCallbackSrvResp struct {
@@ -66,3 +171,72 @@ type UnregisterTaskCallbackSrvReq struct {
 
 type UnregisterTaskCallbackSrvResp struct {
 }
+
+type GetRouteCircuitBreakersReq struct {
+}
+
+type RouteCircuitBreaker struct {
+	RouteId string `json:"route_id"`
+	Schema string `json:"schema"`
+	Host string `json:"host"`
+	Port int `json:"port"`
+	State proto.CircuitBreakerState `json:"state"`
+	Calls int `json:"calls"`
+	Failures int `json:"failures"`
+	OpenedAt int64 `json:"opened_at"`
+}
+
+type GetRouteCircuitBreakersResp struct {
+	Routes []*RouteCircuitBreaker `json:"routes"`
+}
+
+type WorkflowNode struct {
+	Name string `json:"name" validate:"required"`
+	TaskId string `json:"task_id" validate:"required"`
+}
+
+type WorkflowEdge struct {
+	From string `json:"from" validate:"required"`
+	To string `json:"to" validate:"required"`
+	Condition proto.WorkflowEdgeCondition `json:"condition"`
+}
+
+type AddWorkflowReq struct {
+	Name string `json:"name" validate:"required"`
+	Nodes []*WorkflowNode `json:"nodes" validate:"required,min=1,dive"`
+	Edges []*WorkflowEdge `json:"edges" validate:"dive"`
+}
+
+type AddWorkflowResp struct {
+	WorkflowId string `json:"workflow_id"`
+}
+
+type StartWorkflowReq struct {
+	WorkflowId string `json:"workflow_id" validate:"required"`
+}
+
+type StartWorkflowResp struct {
+	WorkflowRunId string `json:"workflow_run_id"`
+}
+
+type GetWorkflowRunReq struct {
+	WorkflowRunId string `json:"workflow_run_id" validate:"required"`
+}
+
+type WorkflowNodeRun struct {
+	Name string `json:"name"`
+	TaskId string `json:"task_id"`
+	Status proto.RunStatus `json:"status"`
+	TaskRunTimes int `json:"task_run_times"`
+}
+
+type GetWorkflowRunResp struct {
+	WorkflowRunId string `json:"workflow_run_id"`
+	WorkflowId string `json:"workflow_id"`
+	WorkflowName string `json:"workflow_name"`
+	Status proto.RunStatus `json:"status"`
+	StartedAt int64 `json:"started_at"`
+	EndedAt int64 `json:"ended_at"`
+	Nodes []*WorkflowNodeRun `json:"nodes"`
+	Edges []*WorkflowEdge `json:"edges"`
+}
@@ -3,6 +3,7 @@ package httpproto
 const (
 	CallbackCmdTaskCallback = iota
 	CallbackCmdTaskSrvHeartBeat
+	CallbackCmdTaskCancel
 )
 
 type TaskCallbackResp struct {
@@ -18,6 +19,13 @@ type TaskCallbackReq struct {
 	Arg      string `json:"arg"`
 	RunTimes int    `json:"run_times"`
 	BizId 	string `json:"biz_id"`
+	WorkflowRunId string `json:"workflow_run_id"`
+	// 广播执行时当前节点是第几个子执行(从1开始)和子执行总数,非广播执行都为0
+	SubRunIdx int `json:"sub_run_idx"`
+	SubRunTotal int `json:"sub_run_total"`
+	// 分片执行时当前是第几个分片(从0开始)和分片总数,不分片时都为0
+	ShardIndex int `json:"shard_index"`
+	ShardTotal int `json:"shard_total"`
 }
 
 type HeartBeatResp struct {
@@ -26,4 +34,16 @@ type HeartBeatResp struct {
 
 type HeartBeatReq struct {
 	Cmd int `json:"cmd"`
+}
+
+// 通知节点取消一次执行,取消后这次执行的确认会被拒绝
+type TaskCancelReq struct {
+	Cmd int `json:"cmd"`
+	TaskId string `json:"task_id"`
+	TaskName string `json:"task_name"`
+	RunTimes int `json:"run_times"`
+	BizId string `json:"biz_id"`
+}
+
+type TaskCancelResp struct {
 }
\ No newline at end of file
@@ -4,4 +4,14 @@ const (
 	HeaderSimpleTraceId = "x-easy-task-trace-id"
 	HeaderSimpleTraceSpanId = "x-easy-task-trace-span-id"
 	HeaderSimpleTraceParentSpanId = "x-easy-task-trace-parent-span-id"
+)
+
+// 回调服务注册时设置了secret时,回调和心跳请求带上的签名header
+const (
+	// 发起请求的unix时间戳(秒)
+	HeaderSignTimestamp = "x-easy-task-timestamp"
+	// 每次请求不同的随机串,用于防重放
+	HeaderSignNonce = "x-easy-task-nonce"
+	// hex(HMAC-SHA256(secret, method + "\n" + path + "\n" + timestamp + "\n" + nonce + "\n" + body)),GET请求时body为query string
+	HeaderSignature = "x-easy-task-signature"
 )
\ No newline at end of file
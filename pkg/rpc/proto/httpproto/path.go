@@ -3,7 +3,17 @@ package httpproto
 const (
 	AddTaskCmdPath = "/add_task"
 	StopTaskCmdPath = "/stop_task"
+	PauseTaskCmdPath = "/pause_task"
+	ResumeTaskCmdPath = "/resume_task"
+	TriggerTaskCmdPath = "/trigger_task"
 	ConfirmTaskCmdPath = "/confirm_task"
+	CancelRunCmdPath = "/cancel_run"
+	ReportTaskProgressCmdPath = "/report_task_progress"
+	GetTaskRunCmdPath = "/get_task_run"
 	RegisterTaskCallbackSrvCmdPath = "/add_task_server"
 	UnregisterTaskCallbackSrvCmdPath = "/del_task_server"
+	GetRouteCircuitBreakersCmdPath = "/get_route_circuit_breakers"
+	AddWorkflowCmdPath = "/add_workflow"
+	StartWorkflowCmdPath = "/start_workflow"
+	GetWorkflowRunCmdPath = "/get_workflow_run"
 )
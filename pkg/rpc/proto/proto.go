@@ -7,4 +7,74 @@ const (
 	SchedModeTimeCron
 	SchedModeTimeSpec
 	SchedModeTimeInterval
+	SchedModeFixedDelay
+)
+
+type MisfirePolicy int
+
+const (
+	MisfirePolicyFireOnce MisfirePolicy = iota
+	MisfirePolicyFireAll
+	MisfirePolicySkip
+	MisfirePolicySkipIfExpired
+)
+
+type ConcurrencyPolicy int
+
+const (
+	ConcurrencyPolicyAllow ConcurrencyPolicy = iota
+	ConcurrencyPolicyForbid
+	ConcurrencyPolicyQueue
+)
+
+type DispatchMode int
+
+const (
+	DispatchModeSingle DispatchMode = iota
+	DispatchModeBroadcast
+)
+
+type BroadcastSuccessRule int
+
+const (
+	BroadcastSuccessRuleAll BroadcastSuccessRule = iota
+	BroadcastSuccessRuleAny
+)
+
+type RouteStrategy int
+
+const (
+	RouteStrategyNil RouteStrategy = iota
+	RouteStrategyRandom
+	RouteStrategyRoundRobin
+	RouteStrategyWeightedRandom
+	RouteStrategyConsistentHash
+	RouteStrategyLeastRecentlyUsed
+	RouteStrategyLeastInFlight
+)
+
+type CircuitBreakerState int
+
+const (
+	CircuitBreakerStateClosed CircuitBreakerState = iota
+	CircuitBreakerStateOpen
+	CircuitBreakerStateHalfOpen
+)
+
+type WorkflowEdgeCondition int
+
+const (
+	WorkflowEdgeConditionOnSuccess WorkflowEdgeCondition = iota
+	WorkflowEdgeConditionOnFailure
+	WorkflowEdgeConditionAlways
+)
+
+type RunStatus int
+
+const (
+	RunStatusPending RunStatus = iota
+	RunStatusRunning
+	RunStatusSuccess
+	RunStatusFailed
+	RunStatusSkipped
 )
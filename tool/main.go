@@ -6,6 +6,7 @@ import (
 	"github.com/995933447/easytask/internal/task"
 	"github.com/995933447/easytask/internal/task/impl/repo/mysql"
 	"github.com/995933447/easytask/internal/util/conf"
+	"github.com/995933447/easytask/internal/util/crypt"
 	"github.com/995933447/easytask/internal/util/logger"
 	"github.com/995933447/easytask/pkg/contxt"
 	"github.com/995933447/goconsole"
@@ -36,11 +37,23 @@ func NewRepos(ctx context.Context) (task.TaskRepo, task.TaskCallbackSrvRepo, tas
 		logger.MustGetSysLogger().Error(ctx, err)
 		return nil, nil, nil, err
 	}
-	if taskCallbackSrvRepo, err = mysql.NewTaskSrvRepo(ctx, cfg.MysqlConf.ConnDsn); err != nil {
+	var secretCipher *crypt.Cipher
+	if cfg.SecretKey != "" {
+		if secretCipher, err = crypt.NewCipher(cfg.SecretKey); err != nil {
+			logger.MustGetSysLogger().Error(ctx, err)
+			return nil, nil, nil, err
+		}
+	}
+	if taskCallbackSrvRepo, err = mysql.NewTaskSrvRepo(ctx, cfg.MysqlConf.ConnDsn, secretCipher); err != nil {
+		logger.MustGetSysLogger().Error(ctx, err)
+		return nil, nil, nil, err
+	}
+	workflowRepo, err := mysql.NewWorkflowRepo(ctx, cfg.MysqlConf.ConnDsn)
+	if err != nil {
 		logger.MustGetSysLogger().Error(ctx, err)
 		return nil, nil, nil, err
 	}
-	if taskRepo, err = mysql.NewTaskRepo(ctx, cfg.MysqlConf.ConnDsn, taskCallbackSrvRepo, taskLogRepo); err != nil {
+	if taskRepo, err = mysql.NewTaskRepo(ctx, cfg.MysqlConf.ConnDsn, taskCallbackSrvRepo, taskLogRepo, workflowRepo); err != nil {
 		logger.MustGetSysLogger().Error(ctx, err)
 		return nil, nil, nil, err
 	}